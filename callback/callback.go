@@ -0,0 +1,180 @@
+// callback/callback.go
+package callback
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Version - текущая версия формата данных кнопок.
+// Формат: v1:<действие>:<id>:<подпись>
+const Version = "v1"
+
+// MaxDataLen - ограничение Telegram на длину callback_data
+const MaxDataLen = 64
+
+// sigLen - длина подписи HMAC в байтах до кодирования
+const sigLen = 8
+
+// MaxActionLen - самое длинное имя действия, при котором данные кнопки
+// с любым int64 ID (до 20 символов) укладываются в MaxDataLen
+const MaxActionLen = MaxDataLen - len(Version+":::") - len("-9223372036854775808") -
+	(sigLen*8+5)/6 // длина подписи в base64 без выравнивания
+
+var (
+	ErrMalformed = errors.New("некорректные данные кнопки")
+	ErrSignature = errors.New("неверная подпись данных кнопки")
+	ErrForbidden = errors.New("недостаточно прав для действия")
+)
+
+// Data - разобранные данные кнопки
+type Data struct {
+	Action string
+	ID     int64
+}
+
+// Codec подписывает и проверяет данные inline-кнопок
+type Codec struct {
+	key []byte
+}
+
+// NewCodec создаёт кодек с секретным ключом подписи
+func NewCodec(secret []byte) *Codec {
+	return &Codec{key: secret}
+}
+
+// Encode формирует подписанные данные кнопки. Имя действия должно
+// проходить CheckAction: Telegram не примет кнопку длиннее MaxDataLen,
+// поэтому такое имя - ошибка в коде, и Encode паникует.
+func (c *Codec) Encode(action string, id int64) string {
+	if err := CheckAction(action); err != nil {
+		panic(err)
+	}
+	payload := Version + ":" + action + ":" + strconv.FormatInt(id, 10)
+	return payload + ":" + c.sign(payload)
+}
+
+// CheckAction проверяет, что имя действия непустое, не содержит
+// разделителя и не длиннее MaxActionLen
+func CheckAction(action string) error {
+	if action == "" || strings.Contains(action, ":") || len(action) > MaxActionLen {
+		return fmt.Errorf("callback: недопустимое имя действия %q (не длиннее %d символов, без «:»)", action, MaxActionLen)
+	}
+	return nil
+}
+
+// Decode проверяет подпись и разбирает данные кнопки
+func (c *Codec) Decode(data string) (Data, error) {
+	if len(data) > MaxDataLen {
+		return Data{}, ErrMalformed
+	}
+
+	parts := strings.Split(data, ":")
+	if len(parts) != 4 || parts[0] != Version {
+		return Data{}, ErrMalformed
+	}
+
+	payload := strings.Join(parts[:3], ":")
+	if !hmac.Equal([]byte(parts[3]), []byte(c.sign(payload))) {
+		return Data{}, ErrSignature
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Data{}, ErrMalformed
+	}
+	return Data{Action: parts[1], ID: id}, nil
+}
+
+// Button создаёт inline-кнопку с подписанными данными
+func (c *Codec) Button(text, action string, id int64) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(text, c.Encode(action, id))
+}
+
+func (c *Codec) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:sigLen])
+}
+
+// Action описывает обработку одного вида кнопок
+type Action struct {
+	// Authorize проверяет, может ли пользователь выполнить действие.
+	// Ошибка ErrForbidden показывается пользователю, остальные логируются.
+	Authorize func(ctx context.Context, q *tgbotapi.CallbackQuery, d Data) error
+	// Handle выполняет действие и возвращает текст ответа на нажатие.
+	// Повторное нажатие должно возвращать ответ, а не повторять действие.
+	Handle func(ctx context.Context, q *tgbotapi.CallbackQuery, d Data) (string, error)
+}
+
+// Dispatcher проверяет данные кнопок и вызывает зарегистрированные действия
+type Dispatcher struct {
+	codec   *Codec
+	bot     *tgbotapi.BotAPI
+	actions map[string]Action
+}
+
+// NewDispatcher создаёт диспетчер callback-запросов
+func NewDispatcher(codec *Codec, bot *tgbotapi.BotAPI) *Dispatcher {
+	return &Dispatcher{
+		codec:   codec,
+		bot:     bot,
+		actions: make(map[string]Action),
+	}
+}
+
+// Register регистрирует действие. Недопустимое имя обнаруживается
+// при запуске, а не при первой отправке кнопки.
+func (d *Dispatcher) Register(name string, a Action) {
+	if err := CheckAction(name); err != nil {
+		panic(err)
+	}
+	d.actions[name] = a
+}
+
+// Dispatch обрабатывает callback-запрос. На запрос всегда отправляется
+// ответ, чтобы у пользователя не зависал индикатор загрузки.
+func (d *Dispatcher) Dispatch(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	answer := d.handle(ctx, q)
+	if _, err := d.bot.Request(tgbotapi.NewCallback(q.ID, answer)); err != nil {
+		log.Printf("Ошибка при отправке ответа на CallbackQuery: %v", err)
+	}
+}
+
+func (d *Dispatcher) handle(ctx context.Context, q *tgbotapi.CallbackQuery) string {
+	data, err := d.codec.Decode(q.Data)
+	if err != nil {
+		log.Printf("Отклонён callback %q от %d: %v", q.Data, q.From.ID, err)
+		return "Кнопка устарела или повреждена."
+	}
+
+	action, ok := d.actions[data.Action]
+	if !ok {
+		return "Неизвестное действие."
+	}
+
+	if action.Authorize != nil {
+		if err := action.Authorize(ctx, q, data); err != nil {
+			if !errors.Is(err, ErrForbidden) {
+				log.Printf("Ошибка проверки прав для %q: %v", data.Action, err)
+			}
+			return "Недостаточно прав для этого действия."
+		}
+	}
+
+	answer, err := action.Handle(ctx, q, data)
+	if err != nil {
+		log.Printf("Ошибка при выполнении действия %s:%d: %v", data.Action, data.ID, err)
+		return "Произошла ошибка. Попробуйте позже."
+	}
+	return answer
+}
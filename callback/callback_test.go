@@ -0,0 +1,114 @@
+// callback/callback_test.go
+package callback
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	c := NewCodec([]byte("secret"))
+	longest := strings.Repeat("a", MaxActionLen)
+
+	tests := []struct {
+		action string
+		id     int64
+	}{
+		{"approve", 1},
+		{"approve", 0},
+		{"reject", -5},
+		{longest, math.MaxInt64},
+		{longest, math.MinInt64},
+	}
+	for _, tt := range tests {
+		data := c.Encode(tt.action, tt.id)
+		if len(data) > MaxDataLen {
+			t.Errorf("Encode(%q, %d) = %d байт, больше %d", tt.action, tt.id, len(data), MaxDataLen)
+		}
+		got, err := c.Decode(data)
+		if err != nil {
+			t.Errorf("Decode(%q): %v", data, err)
+			continue
+		}
+		if got.Action != tt.action || got.ID != tt.id {
+			t.Errorf("Decode(%q) = %+v, ожидалось %s:%d", data, got, tt.action, tt.id)
+		}
+	}
+}
+
+func TestDecodeTampered(t *testing.T) {
+	c := NewCodec([]byte("secret"))
+	data := c.Encode("approve", 42)
+	parts := strings.Split(data, ":")
+	sig := parts[3]
+
+	flipped := []byte(sig)
+	if flipped[0] == 'A' {
+		flipped[0] = 'B'
+	} else {
+		flipped[0] = 'A'
+	}
+
+	tests := []struct {
+		name  string
+		codec *Codec
+		data  string
+		want  error
+	}{
+		{"другой ID", c, "v1:approve:43:" + sig, ErrSignature},
+		{"другое действие", c, "v1:reject:42:" + sig, ErrSignature},
+		{"изменённая подпись", c, "v1:approve:42:" + string(flipped), ErrSignature},
+		{"без подписи", c, "v1:approve:42:", ErrSignature},
+		{"другой ключ", NewCodec([]byte("other")), data, ErrSignature},
+		{"другая версия", c, "v0:approve:42:" + sig, ErrMalformed},
+		{"лишнее поле", c, data + ":x", ErrMalformed},
+		{"нет поля", c, "v1:approve:" + sig, ErrMalformed},
+		{"слишком длинные данные", c, data + strings.Repeat("x", MaxDataLen), ErrMalformed},
+		{"пусто", c, "", ErrMalformed},
+	}
+	for _, tt := range tests {
+		if _, err := tt.codec.Decode(tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Decode(%q) = %v, ожидалась %v", tt.name, tt.data, err, tt.want)
+		}
+	}
+
+	// Подпись не числового ID верна, но разбор ID должен отклонить данные
+	payload := "v1:approve:abc"
+	if _, err := c.Decode(payload + ":" + c.sign(payload)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Decode с нечисловым ID = %v, ожидалась ErrMalformed", err)
+	}
+}
+
+func TestCheckAction(t *testing.T) {
+	for _, name := range []string{"", "a:b", strings.Repeat("a", MaxActionLen+1)} {
+		if err := CheckAction(name); err == nil {
+			t.Errorf("CheckAction(%q) должен вернуть ошибку", name)
+		}
+	}
+	if err := CheckAction(strings.Repeat("a", MaxActionLen)); err != nil {
+		t.Errorf("CheckAction длиной %d: %v", MaxActionLen, err)
+	}
+}
+
+func TestEncodeRejectsLongAction(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Encode со слишком длинным действием должен паниковать")
+		}
+	}()
+	NewCodec([]byte("secret")).Encode(strings.Repeat("a", MaxActionLen+1), 1)
+}
+
+func TestRegisterRejectsLongAction(t *testing.T) {
+	d := NewDispatcher(NewCodec([]byte("secret")), nil)
+	d.Register("approve", Action{})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register со слишком длинным действием должен паниковать")
+		}
+	}()
+	d.Register(strings.Repeat("a", MaxActionLen+1), Action{})
+}
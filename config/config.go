@@ -0,0 +1,239 @@
+// config/config.go
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram_bot/money"
+)
+
+// Режимы получения обновлений
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// Config - настройки бота из переменных окружения
+type Config struct {
+	TelegramToken   string
+	Debug           bool
+	CallbackSecret  string
+	Mode            string
+	Webhook         WebhookConfig
+	Dispatch        DispatchConfig
+	Withdrawal      WithdrawalConfig
+	Referral        ReferralConfig
+	PayoutVaultKey  []byte        // ключ AES-256 для шифрования реквизитов
+	ModerationLease time.Duration // на сколько выполнение закрепляется за модератором
+	PHashDistance   int           // до скольких различающихся битов хэша скриншоты считаются похожими
+	ShutdownTimeout time.Duration
+}
+
+// ReferralConfig - условия реферальной программы
+type ReferralConfig struct {
+	Bonus       money.Amount // разовый бонус за первое одобренное задание реферала
+	PercentBP   int64        // процент уровня 1, пока уровни не настроены, в сотых долях процента
+	PercentDays int          // сколько дней после привязки начисляется процент
+	MaxLevels   int          // глубина многоуровневых начислений
+}
+
+// WithdrawalConfig - ограничения суммы заявки на вывод
+type WithdrawalConfig struct {
+	Min money.Amount
+	Max money.Amount // 0 - без ограничения
+}
+
+// DispatchConfig - настройки параллельной обработки обновлений
+type DispatchConfig struct {
+	Workers        int
+	QueueSize      int
+	EnqueueTimeout time.Duration
+	UpdateTimeout  time.Duration
+}
+
+// WebhookConfig - настройки приёма обновлений через webhook
+type WebhookConfig struct {
+	Listen         string // адрес встроенного HTTP(S)-сервера
+	PublicURL      string // внешний адрес, например https://bot.example.com
+	PathSecret     string // секретная часть пути webhook
+	SecretToken    string // значение заголовка X-Telegram-Bot-Api-Secret-Token
+	CertFile       string // без сертификата сервер работает по HTTP (TLS на прокси)
+	KeyFile        string
+	MaxConnections int
+}
+
+// Telegram допускает в secret_token только эти символы
+var secretTokenRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Load читает настройки из переменных окружения
+func Load() (*Config, error) {
+	cfg := &Config{
+		TelegramToken:  os.Getenv("TELEGRAM_BOT_TOKEN"),
+		Debug:          getBool("BOT_DEBUG", false),
+		CallbackSecret: os.Getenv("CALLBACK_SECRET"),
+		Mode:           strings.ToLower(getString("BOT_MODE", ModePolling)),
+		Webhook: WebhookConfig{
+			Listen:         getString("WEBHOOK_LISTEN", ":8443"),
+			PublicURL:      strings.TrimRight(os.Getenv("WEBHOOK_URL"), "/"),
+			PathSecret:     os.Getenv("WEBHOOK_PATH_SECRET"),
+			SecretToken:    os.Getenv("WEBHOOK_SECRET_TOKEN"),
+			CertFile:       os.Getenv("WEBHOOK_TLS_CERT"),
+			KeyFile:        os.Getenv("WEBHOOK_TLS_KEY"),
+			MaxConnections: getInt("WEBHOOK_MAX_CONNECTIONS", 40),
+		},
+		Dispatch: DispatchConfig{
+			Workers:        getInt("DISPATCH_WORKERS", 16),
+			QueueSize:      getInt("DISPATCH_QUEUE_SIZE", 64),
+			EnqueueTimeout: getDuration("DISPATCH_ENQUEUE_TIMEOUT", 5*time.Second),
+			UpdateTimeout:  getDuration("UPDATE_TIMEOUT", 30*time.Second),
+		},
+		Withdrawal: WithdrawalConfig{
+			Min: getAmount("WITHDRAW_MIN", money.Rubles(400)),
+			Max: getAmount("WITHDRAW_MAX", money.Zero),
+		},
+		Referral: ReferralConfig{
+			Bonus:       getAmount("REFERRAL_BONUS", money.Rubles(50)),
+			PercentBP:   getPercent("REFERRAL_PERCENT", 0),
+			PercentDays: getInt("REFERRAL_PERCENT_DAYS", 30),
+			MaxLevels:   getInt("REFERRAL_MAX_LEVELS", 3),
+		},
+		ModerationLease: getDuration("MODERATION_LEASE", 15*time.Minute),
+		PHashDistance:   getInt("PHASH_MAX_DISTANCE", 6),
+		ShutdownTimeout: getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+
+	if cfg.TelegramToken == "" {
+		return nil, errors.New("TELEGRAM_BOT_TOKEN не задан в переменных окружения")
+	}
+	if cfg.CallbackSecret == "" {
+		cfg.CallbackSecret = cfg.TelegramToken
+	}
+	key, err := decodeKey(os.Getenv("PAYOUT_VAULT_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("PAYOUT_VAULT_KEY: %w", err)
+	}
+	cfg.PayoutVaultKey = key
+
+	if w := cfg.Withdrawal; !w.Max.IsZero() && w.Max.Cmp(w.Min) < 0 {
+		return nil, errors.New("WITHDRAW_MAX не может быть меньше WITHDRAW_MIN")
+	}
+	if l := cfg.Referral.MaxLevels; l < 1 || l > 10 {
+		return nil, errors.New("REFERRAL_MAX_LEVELS должен быть от 1 до 10")
+	}
+	if cfg.ModerationLease < time.Minute {
+		return nil, errors.New("MODERATION_LEASE должен быть не меньше минуты")
+	}
+	// Больше 7 поиск по полосам хэша (migrations/0017) пропускал бы совпадения
+	if d := cfg.PHashDistance; d < 0 || d > 7 {
+		return nil, errors.New("PHASH_MAX_DISTANCE должен быть от 0 до 7")
+	}
+
+	switch cfg.Mode {
+	case ModePolling:
+	case ModeWebhook:
+		if err := cfg.Webhook.validate(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный BOT_MODE %q (ожидается %s или %s)", cfg.Mode, ModePolling, ModeWebhook)
+	}
+
+	return cfg, nil
+}
+
+// Path возвращает путь, по которому сервер принимает обновления
+func (w WebhookConfig) Path() string {
+	return "/webhook/" + w.PathSecret
+}
+
+// URL возвращает полный адрес webhook для регистрации в Telegram
+func (w WebhookConfig) URL() string {
+	return w.PublicURL + w.Path()
+}
+
+func (w WebhookConfig) validate() error {
+	if !strings.HasPrefix(w.PublicURL, "https://") {
+		return errors.New("WEBHOOK_URL должен начинаться с https://")
+	}
+	if w.PathSecret == "" {
+		return errors.New("WEBHOOK_PATH_SECRET не задан")
+	}
+	if !secretTokenRe.MatchString(w.SecretToken) {
+		return errors.New("WEBHOOK_SECRET_TOKEN должен содержать 1-256 символов A-Z, a-z, 0-9, _ или -")
+	}
+	if (w.CertFile == "") != (w.KeyFile == "") {
+		return errors.New("WEBHOOK_TLS_CERT и WEBHOOK_TLS_KEY задаются вместе")
+	}
+	return nil
+}
+
+func getString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func getBool(key string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func getInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func getDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func getAmount(key string, def money.Amount) money.Amount {
+	v, err := money.Parse(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// getPercent читает процент ("10", "2,5") в сотых долях процента
+func getPercent(key string, def int64) int64 {
+	v, err := strconv.ParseFloat(strings.Replace(os.Getenv(key), ",", ".", 1), 64)
+	if err != nil || v < 0 || v > 100 {
+		return def
+	}
+	return int64(math.Round(v * 100))
+}
+
+// decodeKey разбирает 32-байтный ключ в hex или base64
+func decodeKey(v string) ([]byte, error) {
+	if v == "" {
+		return nil, errors.New("не задан (32 байта в hex или base64, например: openssl rand -hex 32)")
+	}
+	if key, err := hex.DecodeString(v); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(v); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("ожидается 32 байта в hex или base64")
+}
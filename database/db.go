@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"telegram_bot/models"
+	"telegram_bot/money"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -18,8 +19,17 @@ import (
 
 var dbInstance *Database
 
+// querier - общие методы *sql.DB и *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
 type Database struct {
 	sqlDB      *sql.DB
+	q          querier // sqlDB или текущая транзакция
+	inTx       bool
 	userStates map[int64]models.State
 	tempData   map[int64]map[string]interface{}
 	tasks      []models.Task
@@ -70,9 +80,15 @@ func NewDatabase() *Database {
 		tempData:   make(map[int64]map[string]interface{}),
 		tasks:      []models.Task{},
 		sqlDB:      sqlDB,
+		q:          sqlDB,
 	}
 }
 
+// SQLDB возвращает пул соединений (нужен для миграций)
+func (db *Database) SQLDB() *sql.DB {
+	return db.sqlDB
+}
+
 // CloseDB - метод для закрытия подключения к базе данных
 func CloseDB() {
 	if dbInstance != nil {
@@ -81,42 +97,52 @@ func CloseDB() {
 	}
 }
 
-// SetTaskStatus обновляет статус задания
-func (db *Database) SetTaskStatus(ctx context.Context, taskID int64, status string) error {
-	query := "UPDATE tasks SET status = $1, updated_at = NOW() WHERE id = $2"
-	result, err := db.sqlDB.ExecContext(ctx, query, status, taskID)
-	if err != nil {
-		return err
+// RunInTx выполняет fn в транзакции. Все методы DBInterface, вызванные
+// через переданный tx, работают внутри неё. Если fn возвращает ошибку,
+// транзакция откатывается. Вложенный вызов использует внешнюю транзакцию.
+func (db *Database) RunInTx(ctx context.Context, fn func(tx DBInterface) error) error {
+	if db.inTx {
+		return fn(db)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	sqlTx, err := db.sqlDB.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("не удалось начать транзакцию: %w", err)
+	}
+
+	txDB := *db
+	txDB.q = sqlTx
+	txDB.inTx = true
+
+	if err := fn(&txDB); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			log.Printf("Ошибка при откате транзакции: %v", rbErr)
+		}
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("задание не найдено")
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("не удалось зафиксировать транзакцию: %w", err)
 	}
 	return nil
 }
 
-func (db *Database) SetUserBalance(ctx context.Context, telegramID int64, newBalance float64) error {
-	query := "UPDATE users SET balance = $1 WHERE telegram_id = $2"
-
-	res, err := db.sqlDB.ExecContext(ctx, query, newBalance, telegramID)
+// SetTaskStatus обновляет статус задания
+func (db *Database) SetTaskStatus(ctx context.Context, taskID int64, status string) error {
+	query := "UPDATE tasks SET status = $1, updated_at = NOW() WHERE id = $2"
+	result, err := db.q.ExecContext(ctx, query, status, taskID)
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("пользователь не найден")
+		return errors.New("задание не найдено")
 	}
-
 	return nil
 }
 
@@ -124,10 +150,11 @@ func (db *Database) SetUserBalance(ctx context.Context, telegramID int64, newBal
 func (db *Database) GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error) {
 	user := &models.User{}
 	query := `
-    SELECT id, telegram_id, admin, username, balance, state, available_at, created_at, referrerID 
+    SELECT id, telegram_id, COALESCE(admin, FALSE), COALESCE(username, ''), COALESCE(balance, 0),
+           COALESCE(state, ''), COALESCE(available_at, 'epoch'), created_at, referrer_id
               FROM users WHERE telegram_id = $1
               `
-	err := db.sqlDB.QueryRowContext(ctx, query, telegramID).Scan(
+	err := db.q.QueryRowContext(ctx, query, telegramID).Scan(
 		&user.ID,
 		&user.TelegramID,
 		&user.Admin,
@@ -150,7 +177,7 @@ func (db *Database) CreateUser(ctx context.Context, user *models.User) error {
     INSERT INTO users (telegram_id, username, balance, state, available_at, created_at, updated_at) 
               VALUES ($1, $2, $3, $4, $5, NOW(), NOW()) RETURNING id
               `
-	return db.sqlDB.QueryRowContext(ctx, query,
+	return db.q.QueryRowContext(ctx, query,
 		user.TelegramID,
 		user.Admin,
 		user.Username,
@@ -164,8 +191,8 @@ func (db *Database) CreateUser(ctx context.Context, user *models.User) error {
 
 // SetUserState обновляет состояние пользователя по telegramID
 func (db *Database) SetUserState(ctx context.Context, telegramID int64, state string) error {
-	query := "UPDATE users SET state = $1, updated_at = NOW() WHERE telegram_id = $2"
-	result, err := db.sqlDB.ExecContext(ctx, query, state, telegramID)
+	query := "UPDATE users SET state = $1, state_changed_at = NOW(), updated_at = NOW() WHERE telegram_id = $2"
+	result, err := db.q.ExecContext(ctx, query, state, telegramID)
 	if err != nil {
 		return err
 	}
@@ -185,17 +212,29 @@ func (db *Database) SetUserState(ctx context.Context, telegramID int64, state st
 // GetUserState получает текущее состояние пользователя
 func (db *Database) GetUserState(ctx context.Context, telegramID int64) (string, error) {
 	var state string
-	query := "SELECT state FROM users WHERE telegram_id = $1"
-	err := db.sqlDB.QueryRowContext(ctx, query, telegramID).Scan(&state)
+	query := "SELECT COALESCE(state, '') FROM users WHERE telegram_id = $1"
+	err := db.q.QueryRowContext(ctx, query, telegramID).Scan(&state)
 	if err != nil {
 		return "", err
 	}
 	return state, nil
 }
 
-func (db *Database) SetUserAvailableAt(ctx context.Context, telegramID int64) error {
+// GetUserStateChangedAt возвращает время последней смены состояния пользователя
+func (db *Database) GetUserStateChangedAt(ctx context.Context, telegramID int64) (time.Time, error) {
+	var changedAt time.Time
+	query := "SELECT COALESCE(state_changed_at, updated_at, NOW()) FROM users WHERE telegram_id = $1"
+	err := db.q.QueryRowContext(ctx, query, telegramID).Scan(&changedAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return changedAt, nil
+}
+
+// SetUserAvailableAt сохраняет время, до которого следующий шаг задания недоступен
+func (db *Database) SetUserAvailableAt(ctx context.Context, telegramID int64, availableAt time.Time) error {
 	query := "UPDATE users SET available_at = $1, updated_at = NOW() WHERE telegram_id = $2"
-	result, err := db.sqlDB.ExecContext(ctx, query, telegramID)
+	result, err := db.q.ExecContext(ctx, query, availableAt, telegramID)
 	if err != nil {
 		return err
 	}
@@ -214,8 +253,8 @@ func (db *Database) SetUserAvailableAt(ctx context.Context, telegramID int64) er
 // GetUserAvailableAt получает время доступности пользователя
 func (db *Database) GetUserAvailableAt(ctx context.Context, telegramID int64) (time.Time, error) {
 	var availableAt time.Time
-	query := "SELECT available_at FROM users WHERE telegram_id = $1"
-	err := db.sqlDB.QueryRowContext(ctx, query, telegramID).Scan(&availableAt)
+	query := "SELECT COALESCE(available_at, 'epoch') FROM users WHERE telegram_id = $1"
+	err := db.q.QueryRowContext(ctx, query, telegramID).Scan(&availableAt)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -226,22 +265,29 @@ func (db *Database) GetUserAvailableAt(ctx context.Context, telegramID int64) (t
 
 // CreateTask создает новое задание
 func (db *Database) CreateTask(ctx context.Context, task *models.Task) error {
+	if task.PerUserLimit == 0 {
+		task.PerUserLimit = 1
+	}
 	query := `
-    INSERT INTO tasks (category, description, link, type, is_active, created_at, status, screenshot_file_id)
-              VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    INSERT INTO tasks (category, description, link, is_active, created_at, status, screenshot_file_id,
+                       reward, max_completions, budget, per_user_limit, template_id)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 			  RETURNING id
               `
-	return db.sqlDB.QueryRowContext(ctx, query, task.Category, task.Description, task.Link, task.IsActive, task.CreatedAt, task.Status, task.ScreenshotFileID).Scan(&task.ID)
+	return db.q.QueryRowContext(ctx, query, task.Category, task.Description, task.Link, task.IsActive, task.CreatedAt, task.Status, task.ScreenshotFileID,
+		task.Reward, task.MaxCompletions, task.Budget, task.PerUserLimit, task.TemplateID).Scan(&task.ID)
 }
 
 // GetTaskByID получает задание по его ID
 func (db *Database) GetTaskByID(ctx context.Context, taskID int64) (*models.Task, error) {
 	task := &models.Task{}
 	query := `
-    SELECT id, user_id, category, description, link, type, is_active, created_at, status, screenshot_file_id  
+    SELECT id, COALESCE(user_id, 0), category, description, COALESCE(link, ''), is_active, created_at,
+           COALESCE(status, ''), COALESCE(screenshot_file_id, ''),
+           reward, max_completions, budget, per_user_limit, taken, spent, template_id
               FROM tasks WHERE id = $1
               `
-	err := db.sqlDB.QueryRowContext(ctx, query, taskID).Scan(
+	err := db.q.QueryRowContext(ctx, query, taskID).Scan(
 		&task.ID,
 		&task.UserID,
 		&task.Category,
@@ -251,6 +297,13 @@ func (db *Database) GetTaskByID(ctx context.Context, taskID int64) (*models.Task
 		&task.CreatedAt,
 		&task.Status,
 		&task.ScreenshotFileID,
+		&task.Reward,
+		&task.MaxCompletions,
+		&task.Budget,
+		&task.PerUserLimit,
+		&task.Taken,
+		&task.Spent,
+		&task.TemplateID,
 	)
 	if err != nil {
 		return nil, err
@@ -261,7 +314,7 @@ func (db *Database) GetTaskByID(ctx context.Context, taskID int64) (*models.Task
 // UpdateTaskStatus обновляет статус задания
 func (db *Database) UpdateTaskStatus(ctx context.Context, taskID int64, status models.Status) error {
 	query := "UPDATE tasks SET is_active = $1, updated_at = NOW() WHERE id = $2"
-	result, err := db.sqlDB.ExecContext(ctx, query, taskID, status)
+	result, err := db.q.ExecContext(ctx, query, taskID, status)
 	if err != nil {
 		return err
 	}
@@ -277,22 +330,42 @@ func (db *Database) UpdateTaskStatus(ctx context.Context, taskID int64, status m
 	return nil
 }
 
-// GetAvailableTaskByType получает доступное задание по типу
-func (db *Database) GetAvailableTaskByType(ctx context.Context, taskType string) (*models.Task, error) {
+// userLimitStatuses - статусы выполнений, которые занимают лимит
+// задания на пользователя
+var userLimitStatuses = []string{
+	models.UserTaskInProgress, models.UserTaskCompleted, models.UserTaskApproved,
+	models.UserTaskResubmit, models.UserTaskAppealed,
+}
+
+// availableTask - условие доступности задания tasks t пользователю $1
+// (userLimitStatuses передаются в $2): задание активно, квота и бюджет
+// не исчерпаны, лимит пользователя не достигнут
+const availableTask = `
+    t.is_active = TRUE
+    AND (t.max_completions IS NULL OR t.taken < t.max_completions)
+    AND (t.budget IS NULL OR t.spent + t.reward <= t.budget)
+    AND (SELECT COUNT(*) FROM user_tasks ut
+         WHERE ut.task_id = t.id AND ut.user_id = $1
+           AND ut.status = ANY($2)) < t.per_user_limit
+`
+
+// GetAvailableTaskByType получает доступное пользователю задание по типу
+func (db *Database) GetAvailableTaskByType(ctx context.Context, taskType string, userID int64) (*models.Task, error) {
 	task := &models.Task{}
 	query := `
-    SELECT id, description, link, type, is_active, created_at, updated_at 
-              FROM tasks 
-              WHERE is_active = TRUE AND type = $1 
+    SELECT id, description, COALESCE(link, ''), category, is_active, created_at, reward
+              FROM tasks t
+              WHERE category = $3 AND ` + availableTask + `
               ORDER BY created_at ASC LIMIT 1
               `
-	err := db.sqlDB.QueryRowContext(ctx, query, taskType).Scan(
+	err := db.q.QueryRowContext(ctx, query, userID, userLimitStatuses, taskType).Scan(
 		&task.ID,
 		&task.Description,
 		&task.Link,
 		&task.Category,
 		&task.IsActive,
 		&task.CreatedAt,
+		&task.Reward,
 	)
 	if err != nil {
 		return nil, err
@@ -300,144 +373,1176 @@ func (db *Database) GetAvailableTaskByType(ctx context.Context, taskType string)
 	return task, nil
 }
 
-// --- Методы для транзакций ---
+// GetAvailableCategories возвращает slug категорий, в которых есть задания,
+// доступные пользователю
+func (db *Database) GetAvailableCategories(ctx context.Context, userID int64) ([]string, error) {
+	rows, err := db.q.QueryContext(ctx, "SELECT DISTINCT t.category FROM tasks t WHERE "+availableTask, userID, userLimitStatuses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slugs []string
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, slug)
+	}
+	return slugs, rows.Err()
+}
+
+// --- Методы для категорий ---
+
+// ErrCategoryNotFound возвращается, если категории с таким slug или ID нет
+var ErrCategoryNotFound = errors.New("категория не найдена")
+
+const categoryColumns = "id, slug, names, emoji, domains, default_reward, is_active, sort_order, created_at, updated_at"
 
-// UpdateUserBalance обновляет баланс пользователя
-func (db *Database) UpdateUserBalance(ctx context.Context, userID int64, amount float64) error {
-	query := "UPDATE users SET balance = balance + $1, updated_at = NOW() WHERE id = $2"
-	result, err := db.sqlDB.ExecContext(ctx, query, amount, userID)
+// ListCategories возвращает категории в порядке сортировки
+func (db *Database) ListCategories(ctx context.Context, activeOnly bool) ([]*models.Category, error) {
+	query := "SELECT " + categoryColumns + " FROM categories WHERE is_active OR NOT $1 ORDER BY sort_order, id"
+	rows, err := db.q.QueryContext(ctx, query, activeOnly)
 	if err != nil {
-		return fmt.Errorf("ошибка обновления баланса пользователя: %w", err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
+	var categories []*models.Category
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// GetCategoryBySlug получает категорию по slug
+func (db *Database) GetCategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	row := db.q.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE slug = $1", slug)
+	c, err := scanCategory(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
+	return c, err
+}
+
+// GetCategoryByID получает категорию по ID
+func (db *Database) GetCategoryByID(ctx context.Context, id int64) (*models.Category, error) {
+	row := db.q.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE id = $1", id)
+	c, err := scanCategory(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
+	return c, err
+}
+
+// SaveCategory создаёт категорию или обновляет существующую с тем же slug
+func (db *Database) SaveCategory(ctx context.Context, c *models.Category) error {
+	names, err := json.Marshal(c.Names)
 	if err != nil {
-		return fmt.Errorf("ошибка получения количества затронутых строк: %w", err)
+		return err
+	}
+	domains := []byte("[]")
+	if len(c.Domains) > 0 {
+		if domains, err = json.Marshal(c.Domains); err != nil {
+			return err
+		}
 	}
+	query := `
+    INSERT INTO categories (slug, names, emoji, domains, default_reward, is_active, sort_order)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    ON CONFLICT (slug) DO UPDATE
+    SET names = EXCLUDED.names, emoji = EXCLUDED.emoji, domains = EXCLUDED.domains,
+        default_reward = EXCLUDED.default_reward, is_active = EXCLUDED.is_active,
+        sort_order = EXCLUDED.sort_order, updated_at = NOW()
+    RETURNING id, created_at, updated_at
+    `
+	return db.q.QueryRowContext(ctx, query, c.Slug, string(names), c.Emoji, string(domains), c.DefaultReward, c.IsActive, c.SortOrder).
+		Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+}
 
+// SetCategoryActive включает или скрывает категорию
+func (db *Database) SetCategoryActive(ctx context.Context, id int64, active bool) error {
+	result, err := db.q.ExecContext(ctx, "UPDATE categories SET is_active = $1, updated_at = NOW() WHERE id = $2", active, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
 	if rowsAffected == 0 {
-		return errors.New("пользователь не найден")
+		return ErrCategoryNotFound
 	}
 	return nil
 }
 
+func scanCategory(row scanner) (*models.Category, error) {
+	c := &models.Category{}
+	var names, domains []byte
+	err := row.Scan(&c.ID, &c.Slug, &names, &c.Emoji, &domains, &c.DefaultReward, &c.IsActive, &c.SortOrder, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(names, &c.Names); err != nil {
+		return nil, fmt.Errorf("некорректные названия категории %s: %w", c.Slug, err)
+	}
+	if err := json.Unmarshal(domains, &c.Domains); err != nil {
+		return nil, fmt.Errorf("некорректные домены категории %s: %w", c.Slug, err)
+	}
+	return c, nil
+}
+
+// --- Методы для шаблонов заданий ---
+
+// ErrTemplateNotFound возвращается, если шаблона нет
+var ErrTemplateNotFound = errors.New("шаблон задания не найден")
+
+// CreateTemplate сохраняет шаблон вместе с шагами. Шаблон по умолчанию
+// заменяет прежний шаблон по умолчанию категории.
+func (db *Database) CreateTemplate(ctx context.Context, t *models.TaskTemplate) error {
+	return db.RunInTx(ctx, func(tx DBInterface) error {
+		if t.IsDefault {
+			_, err := tx.ExecContext(ctx, "UPDATE task_templates SET is_default = FALSE WHERE category = $1 AND is_default", t.Category)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO task_templates (category, name, is_default) VALUES ($1, $2, $3)
+            RETURNING id, created_at
+        `, t.Category, t.Name, t.IsDefault).Scan(&t.ID, &t.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		for i := range t.Steps {
+			step := &t.Steps[i]
+			step.Position = i + 1
+			var deadline *int64
+			if step.Deadline > 0 {
+				seconds := int64(step.Deadline.Seconds())
+				deadline = &seconds
+			}
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO task_template_steps
+                    (template_id, position, instruction, proof_type, proof_count, delay_seconds, deadline_seconds)
+                VALUES ($1, $2, $3, $4, $5, $6, $7)
+            `, t.ID, step.Position, step.Instruction, step.ProofType, step.ProofCount, int64(step.Delay.Seconds()), deadline)
+			if err != nil {
+				return fmt.Errorf("ошибка при сохранении шага %d: %w", step.Position, err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetTemplate получает шаблон с шагами
+func (db *Database) GetTemplate(ctx context.Context, id int) (*models.TaskTemplate, error) {
+	t := &models.TaskTemplate{}
+	err := db.q.QueryRowContext(ctx, `
+        SELECT id, category, name, is_default, created_at FROM task_templates WHERE id = $1
+    `, id).Scan(&t.ID, &t.Category, &t.Name, &t.IsDefault, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Steps, err = db.templateSteps(ctx, t.ID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetDefaultTemplate получает шаблон по умолчанию категории
+func (db *Database) GetDefaultTemplate(ctx context.Context, category string) (*models.TaskTemplate, error) {
+	var id int
+	err := db.q.QueryRowContext(ctx,
+		"SELECT id FROM task_templates WHERE category = $1 AND is_default", category).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return db.GetTemplate(ctx, id)
+}
+
+// ListTemplates возвращает шаблоны с шагами, новые первыми
+func (db *Database) ListTemplates(ctx context.Context) ([]*models.TaskTemplate, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT id, category, name, is_default, created_at FROM task_templates
+        ORDER BY category, is_default DESC, id DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*models.TaskTemplate
+	for rows.Next() {
+		t := &models.TaskTemplate{}
+		if err := rows.Scan(&t.ID, &t.Category, &t.Name, &t.IsDefault, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, t := range templates {
+		if t.Steps, err = db.templateSteps(ctx, t.ID); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// SetDefaultTemplate делает шаблон шаблоном по умолчанию его категории
+func (db *Database) SetDefaultTemplate(ctx context.Context, id int) error {
+	return db.RunInTx(ctx, func(tx DBInterface) error {
+		var category string
+		err := tx.QueryRowContext(ctx, "SELECT category FROM task_templates WHERE id = $1 FOR UPDATE", id).Scan(&category)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTemplateNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE task_templates SET is_default = FALSE WHERE category = $1 AND is_default", category)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE task_templates SET is_default = TRUE WHERE id = $1", id)
+		return err
+	})
+}
+
+func (db *Database) templateSteps(ctx context.Context, templateID int) ([]models.TemplateStep, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT position, instruction, proof_type, proof_count, delay_seconds, COALESCE(deadline_seconds, 0)
+        FROM task_template_steps WHERE template_id = $1
+        ORDER BY position
+    `, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []models.TemplateStep
+	for rows.Next() {
+		var step models.TemplateStep
+		var delay, deadline int64
+		if err := rows.Scan(&step.Position, &step.Instruction, &step.ProofType, &step.ProofCount, &delay, &deadline); err != nil {
+			return nil, err
+		}
+		step.Delay = time.Duration(delay) * time.Second
+		step.Deadline = time.Duration(deadline) * time.Second
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
+
+// --- Методы для доказательств выполнения ---
+
+// AddUserTaskProof сохраняет доказательство и возвращает, сколько
+// доказательств уже прислано на этом шаге
+func (db *Database) AddUserTaskProof(ctx context.Context, p *models.UserTaskProof) (int, error) {
+	var count int
+	err := db.RunInTx(ctx, func(tx DBInterface) error {
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO user_task_proofs (user_task_id, stage, kind, file_id, file_unique_id, phash, content)
+            VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''))
+            RETURNING id, created_at
+        `, p.UserTaskID, p.Stage, p.Kind, p.FileID, p.UniqueID, phashValue(p.PHash), p.Content).Scan(&p.ID, &p.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM user_task_proofs WHERE user_task_id = $1 AND stage = $2 AND NOT superseded",
+			p.UserTaskID, p.Stage).Scan(&count)
+	})
+	return count, err
+}
+
+// ListUserTaskProofs возвращает действующие доказательства по заданию пользователя:
+// заменённые при повторной отправке не возвращаются
+func (db *Database) ListUserTaskProofs(ctx context.Context, userTaskID int) ([]*models.UserTaskProof, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT `+proofColumns+`
+        FROM user_task_proofs WHERE user_task_id = $1 AND NOT superseded
+        ORDER BY stage, id
+    `, userTaskID)
+	if err != nil {
+		return nil, err
+	}
+	return scanProofs(rows)
+}
+
+// proofColumns - столбцы user_task_proofs для scanProofs
+const proofColumns = `id, user_task_id, stage, kind, COALESCE(file_id, ''), COALESCE(file_unique_id, ''),
+               phash, COALESCE(content, ''), created_at`
+
+func scanProofs(rows *sql.Rows) ([]*models.UserTaskProof, error) {
+	defer rows.Close()
+
+	var proofs []*models.UserTaskProof
+	for rows.Next() {
+		p := &models.UserTaskProof{}
+		var phash sql.NullInt64
+		err := rows.Scan(&p.ID, &p.UserTaskID, &p.Stage, &p.Kind, &p.FileID, &p.UniqueID, &phash, &p.Content, &p.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if phash.Valid {
+			hash := uint64(phash.Int64)
+			p.PHash = &hash
+		}
+		proofs = append(proofs, p)
+	}
+	return proofs, rows.Err()
+}
+
+// phashValue - хэш для записи в BIGINT: биты uint64 хранятся как есть
+func phashValue(hash *uint64) interface{} {
+	if hash == nil {
+		return nil
+	}
+	return int64(*hash)
+}
+
+// hammingDistance - SQL-выражение расстояния Хэмминга между BIGINT-хэшами
+func hammingDistance(a, b string) string {
+	return fmt.Sprintf("length(replace(((%s # %s)::bit(64))::text, '0', ''))", a, b)
+}
+
+// FindSimilarProofs ищет скриншоты других выполнений (любых исполнителей
+// и заданий), хэш которых отличается от скриншотов выполнения не больше
+// чем на maxDistance битов. Ближайшие совпадения идут первыми.
+//
+// Кандидаты отбираются по индексу полос phash_bands: при maxDistance < 8
+// похожие хэши совпадают хотя бы в одной полосе. Заменённые при повторной
+// отправке скриншоты не учитываются ни с одной стороны.
+func (db *Database) FindSimilarProofs(ctx context.Context, userTaskID int, maxDistance, limit int) ([]*models.SimilarProof, error) {
+	distance := hammingDistance("p.phash", "o.phash")
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT p.id, p.stage, o.user_task_id, ut.user_id, ut.task_id, `+distance+` AS distance
+        FROM user_task_proofs p
+        JOIN user_task_proofs o ON o.phash_bands && p.phash_bands
+             AND o.user_task_id <> p.user_task_id AND NOT o.superseded
+        JOIN user_tasks ut ON ut.id = o.user_task_id
+        WHERE p.user_task_id = $1 AND NOT p.superseded AND p.phash IS NOT NULL
+          AND `+distance+` <= $2
+        ORDER BY distance, p.stage, o.user_task_id
+        LIMIT $3
+    `, userTaskID, maxDistance, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var similar []*models.SimilarProof
+	for rows.Next() {
+		s := &models.SimilarProof{}
+		if err := rows.Scan(&s.ProofID, &s.Stage, &s.UserTaskID, &s.UserID, &s.TaskID, &s.Distance); err != nil {
+			return nil, err
+		}
+		similar = append(similar, s)
+	}
+	return similar, rows.Err()
+}
+
+// ListUnhashedProofs возвращает скриншоты без перцептивного хэша с ID больше afterID
+func (db *Database) ListUnhashedProofs(ctx context.Context, afterID int64, limit int) ([]*models.UserTaskProof, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT `+proofColumns+`
+        FROM user_task_proofs
+        WHERE id > $1 AND phash IS NULL AND file_id IS NOT NULL AND kind IN ($2, $3)
+        ORDER BY id
+        LIMIT $4
+    `, afterID, models.ProofScreenshot, models.ProofScreenshots, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanProofs(rows)
+}
+
+// SetProofHash сохраняет перцептивный хэш скриншота
+func (db *Database) SetProofHash(ctx context.Context, proofID int64, hash uint64) error {
+	_, err := db.q.ExecContext(ctx, "UPDATE user_task_proofs SET phash = $1 WHERE id = $2", phashValue(&hash), proofID)
+	return err
+}
+
 // --- Методы для связывания задания с пользователем ---
 
-// AssignTaskToUser назначает задание пользователю
-func (db *Database) AssignTaskToUser(ctx context.Context, taskID int64, userID int64) error {
+// taskExhausting - условие, что после резерва следующее место уже не поместится
+// в квоту или бюджет. Вычисляется по значениям строки до обновления.
+const taskExhausting = `(max_completions IS NOT NULL AND taken + 1 >= max_completions)
+                    OR (budget IS NOT NULL AND spent + 2 * reward > budget)`
+
+// taskHasRoom - условие, что в квоте и бюджете задания есть место
+const taskHasRoom = `(max_completions IS NULL OR taken < max_completions)
+                AND (budget IS NULL OR spent + reward <= budget)`
+
+var (
+	// ErrTaskUnavailable возвращается, если квота или бюджет задания исчерпаны
+	// или задание снято с публикации
+	ErrTaskUnavailable = errors.New("задание недоступно")
+	// ErrTaskUserLimit возвращается, если пользователь уже выполнил задание
+	// максимально допустимое число раз
+	ErrTaskUserLimit = errors.New("достигнут лимит выполнений задания")
+)
+
+// AssignTaskToUser резервирует место в задании и назначает его пользователю.
+// Возвращает ID записи user_tasks. Если резерв исчерпал квоту или бюджет,
+// задание снимается с публикации.
+func (db *Database) AssignTaskToUser(ctx context.Context, taskID int64, userID int64) (int64, error) {
 	var userTaskID int64
+	err := db.RunInTx(ctx, func(tx DBInterface) error {
+		// Условное обновление блокирует строку задания: параллельные выдачи
+		// выполняются по очереди и не превышают квоту
+		var reward money.Amount
+		var perUserLimit int
+		err := tx.QueryRowContext(ctx, `
+            UPDATE tasks SET
+                taken = taken + 1,
+                spent = spent + reward,
+                is_active = NOT (`+taskExhausting+`),
+                exhausted_at = CASE WHEN `+taskExhausting+` THEN NOW() END
+            WHERE id = $1 AND is_active = TRUE AND `+taskHasRoom+`
+            RETURNING reward, per_user_limit
+        `, taskID).Scan(&reward, &perUserLimit)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTaskUnavailable
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка при резервировании задания: %w", err)
+		}
+
+		var done int
+		err = tx.QueryRowContext(ctx, `
+            SELECT COUNT(*) FROM user_tasks
+            WHERE task_id = $1 AND user_id = $2 AND status = ANY($3)
+        `, taskID, userID, userLimitStatuses).Scan(&done)
+		if err != nil {
+			return err
+		}
+		if done >= perUserLimit {
+			return ErrTaskUserLimit
+		}
+
+		err = tx.QueryRowContext(ctx, `
+            INSERT INTO user_tasks (user_id, task_id, status, reward, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING id
+        `, userID, taskID, models.UserTaskInProgress, reward).Scan(&userTaskID)
+		if err != nil {
+			return fmt.Errorf("ошибка при выполнении запроса: %w", err)
+		}
+		return nil
+	})
+	return userTaskID, err
+}
+
+// UpdateTaskLimits меняет вознаграждение, квоту, бюджет и лимит на пользователя.
+// Задание снимается с публикации, если места больше нет, и публикуется снова,
+// если было снято из-за исчерпания и место появилось.
+func (db *Database) UpdateTaskLimits(ctx context.Context, task *models.Task) error {
+	return db.RunInTx(ctx, func(tx DBInterface) error {
+		result, err := tx.ExecContext(ctx, `
+            UPDATE tasks SET reward = $2, max_completions = $3, budget = $4, per_user_limit = $5, updated_at = NOW()
+            WHERE id = $1
+        `, task.ID, task.Reward, task.MaxCompletions, task.Budget, task.PerUserLimit)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return errors.New("задание не найдено")
+		}
+
+		_, err = tx.ExecContext(ctx, `
+            UPDATE tasks SET
+                is_active = NOT is_active,
+                exhausted_at = CASE WHEN is_active THEN NOW() END
+            WHERE id = $1 AND (
+                (is_active AND NOT (`+taskHasRoom+`))
+                OR (NOT is_active AND exhausted_at IS NOT NULL AND `+taskHasRoom+`)
+            )
+        `, task.ID)
+		return err
+	})
+}
 
-	// Выполнение запроса с RETURNING id
+// ReleaseTaskSlot возвращает место и бюджет задания после отклонения или
+// истечения срока. Задание, снятое из-за исчерпания квоты, публикуется снова.
+func (db *Database) ReleaseTaskSlot(ctx context.Context, taskID int64, reward money.Amount) error {
 	query := `
-        INSERT INTO user_tasks (user_id, task_id, status, created_at, updated_at) 
-        VALUES ($1, $2, 'in_progress', NOW(), NOW()) RETURNING id
+    UPDATE tasks SET
+        taken = GREATEST(taken - 1, 0),
+        spent = GREATEST(spent - $2, 0),
+        is_active = is_active OR exhausted_at IS NOT NULL,
+        exhausted_at = NULL
+    WHERE id = $1
     `
-	err := db.sqlDB.QueryRowContext(ctx, query, userID, taskID).Scan(&userTaskID)
+	result, err := db.q.ExecContext(ctx, query, taskID, reward)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("задание не найдено")
+	}
+	return nil
+}
+
+// RetakeTaskSlot снова занимает место и бюджет задания, когда отклонённое
+// выполнение одобрено по апелляции. Работа уже сделана, поэтому квота
+// не проверяется; задание снимается с публикации, если места больше нет.
+func (db *Database) RetakeTaskSlot(ctx context.Context, taskID int64, reward money.Amount) error {
+	result, err := db.q.ExecContext(ctx, `
+    UPDATE tasks SET
+        taken = taken + 1,
+        spent = spent + $2,
+        is_active = is_active AND NOT (`+taskExhausting+`),
+        exhausted_at = CASE WHEN is_active AND `+taskExhausting+` THEN NOW() ELSE exhausted_at END
+    WHERE id = $1
+    `, taskID, reward)
 	if err != nil {
-		return fmt.Errorf("ошибка при выполнении запроса: %w", err)
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errors.New("задание не найдено")
 	}
+	return nil
+}
 
-	// Дополнительно можно использовать userTaskID, если нужно
-	// fmt.Printf("Assigned task ID: %d\n", userTaskID)
+// CheckAppealUserLimit проверяет, что одобрение апелляции по выполнению
+// userTaskID не превысит лимит задания на пользователя: после отклонения
+// исполнитель мог взять задание снова. Блокирует строку задания до конца
+// транзакции, как и выдача задания. Возвращает ErrTaskUserLimit.
+func (db *Database) CheckAppealUserLimit(ctx context.Context, userTaskID int64) error {
+	var taskID, userID int64
+	var perUserLimit int
+	err := db.q.QueryRowContext(ctx, `
+        SELECT t.id, ut.user_id, t.per_user_limit
+        FROM user_tasks ut JOIN tasks t ON t.id = ut.task_id
+        WHERE ut.id = $1
+        FOR UPDATE OF t
+    `, userTaskID).Scan(&taskID, &userID, &perUserLimit)
+	if err != nil {
+		return err
+	}
 
+	// Подсчёт после блокировки видит выдачи, завершившиеся до неё
+	var taken int
+	err = db.q.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM user_tasks
+        WHERE task_id = $1 AND user_id = $2 AND id <> $3 AND status = ANY($4)
+    `, taskID, userID, userTaskID, userLimitStatuses).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	// Само выполнение после одобрения тоже займёт место в лимите
+	if taken+1 > perUserLimit {
+		return ErrTaskUserLimit
+	}
 	return nil
 }
 
-// --- Методы для временных данных ---
+// ErrStatusChanged возвращается, если статус записи уже изменён другим запросом
+var ErrStatusChanged = errors.New("статус уже изменён")
 
-// SetTempData устанавливает временные данные для пользователя
-func (db *Database) SetTempData(ctx context.Context, userID int64, key string, jsonValue interface{}) error {
+// GetUserTaskByID получает задание пользователя по его ID
+func (db *Database) GetUserTaskByID(ctx context.Context, userTaskID int64) (*models.UserTask, error) {
 	query := `
-    INSERT INTO temp_data (user_id, key, value)
-    VALUES ($1, $2, $3)
-    ON CONFLICT (user_id, key) DO UPDATE SET value = $3
-    `
-	_, err := db.sqlDB.ExecContext(ctx, query, userID, key, jsonValue)
+    SELECT id, user_id, task_id, status, screenshots, current_stage, last_updated, reward, resubmits, appealed_at,
+           moderator_id, lease_until
+              FROM user_tasks WHERE id = $1
+              `
+	return scanUserTask(db.q.QueryRowContext(ctx, query, userTaskID))
+}
+
+// SetUserTaskStatus меняет статус задания пользователя, только если текущий
+// статус равен from. Иначе возвращает ErrStatusChanged.
+func (db *Database) SetUserTaskStatus(ctx context.Context, userTaskID int64, from, to string) error {
+	query := "UPDATE user_tasks SET status = $1, last_updated = NOW() WHERE id = $2 AND status = $3"
+	result, err := db.q.ExecContext(ctx, query, to, userTaskID, from)
 	if err != nil {
-		return fmt.Errorf("не удалось установить временные данные: %w", err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrStatusChanged
 	}
 	return nil
 }
 
-// GetUserReferralCount возвращает количество рефералов пользователя.
-func (db *Database) GetUserReferralCount(ctx context.Context, userID int64) (int, error) {
-	query := "SELECT COUNT(*) FROM users WHERE referrer_id = $1"
-	var count int
-	err := db.sqlDB.QueryRowContext(ctx, query, userID).Scan(&count)
+// Ошибки очереди проверки
+var (
+	// ErrQueueEmpty возвращается, если свободных выполнений для проверки нет
+	ErrQueueEmpty = errors.New("нет выполнений для проверки")
+	// ErrLeaseTaken возвращается, если выполнение проверяет другой модератор
+	ErrLeaseTaken = errors.New("выполнение проверяет другой модератор")
+)
+
+// leaseFree - условие, что выполнение может взять модератор $2:
+// оно не закреплено, закреплено за ним же или срок аренды истёк
+const leaseFree = `(moderator_id = $2 OR lease_until IS NULL OR lease_until < NOW())`
+
+// ClaimSubmission закрепляет за модератором выполнение на проверку на время lease.
+// Сначала возвращается выполнение, уже взятое этим модератором, затем самое
+// старое свободное. Если свободных нет, возвращает ErrQueueEmpty.
+func (db *Database) ClaimSubmission(ctx context.Context, moderatorID int64, lease time.Duration) (*models.UserTask, error) {
+	var userTaskID int64
+	err := db.q.QueryRowContext(ctx, `
+        UPDATE user_tasks SET moderator_id = $2, lease_until = NOW() + $3 * INTERVAL '1 second'
+        WHERE id = (
+            SELECT id FROM user_tasks
+            WHERE status = $1 AND `+leaseFree+`
+            ORDER BY COALESCE(moderator_id = $2, FALSE) DESC, last_updated
+            LIMIT 1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id
+    `, models.UserTaskCompleted, moderatorID, int(lease.Seconds())).Scan(&userTaskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrQueueEmpty
+	}
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return count, nil
+	return db.GetUserTaskByID(ctx, userTaskID)
 }
 
-// GetCompletedTasksCount возвращает количество выполненных заданий пользователя.
-func (db *Database) GetCompletedTasksCount(ctx context.Context, userID int64) (int, error) {
-	query := "SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND status = 'Completed'"
-	var count int
-	err := db.sqlDB.QueryRowContext(ctx, query, userID).Scan(&count)
+// RenewModerationLease продлевает аренду выполнения модератором или закрепляет
+// его, если аренда свободна. В транзакции блокирует выполнение до её конца,
+// поэтому решение по нему принимает только один модератор.
+// Возвращает ErrStatusChanged, если выполнение уже проверено, и ErrLeaseTaken,
+// если его проверяет другой модератор.
+func (db *Database) RenewModerationLease(ctx context.Context, userTaskID, moderatorID int64, lease time.Duration) error {
+	result, err := db.q.ExecContext(ctx, `
+        UPDATE user_tasks SET moderator_id = $2, lease_until = NOW() + $3 * INTERVAL '1 second'
+        WHERE id = $1 AND status = $4 AND `+leaseFree+`
+    `, userTaskID, moderatorID, int(lease.Seconds()), models.UserTaskCompleted)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return count, nil
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+
+	userTask, err := db.GetUserTaskByID(ctx, userTaskID)
+	if err != nil {
+		return err
+	}
+	if userTask.Status != models.UserTaskCompleted {
+		return ErrStatusChanged
+	}
+	return ErrLeaseTaken
 }
 
-// DeleteTempData удаляет временные данные пользователя по ключу.
-func (db *Database) DeleteTempData(ctx context.Context, userID int64, key string) error {
-	query := "DELETE FROM temp_data WHERE user_id = $1 AND key = $2"
-	res, err := db.sqlDB.ExecContext(ctx, query, userID, key)
+// ReleaseModerationLease возвращает выполнение в очередь
+func (db *Database) ReleaseModerationLease(ctx context.Context, userTaskID, moderatorID int64) error {
+	result, err := db.q.ExecContext(ctx, `
+        UPDATE user_tasks SET moderator_id = NULL, lease_until = NULL
+        WHERE id = $1 AND moderator_id = $2 AND status = $3
+    `, userTaskID, moderatorID, models.UserTaskCompleted)
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := res.RowsAffected()
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrStatusChanged
+	}
+	return nil
+}
+
+// CountSubmissions возвращает, сколько выполнений ждут проверки
+// и сколько из них сейчас проверяют модераторы
+func (db *Database) CountSubmissions(ctx context.Context) (waiting, leased int, err error) {
+	err = db.q.QueryRowContext(ctx, `
+        SELECT COUNT(*), COUNT(*) FILTER (WHERE lease_until >= NOW())
+        FROM user_tasks WHERE status = $1
+    `, models.UserTaskCompleted).Scan(&waiting, &leased)
+	return waiting, leased, err
+}
+
+// AddModerationMessage запоминает карточку выполнения, отправленную модератору
+func (db *Database) AddModerationMessage(ctx context.Context, m models.ModerationMessage) error {
+	_, err := db.q.ExecContext(ctx,
+		"INSERT INTO moderation_messages (user_task_id, chat_id, message_id) VALUES ($1, $2, $3)",
+		m.UserTaskID, m.ChatID, m.MessageID)
+	return err
+}
+
+// ListModerationMessages возвращает карточки выполнения, отправленные модераторам
+func (db *Database) ListModerationMessages(ctx context.Context, userTaskID int) ([]models.ModerationMessage, error) {
+	rows, err := db.q.QueryContext(ctx,
+		"SELECT user_task_id, chat_id, message_id FROM moderation_messages WHERE user_task_id = $1 ORDER BY id",
+		userTaskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.ModerationMessage
+	for rows.Next() {
+		var m models.ModerationMessage
+		if err := rows.Scan(&m.UserTaskID, &m.ChatID, &m.MessageID); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// GetExecutorStats возвращает историю выполнений пользователя
+func (db *Database) GetExecutorStats(ctx context.Context, userID int) (*models.ExecutorStats, error) {
+	stats := &models.ExecutorStats{}
+	err := db.q.QueryRowContext(ctx, `
+    SELECT COUNT(*) FILTER (WHERE status = $2),
+           COUNT(*) FILTER (WHERE status = $3),
+           COUNT(*) FILTER (WHERE status = $4),
+           COUNT(*) FILTER (WHERE status = $5),
+           COALESCE(SUM(reward) FILTER (WHERE status = $2), 0)
+    FROM user_tasks WHERE user_id = $1
+    `, userID, models.UserTaskApproved, models.UserTaskRejected, models.UserTaskExpired, models.UserTaskCompleted).
+		Scan(&stats.Approved, &stats.Rejected, &stats.Expired, &stats.Submitted, &stats.Earned)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ResubmitUserTask возвращает выполнение, ожидающее повторной отправки,
+// на шаг stage. Доказательства этого и следующих шагов помечаются заменёнными.
+// Возвращает номер новой попытки или ErrStatusChanged.
+func (db *Database) ResubmitUserTask(ctx context.Context, userTaskID int64, stage int) (int, error) {
+	var resubmits int
+	err := db.RunInTx(ctx, func(tx DBInterface) error {
+		err := tx.QueryRowContext(ctx, `
+            UPDATE user_tasks SET status = $2, current_stage = $3, resubmits = resubmits + 1,
+                moderator_id = NULL, lease_until = NULL, last_updated = NOW()
+            WHERE id = $1 AND status = $4
+            RETURNING resubmits
+        `, userTaskID, models.UserTaskInProgress, stage, models.UserTaskResubmit).Scan(&resubmits)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrStatusChanged
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE user_task_proofs SET superseded = TRUE WHERE user_task_id = $1 AND stage >= $2 AND NOT superseded",
+			userTaskID, stage)
+		return err
+	})
+	return resubmits, err
+}
+
+// AppealUserTask отмечает отклонённое выполнение обжалованным. Обжаловать
+// можно один раз, иначе возвращается ErrStatusChanged.
+func (db *Database) AppealUserTask(ctx context.Context, userTaskID int64) error {
+	result, err := db.q.ExecContext(ctx, `
+        UPDATE user_tasks SET status = $2, appealed_at = NOW(), last_updated = NOW()
+        WHERE id = $1 AND status = $3 AND appealed_at IS NULL
+    `, userTaskID, models.UserTaskAppealed, models.UserTaskRejected)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return errors.New("временные данные не найдены")
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrStatusChanged
 	}
 	return nil
 }
 
-// GetPendingTasks возвращает список заданий со статусом "Pending".
-func (db *Database) GetPendingTasks(ctx context.Context) ([]*models.Task, error) {
-	query := "SELECT id, user_id, category, description, is_active, created_at, status, link, screenshot_file_id FROM tasks WHERE status = 'Pending'"
-	rows, err := db.sqlDB.QueryContext(ctx, query)
+// ListAppealedUserTasks возвращает нерассмотренные апелляции, начиная с самых старых
+func (db *Database) ListAppealedUserTasks(ctx context.Context, limit int) ([]int64, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT id FROM user_tasks WHERE status = $1
+        ORDER BY appealed_at, id LIMIT $2
+    `, models.UserTaskAppealed, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tasks []*models.Task
+	var ids []int64
 	for rows.Next() {
-		var task models.Task
-		if err := rows.Scan(&task.ID, &task.UserID, &task.Category, &task.Description, &task.IsActive, &task.CreatedAt, &task.Status, &task.Link, &task.ScreenshotFileID); err != nil {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, &task)
+		ids = append(ids, id)
 	}
+	return ids, rows.Err()
+}
 
-	if err := rows.Err(); err != nil {
+// scanner - общий интерфейс *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUserTask(row scanner) (*models.UserTask, error) {
+	userTask := &models.UserTask{}
+	var screenshots []byte
+	err := row.Scan(
+		&userTask.ID,
+		&userTask.UserID,
+		&userTask.TaskID,
+		&userTask.Status,
+		&screenshots,
+		&userTask.CurrentStage,
+		&userTask.LastUpdated,
+		&userTask.Reward,
+		&userTask.Resubmits,
+		&userTask.AppealedAt,
+		&userTask.ModeratorID,
+		&userTask.LeaseUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(screenshots) > 0 {
+		if err := json.Unmarshal(screenshots, &userTask.Screenshots); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе скриншотов: %w", err)
+		}
+	}
+	return userTask, nil
+}
+
+// --- Методы для модерации ---
+
+// ErrReasonNotFound возвращается, если причины отклонения нет
+var ErrReasonNotFound = errors.New("причина отклонения не найдена")
+
+// ListRejectionReasons возвращает причины отклонения в порядке показа
+func (db *Database) ListRejectionReasons(ctx context.Context, activeOnly bool) ([]*models.RejectionReason, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT id, text, allow_resubmit, is_active, sort_order
+        FROM rejection_reasons WHERE is_active OR NOT $1
+        ORDER BY sort_order, id
+    `, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reasons []*models.RejectionReason
+	for rows.Next() {
+		r := &models.RejectionReason{}
+		if err := rows.Scan(&r.ID, &r.Text, &r.AllowResubmit, &r.IsActive, &r.SortOrder); err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, r)
+	}
+	return reasons, rows.Err()
+}
+
+// GetRejectionReason получает причину отклонения по ID
+func (db *Database) GetRejectionReason(ctx context.Context, id int64) (*models.RejectionReason, error) {
+	r := &models.RejectionReason{}
+	err := db.q.QueryRowContext(ctx, `
+        SELECT id, text, allow_resubmit, is_active, sort_order
+        FROM rejection_reasons WHERE id = $1
+    `, id).Scan(&r.ID, &r.Text, &r.AllowResubmit, &r.IsActive, &r.SortOrder)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReasonNotFound
+	}
+	if err != nil {
 		return nil, err
 	}
+	return r, nil
+}
+
+// CreateRejectionReason добавляет причину отклонения в конец списка
+func (db *Database) CreateRejectionReason(ctx context.Context, r *models.RejectionReason) error {
+	return db.q.QueryRowContext(ctx, `
+        INSERT INTO rejection_reasons (text, allow_resubmit, sort_order)
+        VALUES ($1, $2, (SELECT COALESCE(MAX(sort_order), 0) + 10 FROM rejection_reasons))
+        RETURNING id, is_active, sort_order
+    `, r.Text, r.AllowResubmit).Scan(&r.ID, &r.IsActive, &r.SortOrder)
+}
+
+// SetRejectionReasonActive включает или скрывает причину отклонения
+func (db *Database) SetRejectionReasonActive(ctx context.Context, id int64, active bool) error {
+	result, err := db.q.ExecContext(ctx, "UPDATE rejection_reasons SET is_active = $1 WHERE id = $2", active, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrReasonNotFound
+	}
+	return nil
+}
+
+// AddModerationDecision записывает решение в историю выполнения
+func (db *Database) AddModerationDecision(ctx context.Context, d *models.ModerationDecision) error {
+	return db.q.QueryRowContext(ctx, `
+        INSERT INTO moderation_decisions (user_task_id, moderator_id, decision, reason, reason_id, rule_id)
+        VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+        RETURNING id, created_at
+    `, d.UserTaskID, d.ModeratorID, d.Decision, d.Reason, d.ReasonID, d.RuleID).Scan(&d.ID, &d.CreatedAt)
+}
+
+// ListModerationDecisions возвращает историю решений по выполнению
+func (db *Database) ListModerationDecisions(ctx context.Context, userTaskID int) ([]*models.ModerationDecision, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT id, user_task_id, moderator_id, decision, COALESCE(reason, ''), reason_id, rule_id, created_at
+        FROM moderation_decisions WHERE user_task_id = $1
+        ORDER BY id
+    `, userTaskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	return tasks, nil
+	var decisions []*models.ModerationDecision
+	for rows.Next() {
+		d := &models.ModerationDecision{}
+		if err := rows.Scan(&d.ID, &d.UserTaskID, &d.ModeratorID, &d.Decision, &d.Reason, &d.ReasonID, &d.RuleID, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, rows.Err()
+}
+
+// --- Методы для автоматической проверки ---
+
+// ErrTriageRuleNotFound возвращается, если правила нет
+var ErrTriageRuleNotFound = errors.New("правило автоматической проверки не найдено")
+
+// ListTriageRules возвращает правила автоматической проверки в порядке применения
+func (db *Database) ListTriageRules(ctx context.Context, activeOnly bool) ([]*models.TriageRule, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT id, category, action, conditions, position, is_active
+        FROM triage_rules WHERE is_active OR NOT $1
+        ORDER BY position, id
+    `, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*models.TriageRule
+	for rows.Next() {
+		r := &models.TriageRule{}
+		var conditions []byte
+		if err := rows.Scan(&r.ID, &r.Category, &r.Action, &conditions, &r.Position, &r.IsActive); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(conditions, &r.Conditions); err != nil {
+			return nil, fmt.Errorf("ошибка при разборе условий правила %d: %w", r.ID, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// GetTriageRule получает правило автоматической проверки по ID
+func (db *Database) GetTriageRule(ctx context.Context, id int64) (*models.TriageRule, error) {
+	r := &models.TriageRule{}
+	var conditions []byte
+	err := db.q.QueryRowContext(ctx, `
+        SELECT id, category, action, conditions, position, is_active
+        FROM triage_rules WHERE id = $1
+    `, id).Scan(&r.ID, &r.Category, &r.Action, &conditions, &r.Position, &r.IsActive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTriageRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(conditions, &r.Conditions); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе условий правила %d: %w", r.ID, err)
+	}
+	return r, nil
+}
+
+// CreateTriageRule добавляет правило в конец списка
+func (db *Database) CreateTriageRule(ctx context.Context, r *models.TriageRule) error {
+	conditions, err := json.Marshal(r.Conditions)
+	if err != nil {
+		return err
+	}
+	return db.q.QueryRowContext(ctx, `
+        INSERT INTO triage_rules (category, action, conditions, position)
+        VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position), 0) + 10 FROM triage_rules))
+        RETURNING id, is_active, position
+    `, r.Category, r.Action, conditions).Scan(&r.ID, &r.IsActive, &r.Position)
+}
+
+// SetTriageRuleActive включает или выключает правило
+func (db *Database) SetTriageRuleActive(ctx context.Context, id int64, active bool) error {
+	result, err := db.q.ExecContext(ctx, "UPDATE triage_rules SET is_active = $1 WHERE id = $2", active, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTriageRuleNotFound
+	}
+	return nil
+}
+
+// ListAutoDecisions возвращает последние автоматические решения, новые первыми
+func (db *Database) ListAutoDecisions(ctx context.Context, limit int) ([]*models.AutoDecision, error) {
+	rows, err := db.q.QueryContext(ctx, `
+        SELECT d.id, d.user_task_id, ut.task_id, d.decision, COALESCE(d.reason, ''), d.rule_id,
+               NOT EXISTS (
+                   SELECT 1 FROM moderation_decisions l WHERE l.user_task_id = d.user_task_id AND l.id > d.id
+               ),
+               d.created_at
+        FROM moderation_decisions d
+        JOIN user_tasks ut ON ut.id = d.user_task_id
+        WHERE d.decision IN ($1, $2)
+        ORDER BY d.id DESC
+        LIMIT $3
+    `, models.DecisionAutoApproved, models.DecisionAutoRejected, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []*models.AutoDecision
+	for rows.Next() {
+		d := &models.AutoDecision{}
+		err := rows.Scan(&d.ID, &d.UserTaskID, &d.TaskID, &d.Decision, &d.Reason, &d.RuleID, &d.Latest, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, rows.Err()
+}
+
+// SetUserTrustLevel задаёт уровень доверия исполнителя. Возвращает
+// sql.ErrNoRows, если пользователь не найден.
+func (db *Database) SetUserTrustLevel(ctx context.Context, telegramID int64, level int) error {
+	result, err := db.q.ExecContext(ctx,
+		"UPDATE users SET trust_level = $1, updated_at = NOW() WHERE telegram_id = $2", level, telegramID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetSeniorModerator назначает администратора старшим модератором или
+// снимает назначение. Возвращает sql.ErrNoRows, если такого администратора нет.
+func (db *Database) SetSeniorModerator(ctx context.Context, telegramID int64, senior bool) error {
+	result, err := db.q.ExecContext(ctx,
+		"UPDATE users SET senior_moderator = $1, updated_at = NOW() WHERE telegram_id = $2 AND admin", senior, telegramID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- Методы для временных данных ---
+
+// SetTempData устанавливает временные данные для пользователя
+func (db *Database) SetTempData(ctx context.Context, userID int64, key string, jsonValue interface{}) error {
+	query := `
+    INSERT INTO temp_data (user_id, key, value)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (user_id, key) DO UPDATE SET value = $3
+    `
+	_, err := db.q.ExecContext(ctx, query, userID, key, jsonValue)
+	if err != nil {
+		return fmt.Errorf("не удалось установить временные данные: %w", err)
+	}
+	return nil
+}
+
+// GetUserReferralCount возвращает количество рефералов пользователя.
+// referrer_id хранит внутренний ID, поэтому пригласивший ищется по Telegram ID.
+func (db *Database) GetUserReferralCount(ctx context.Context, telegramID int64) (int, error) {
+	query := `
+    SELECT COUNT(*) FROM users r
+    JOIN users u ON r.referrer_id = u.id
+    WHERE u.telegram_id = $1
+    `
+	var count int
+	err := db.q.QueryRowContext(ctx, query, telegramID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ErrTempDataNotFound возвращается, если временных данных с таким ключом нет
+var ErrTempDataNotFound = errors.New("временные данные не найдены")
+
+// DeleteTempData удаляет временные данные пользователя по ключу.
+func (db *Database) DeleteTempData(ctx context.Context, userID int64, key string) error {
+	query := "DELETE FROM temp_data WHERE user_id = $1 AND key = $2"
+	res, err := db.q.ExecContext(ctx, query, userID, key)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTempDataNotFound
+	}
+	return nil
 }
 
 // Реализация общих методов, если они добавлены в интерфейс
 
 // ExecContext выполняет общий SQL-запрос.
 func (db *Database) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return db.sqlDB.ExecContext(ctx, query, args...)
+	return db.q.ExecContext(ctx, query, args...)
 }
 
 // QueryRowContext выполняет запрос, возвращающий одну строку.
 func (db *Database) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return db.sqlDB.QueryRowContext(ctx, query, args...)
+	return db.q.QueryRowContext(ctx, query, args...)
 }
 
 // Query выполняет запрос, возвращающий множество строк.
 func (db *Database) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return db.sqlDB.QueryContext(ctx, query, args...)
+	return db.q.QueryContext(ctx, query, args...)
 }
 
 // Реализация методов для работы с временными данными
@@ -445,7 +1550,7 @@ func (db *Database) QueryContext(ctx context.Context, query string, args ...inte
 // GetTempData получает временные данные пользователя по ключу.
 func (db *Database) GetTempData(ctx context.Context, userID int64, key string) (interface{}, error) {
 	query := "SELECT value FROM temp_data WHERE user_id = $1 AND key = $2"
-	row := db.sqlDB.QueryRowContext(ctx, query, userID, key)
+	row := db.q.QueryRowContext(ctx, query, userID, key)
 
 	var jsonData []byte
 	err := row.Scan(&jsonData)
@@ -465,19 +1570,30 @@ func (db *Database) GetTempData(ctx context.Context, userID int64, key string) (
 	return value, nil
 }
 
-// --- Дополнительные методы (например, создание транзакций) ---
+// SetTempValue сохраняет во временных данных значение любого типа в виде JSON
+func (db *Database) SetTempValue(ctx context.Context, userID int64, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать временные данные %s: %w", key, err)
+	}
+	return db.SetTempData(ctx, userID, key, string(data))
+}
 
-// CreateTransaction создает новую транзакцию
-func (db *Database) CreateTransaction(ctx context.Context, tx *models.Transaction) error {
-	query := `
-    INSERT INTO transactions (user_id, amount, description, created_at) 
-              VALUES ($1, $2, $3, NOW()) RETURNING id
-              `
-	return db.sqlDB.QueryRowContext(ctx, query,
-		tx.UserID,
-		tx.Amount,
-		tx.Description,
-	).Scan(&tx.ID)
+// GetTempValue читает временные данные, сохранённые SetTempValue, в value.
+// Возвращает ErrTempDataNotFound, если данных нет.
+func (db *Database) GetTempValue(ctx context.Context, userID int64, key string, value interface{}) error {
+	var data []byte
+	err := db.q.QueryRowContext(ctx, "SELECT value FROM temp_data WHERE user_id = $1 AND key = $2", userID, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTempDataNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("некорректные временные данные %s: %w", key, err)
+	}
+	return nil
 }
 
 // --- Методы для администраторов ---
@@ -486,7 +1602,7 @@ func (db *Database) CreateTransaction(ctx context.Context, tx *models.Transactio
 func (db *Database) IsAdmin(ctx context.Context, telegramID int64) (bool, error) {
 	var isAdmin bool
 	query := "SELECT admin FROM users WHERE telegram_id = $1"
-	err := db.sqlDB.QueryRowContext(ctx, query, telegramID).Scan(&isAdmin)
+	err := db.q.QueryRowContext(ctx, query, telegramID).Scan(&isAdmin)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Пользователь не найден
@@ -496,22 +1612,3 @@ func (db *Database) IsAdmin(ctx context.Context, telegramID int64) (bool, error)
 	}
 	return isAdmin, nil
 }
-
-func (db *Database) SaveUserTaskScreenshot(ctx context.Context, userID int64, fileID string) error {
-	query := "UPDATE tasks SET screenshot_file_id = $1 WHERE user_id = $2 AND is_completed = false"
-	result, err := db.sqlDB.ExecContext(ctx, query, fileID, userID) // Убедитесь, что используете правильный объект для ExecContext
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if rowsAffected == 0 {
-		return errors.New("задание не найдено или уже завершено")
-	}
-
-	return nil
-}
@@ -6,36 +6,88 @@ import (
 	"time"
 
 	"telegram_bot/models"
+	"telegram_bot/money"
 )
 
 // DBInterface определяет методы для взаимодействия с базой данных
 type DBInterface interface {
 	GetUserAvailableAt(ctx context.Context, telegramID int64) (time.Time, error)
 	CreateTask(ctx context.Context, task *models.Task) error
-	SetUserAvailableAt(ctx context.Context, telegramID int64) error
+	SetUserAvailableAt(ctx context.Context, telegramID int64, availableAt time.Time) error
 	GetTaskByID(ctx context.Context, taskID int64) (*models.Task, error)
-	UpdateUserBalance(ctx context.Context, userID int64, balance float64) error
 	UpdateTaskStatus(ctx context.Context, taskID int64, status models.Status) error
 	SetTempData(ctx context.Context, userID int64, key string, value interface{}) error
 	GetTempData(ctx context.Context, userID int64, key string) (interface{}, error)
-	GetAvailableTaskByType(ctx context.Context, taskType string) (*models.Task, error)
-	AssignTaskToUser(ctx context.Context, taskID, userID int64) error
+	SetTempValue(ctx context.Context, userID int64, key string, value interface{}) error
+	GetTempValue(ctx context.Context, userID int64, key string, value interface{}) error
+	GetAvailableTaskByType(ctx context.Context, taskType string, userID int64) (*models.Task, error)
+	GetAvailableCategories(ctx context.Context, userID int64) ([]string, error)
+	AssignTaskToUser(ctx context.Context, taskID, userID int64) (int64, error)
+	ReleaseTaskSlot(ctx context.Context, taskID int64, reward money.Amount) error
+	RetakeTaskSlot(ctx context.Context, taskID int64, reward money.Amount) error
+	UpdateTaskLimits(ctx context.Context, task *models.Task) error
+
+	ListCategories(ctx context.Context, activeOnly bool) ([]*models.Category, error)
+	GetCategoryBySlug(ctx context.Context, slug string) (*models.Category, error)
+	GetCategoryByID(ctx context.Context, id int64) (*models.Category, error)
+	SaveCategory(ctx context.Context, c *models.Category) error
+	SetCategoryActive(ctx context.Context, id int64, active bool) error
+
 	SetUserState(ctx context.Context, userID int64, state string) error
 	GetUserState(ctx context.Context, userID int64) (string, error)
+	GetUserStateChangedAt(ctx context.Context, telegramID int64) (time.Time, error)
 
 	GetUserByTelegramID(ctx context.Context, telegramID int64) (*models.User, error)
-	SetUserBalance(ctx context.Context, telegramID int64, newBalance float64) error
 
 	SetTaskStatus(ctx context.Context, taskID int64, status string) error
-	GetPendingTasks(ctx context.Context) ([]*models.Task, error)
 	DeleteTempData(ctx context.Context, userID int64, key string) error
 
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 
-	GetUserReferralCount(ctx context.Context, userID int64) (int, error)
-	GetCompletedTasksCount(ctx context.Context, userID int64) (int, error)
+	GetUserReferralCount(ctx context.Context, telegramID int64) (int, error)
+
+	CreateTemplate(ctx context.Context, t *models.TaskTemplate) error
+	GetTemplate(ctx context.Context, id int) (*models.TaskTemplate, error)
+	GetDefaultTemplate(ctx context.Context, category string) (*models.TaskTemplate, error)
+	ListTemplates(ctx context.Context) ([]*models.TaskTemplate, error)
+	SetDefaultTemplate(ctx context.Context, id int) error
+
+	AddUserTaskProof(ctx context.Context, p *models.UserTaskProof) (int, error)
+	ListUserTaskProofs(ctx context.Context, userTaskID int) ([]*models.UserTaskProof, error)
+	FindSimilarProofs(ctx context.Context, userTaskID int, maxDistance, limit int) ([]*models.SimilarProof, error)
+	ListUnhashedProofs(ctx context.Context, afterID int64, limit int) ([]*models.UserTaskProof, error)
+	SetProofHash(ctx context.Context, proofID int64, hash uint64) error
+
+	GetUserTaskByID(ctx context.Context, userTaskID int64) (*models.UserTask, error)
+	SetUserTaskStatus(ctx context.Context, userTaskID int64, from, to string) error
+	ClaimSubmission(ctx context.Context, moderatorID int64, lease time.Duration) (*models.UserTask, error)
+	RenewModerationLease(ctx context.Context, userTaskID, moderatorID int64, lease time.Duration) error
+	ReleaseModerationLease(ctx context.Context, userTaskID, moderatorID int64) error
+	CountSubmissions(ctx context.Context) (waiting, leased int, err error)
+	AddModerationMessage(ctx context.Context, m models.ModerationMessage) error
+	ListModerationMessages(ctx context.Context, userTaskID int) ([]models.ModerationMessage, error)
+	GetExecutorStats(ctx context.Context, userID int) (*models.ExecutorStats, error)
+	ResubmitUserTask(ctx context.Context, userTaskID int64, stage int) (int, error)
+	AppealUserTask(ctx context.Context, userTaskID int64) error
+	ListAppealedUserTasks(ctx context.Context, limit int) ([]int64, error)
+	CheckAppealUserLimit(ctx context.Context, userTaskID int64) error
+
+	ListRejectionReasons(ctx context.Context, activeOnly bool) ([]*models.RejectionReason, error)
+	GetRejectionReason(ctx context.Context, id int64) (*models.RejectionReason, error)
+	CreateRejectionReason(ctx context.Context, r *models.RejectionReason) error
+	SetRejectionReasonActive(ctx context.Context, id int64, active bool) error
+	AddModerationDecision(ctx context.Context, d *models.ModerationDecision) error
+	ListModerationDecisions(ctx context.Context, userTaskID int) ([]*models.ModerationDecision, error)
+
+	ListTriageRules(ctx context.Context, activeOnly bool) ([]*models.TriageRule, error)
+	GetTriageRule(ctx context.Context, id int64) (*models.TriageRule, error)
+	CreateTriageRule(ctx context.Context, r *models.TriageRule) error
+	SetTriageRuleActive(ctx context.Context, id int64, active bool) error
+	ListAutoDecisions(ctx context.Context, limit int) ([]*models.AutoDecision, error)
+	SetUserTrustLevel(ctx context.Context, telegramID int64, level int) error
+	SetSeniorModerator(ctx context.Context, telegramID int64, senior bool) error
 
-	SaveUserTaskScreenshot(ctx context.Context, userID int64, fileID string) error
+	RunInTx(ctx context.Context, fn func(tx DBInterface) error) error
 }
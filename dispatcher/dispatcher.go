@@ -0,0 +1,222 @@
+// dispatcher/dispatcher.go
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleFunc обрабатывает одно обновление
+type HandleFunc func(ctx context.Context, update tgbotapi.Update)
+
+// ErrClosed возвращается при попытке отправить обновление после остановки
+var ErrClosed = errors.New("диспетчер остановлен")
+
+// ErrQueueFull возвращается, если очередь шарда не освободилась за EnqueueTimeout
+var ErrQueueFull = errors.New("очередь обработки переполнена")
+
+// Config - параметры пула обработчиков
+type Config struct {
+	Workers        int           // количество шардов (горутин)
+	QueueSize      int           // длина очереди каждого шарда
+	EnqueueTimeout time.Duration // сколько ждать места в переполненной очереди
+	HandleTimeout  time.Duration // дедлайн обработки одного обновления
+}
+
+// Stats - счётчики для мониторинга очередей
+type Stats struct {
+	Enqueued  uint64 // принято в очередь
+	Processed uint64 // обработано
+	Blocked   uint64 // пришлось ждать места в очереди
+	Dropped   uint64 // не принято: очередь не освободилась за EnqueueTimeout
+	TimedOut  uint64 // обработка превысила HandleTimeout
+	Queued    []int  // текущая длина очереди каждого шарда
+}
+
+// Dispatcher распределяет обновления по шардам по Telegram ID пользователя.
+// Обновления одного пользователя обрабатываются строго по порядку одним
+// шардом, обновления разных пользователей - параллельно.
+type Dispatcher struct {
+	cfg    Config
+	handle HandleFunc
+	shards []chan tgbotapi.Update
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex // защищает closed и отправку в закрываемые очереди
+	closed bool
+
+	enqueued  atomic.Uint64
+	processed atomic.Uint64
+	blocked   atomic.Uint64
+	dropped   atomic.Uint64
+	timedOut  atomic.Uint64
+}
+
+// New создаёт диспетчер и запускает шарды
+func New(cfg Config, handle HandleFunc) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 8
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 64
+	}
+	if cfg.HandleTimeout <= 0 {
+		cfg.HandleTimeout = 30 * time.Second
+	}
+
+	d := &Dispatcher{
+		cfg:    cfg,
+		handle: handle,
+		shards: make([]chan tgbotapi.Update, cfg.Workers),
+	}
+	for i := range d.shards {
+		d.shards[i] = make(chan tgbotapi.Update, cfg.QueueSize)
+		d.wg.Add(1)
+		go d.worker(d.shards[i])
+	}
+	return d
+}
+
+// Submit ставит обновление в очередь шарда его пользователя. Если очередь
+// заполнена, ждёт до EnqueueTimeout (обратное давление на транспорт).
+// Если обновление не принято, возвращается ошибка: транспорт не должен
+// подтверждать его Telegram, чтобы оно пришло повторно, а не потерялось.
+func (d *Dispatcher) Submit(ctx context.Context, update tgbotapi.Update) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrClosed
+	}
+
+	shard := d.shards[d.shardOf(update)]
+	select {
+	case shard <- update:
+		d.enqueued.Add(1)
+		return nil
+	default:
+	}
+
+	d.blocked.Add(1)
+	var timeout <-chan time.Time
+	if d.cfg.EnqueueTimeout > 0 {
+		timer := time.NewTimer(d.cfg.EnqueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case shard <- update:
+		d.enqueued.Add(1)
+		return nil
+	case <-timeout:
+		d.dropped.Add(1)
+		log.Printf("Очередь переполнена, обновление %d не принято", update.UpdateID)
+		return ErrQueueFull
+	case <-ctx.Done():
+		d.dropped.Add(1)
+		return ctx.Err()
+	}
+}
+
+// Shutdown перестаёт принимать обновления и ждёт, пока шарды обработают
+// уже принятые. Если ctx отменяется раньше, возвращает ошибку ctx.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, shard := range d.shards {
+			close(shard)
+		}
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats возвращает текущие счётчики
+func (d *Dispatcher) Stats() Stats {
+	s := Stats{
+		Enqueued:  d.enqueued.Load(),
+		Processed: d.processed.Load(),
+		Blocked:   d.blocked.Load(),
+		Dropped:   d.dropped.Load(),
+		TimedOut:  d.timedOut.Load(),
+		Queued:    make([]int, len(d.shards)),
+	}
+	for i, shard := range d.shards {
+		s.Queued[i] = len(shard)
+	}
+	return s
+}
+
+// LogStats периодически пишет счётчики в лог до отмены ctx
+func (d *Dispatcher) LogStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s := d.Stats()
+			log.Printf("Диспетчер: принято %d, обработано %d, ожидали очередь %d, отброшено %d, превысили дедлайн %d, очереди %v",
+				s.Enqueued, s.Processed, s.Blocked, s.Dropped, s.TimedOut, s.Queued)
+		}
+	}
+}
+
+func (d *Dispatcher) worker(queue <-chan tgbotapi.Update) {
+	defer d.wg.Done()
+	for update := range queue {
+		d.process(update)
+	}
+}
+
+func (d *Dispatcher) process(update tgbotapi.Update) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.HandleTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Паника при обработке обновления %d: %v", update.UpdateID, r)
+		}
+	}()
+
+	d.handle(ctx, update)
+	d.processed.Add(1)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		d.timedOut.Add(1)
+		log.Printf("Обработка обновления %d превысила %v", update.UpdateID, d.cfg.HandleTimeout)
+	}
+}
+
+// shardOf выбирает шард по Telegram ID отправителя. Обновления без
+// отправителя (например, служебные) распределяются по номеру обновления.
+func (d *Dispatcher) shardOf(update tgbotapi.Update) int {
+	key := int64(update.UpdateID)
+	if from := update.SentFrom(); from != nil {
+		key = from.ID
+	}
+	if key < 0 {
+		key = -key
+	}
+	return int(key % int64(len(d.shards)))
+}
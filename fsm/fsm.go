@@ -0,0 +1,242 @@
+// fsm/fsm.go
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"telegram_bot/database"
+	"telegram_bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// CancelText - текст универсального выхода из любого состояния
+const CancelText = "Отмена"
+
+// Input - вид сообщения, допустимого в состоянии
+type Input int
+
+const (
+	InputText Input = iota + 1
+	InputPhoto
+	InputDocument
+)
+
+// Hook вызывается при входе в состояние, выходе из него или из диалога
+type Hook func(ctx context.Context, telegramID int64) error
+
+// ChoicesFunc возвращает допустимые тексты состояния
+type ChoicesFunc func(ctx context.Context) ([]string, error)
+
+// State описывает одно состояние диалога
+type State struct {
+	Name        models.State
+	Prompt      string         // подсказка, если сообщение не подходит для состояния
+	Inputs      []Input        // допустимые виды сообщений (пусто - любые)
+	Choices     []string       // допустимые тексты (пусто - любой текст)
+	ChoicesFunc ChoicesFunc    // допустимые тексты, если их набор хранится в базе
+	Next        []models.State // разрешённые переходы, кроме выхода в StateNone
+	Timeout     time.Duration  // 0 - без ограничения времени
+	OnEnter     Hook
+	OnExit      Hook
+	OnLeave     Hook // выход из диалога в StateNone, после OnExit
+}
+
+// TransitionError - попытка недопустимого перехода
+type TransitionError struct {
+	From models.State
+	To   models.State
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("недопустимый переход из состояния %q в %q", e.From, e.To)
+}
+
+// ErrUnknownState возвращается для состояний, не объявленных в машине
+var ErrUnknownState = errors.New("неизвестное состояние")
+
+// Machine - конечный автомат многошаговых диалогов.
+// Текущее состояние хранится в users.state через DBInterface.
+type Machine struct {
+	db     database.DBInterface
+	states map[models.State]*State
+}
+
+// New создаёт пустой автомат
+func New(db database.DBInterface) *Machine {
+	return &Machine{
+		db:     db,
+		states: make(map[models.State]*State),
+	}
+}
+
+// Add объявляет состояние
+func (m *Machine) Add(s State) {
+	m.states[s.Name] = &s
+}
+
+// State возвращает описание состояния
+func (m *Machine) State(name models.State) (*State, bool) {
+	s, ok := m.states[name]
+	return s, ok
+}
+
+// Current возвращает текущее состояние пользователя
+func (m *Machine) Current(ctx context.Context, telegramID int64) (models.State, error) {
+	state, err := m.db.GetUserState(ctx, telegramID)
+	if err != nil {
+		return models.StateNone, err
+	}
+	return models.State(state), nil
+}
+
+// Transition переводит пользователя в состояние to. Из StateNone можно
+// войти в любое объявленное состояние, в StateNone можно выйти из любого,
+// остальные переходы должны быть перечислены в State.Next. Переход
+// в текущее состояние ничего не меняет.
+func (m *Machine) Transition(ctx context.Context, telegramID int64, to models.State) error {
+	from, err := m.Current(ctx, telegramID)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+
+	if to != models.StateNone {
+		if _, ok := m.states[to]; !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownState, to)
+		}
+	}
+	if !m.allowed(from, to) {
+		return &TransitionError{From: from, To: to}
+	}
+
+	return m.move(ctx, telegramID, from, to)
+}
+
+// Finish завершает диалог, возвращая пользователя в StateNone
+func (m *Machine) Finish(ctx context.Context, telegramID int64) error {
+	return m.Transition(ctx, telegramID, models.StateNone)
+}
+
+// Cancel сбрасывает состояние пользователя независимо от текущего
+func (m *Machine) Cancel(ctx context.Context, telegramID int64) error {
+	from, err := m.Current(ctx, telegramID)
+	if err != nil {
+		return err
+	}
+	return m.move(ctx, telegramID, from, models.StateNone)
+}
+
+// Accepts проверяет, подходит ли сообщение для состояния.
+// Возвращает подсказку для пользователя, если не подходит.
+func (m *Machine) Accepts(ctx context.Context, name models.State, msg *tgbotapi.Message) (string, bool) {
+	s, ok := m.states[name]
+	if !ok || msg == nil {
+		return "", true
+	}
+
+	if len(s.Inputs) > 0 && !hasInput(s.Inputs, inputOf(msg)) {
+		return s.prompt(), false
+	}
+	if len(s.Choices) > 0 && !contains(s.Choices, msg.Text) {
+		return s.prompt(), false
+	}
+	if s.ChoicesFunc != nil {
+		// При ошибке сообщение пропускается: обработчик состояния проверит его сам
+		choices, err := s.ChoicesFunc(ctx)
+		if err == nil && !contains(choices, msg.Text) {
+			return s.prompt(), false
+		}
+	}
+	return "", true
+}
+
+// Expired сообщает, истекло ли время ожидания в текущем состоянии
+func (m *Machine) Expired(ctx context.Context, telegramID int64, name models.State) (bool, error) {
+	s, ok := m.states[name]
+	if !ok || s.Timeout == 0 {
+		return false, nil
+	}
+
+	changedAt, err := m.db.GetUserStateChangedAt(ctx, telegramID)
+	if err != nil {
+		return false, err
+	}
+	return time.Since(changedAt) > s.Timeout, nil
+}
+
+func (m *Machine) allowed(from, to models.State) bool {
+	if from == models.StateNone || to == models.StateNone {
+		return true
+	}
+	s, ok := m.states[from]
+	if !ok {
+		return false
+	}
+	return contains(s.Next, to)
+}
+
+func (m *Machine) move(ctx context.Context, telegramID int64, from, to models.State) error {
+	if s, ok := m.states[from]; ok && s.OnExit != nil {
+		if err := s.OnExit(ctx, telegramID); err != nil {
+			return fmt.Errorf("выход из состояния %q: %w", from, err)
+		}
+	}
+	if s, ok := m.states[from]; ok && to == models.StateNone && s.OnLeave != nil {
+		if err := s.OnLeave(ctx, telegramID); err != nil {
+			return fmt.Errorf("выход из диалога в состоянии %q: %w", from, err)
+		}
+	}
+
+	if err := m.db.SetUserState(ctx, telegramID, string(to)); err != nil {
+		return err
+	}
+
+	if s, ok := m.states[to]; ok && s.OnEnter != nil {
+		if err := s.OnEnter(ctx, telegramID); err != nil {
+			return fmt.Errorf("вход в состояние %q: %w", to, err)
+		}
+	}
+	return nil
+}
+
+func (s *State) prompt() string {
+	if s.Prompt != "" {
+		return s.Prompt
+	}
+	return "Сейчас ожидается другой ответ. Нажмите «" + CancelText + "», чтобы выйти."
+}
+
+func inputOf(msg *tgbotapi.Message) Input {
+	switch {
+	case len(msg.Photo) > 0:
+		return InputPhoto
+	case msg.Document != nil:
+		return InputDocument
+	default:
+		return InputText
+	}
+}
+
+func hasInput(inputs []Input, in Input) bool {
+	for _, i := range inputs {
+		if i == in {
+			return true
+		}
+	}
+	return false
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
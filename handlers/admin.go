@@ -3,187 +3,443 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/fsm"
 	"telegram_bot/models"
+	"telegram_bot/taskimport"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// Действия кнопок предпросмотра нового задания. ID в ActionDraftEdit - поле
+// черновика, 0 - показать список полей.
+const (
+	ActionDraftConfirm = "draftok"
+	ActionDraftEdit    = "draftedit"
+	ActionDraftCancel  = "draftcancel"
+)
+
+// Поля черновика задания, которые можно изменить из предпросмотра
+const (
+	draftFieldLink int64 = iota + 1
+	draftFieldDescription
+	draftFieldExample
+	draftFieldReward
+)
+
+// tempTaskDraft - ключ временных данных с черновиком задания
+const tempTaskDraft = "task_draft"
+
+// skipText - кнопка пропуска необязательного шага мастера
+const skipText = "Пропустить"
+
+// draftFieldStates - состояние мастера для правки поля черновика
+var draftFieldStates = map[int64]models.State{
+	draftFieldLink:        models.StateAwaitingTaskLink,
+	draftFieldDescription: models.StateAwaitingTaskDescription,
+	draftFieldExample:     models.StateAwaitingTaskExample,
+	draftFieldReward:      models.StateAwaitingTaskReward,
+}
+
+// registerTaskDraftCallbacks регистрирует кнопки предпросмотра нового задания
+func (h *Handler) registerTaskDraftCallbacks(d *callback.Dispatcher) {
+	d.Register(ActionDraftConfirm, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleDraftConfirm,
+	})
+	d.Register(ActionDraftEdit, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleDraftEdit,
+	})
+	d.Register(ActionDraftCancel, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleDraftCancel,
+	})
+}
+
 func (h *Handler) HandleAdminAddTask(ctx context.Context, update tgbotapi.Update) {
-	// Предложение выбрать тип задания
-	userID := update.Message.From.ID
-	msgKeyboard := h.AdminMenuTask
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Выберите тип задания для добавления:")
-	msg.ReplyMarkup = msgKeyboard
-	h.Bot.Send(msg)
+	chatID := update.Message.Chat.ID
+	keyboard, n, err := h.categoryKeyboard(ctx, update.Message.From.LanguageCode)
+	if err != nil {
+		log.Printf("Ошибка при получении категорий: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить список категорий."))
+		return
+	}
+	if n == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Нет активных категорий. Добавьте категорию в разделе «Категории»."))
+		return
+	}
 
 	// Установка состояния администратора
-	err := h.DB.SetUserState(ctx, userID, string(models.StateAwaitingTaskCategory))
-	if err != nil {
-		log.Printf("Ошибка при установке состояния: %v", err)
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при установке состояния.")
-		h.Bot.Send(msg)
+	userID := update.Message.From.ID
+	if !h.transition(ctx, chatID, userID, models.StateAwaitingTaskCategory) {
+		return
 	}
+	h.clearTempData(tempTaskDraft)(ctx, userID)
+
+	// Предложение выбрать тип задания
+	msg := tgbotapi.NewMessage(chatID, "Выберите тип задания для добавления:")
+	msg.ReplyMarkup = keyboard
+	h.Bot.Send(msg)
 }
 
 func (h *Handler) HandleAdminTaskCategorySelection(ctx context.Context, update tgbotapi.Update) {
-	categoryText := update.Message.Text
+	chatID := update.Message.Chat.ID
 	adminID := update.Message.From.ID
 
-	if categoryText == "Отменить добавление" {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Добавление задания отменено.")
-		msg.ReplyMarkup = h.AdminMenuTask
+	// Валидация выбранной категории
+	category, err := h.findCategory(ctx, update.Message.Text)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "Неверная категория. Пожалуйста, выберите одну из доступных.")
+		if keyboard, _, err := h.categoryKeyboard(ctx, update.Message.From.LanguageCode); err == nil {
+			msg.ReplyMarkup = keyboard
+		}
 		h.Bot.Send(msg)
-		h.DB.SetUserState(ctx, adminID, string(models.StateNone))
 		return
 	}
 
-	// Валидация выбранной категории
-	var selectedCategory models.Category
-	switch categoryText {
-	case string(models.CategoryAvito):
-		selectedCategory = models.CategoryAvito
-	case string(models.CategoryYandex):
-		selectedCategory = models.CategoryYandex
-	case string(models.CategoryGoogle):
-		selectedCategory = models.CategoryGoogle
-	case string(models.Category2GIS):
-		selectedCategory = models.Category2GIS
-	default:
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Неверная категория. Пожалуйста, выберите одну из доступных.")
-		msg.ReplyMarkup = h.AdminMenuTask // Или клавиатура с категориями
-		h.Bot.Send(msg)
+	// Новый черновик: вознаграждение по умолчанию берётся из категории,
+	// шаги - из шаблона категории по умолчанию
+	maxCompletions := 1
+	draft := &models.TaskDraft{
+		Category:       category.Slug,
+		Reward:         category.DefaultReward,
+		MaxCompletions: &maxCompletions,
+		PerUserLimit:   1,
+	}
+	if template, err := h.DB.GetDefaultTemplate(ctx, category.Slug); err == nil {
+		draft.TemplateID = &template.ID
+	} else if !errors.Is(err, database.ErrTemplateNotFound) {
+		log.Printf("Ошибка при получении шаблона категории %s: %v", category.Slug, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка при получении шаблона задания."))
 		return
 	}
-	// Сохранение выбранной категории
-	err := h.DB.SetTempData(ctx, update.Message.From.ID, "new_task_category", selectedCategory)
-	if err != nil {
-		// Обработка ошибки
-		log.Printf("Ошибка при сохранении временных данных: %v", err)
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при сохранении категории задания.")
-		h.Bot.Send(msg)
+
+	h.nextDraftStep(ctx, chatID, adminID, draft, models.StateAwaitingTaskLink)
+}
+
+// HandleAdminTaskLink принимает ссылку на объявление или карточку площадки
+func (h *Handler) HandleAdminTaskLink(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+	draft, ok := h.loadTaskDraft(ctx, chatID, adminID)
+	if !ok {
 		return
 	}
 
-	// Запрос описания задания
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Введите описание задания:")
-	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
-	h.Bot.Send(msg)
+	category, err := h.DB.GetCategoryBySlug(ctx, draft.Category)
+	if err != nil {
+		log.Printf("Ошибка при получении категории %s: %v", draft.Category, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка при получении категории задания."))
+		return
+	}
+	link, err := taskimport.ValidateLink(update.Message.Text, category)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+". Отправьте ссылку ещё раз."))
+		return
+	}
 
-	// Обновление состояния администратора
-	h.DB.SetUserState(ctx, update.Message.From.ID, string(models.StateAwaitingTaskDescription))
+	draft.Link = link
+	h.nextDraftStep(ctx, chatID, adminID, draft, models.StateAwaitingTaskDescription)
 }
 
 func (h *Handler) HandleAdminTaskDescription(ctx context.Context, update tgbotapi.Update) {
-	description := update.Message.Text
+	chatID := update.Message.Chat.ID
 	adminID := update.Message.From.ID
+	draft, ok := h.loadTaskDraft(ctx, chatID, adminID)
+	if !ok {
+		return
+	}
 
-	// Получение сохраненной категории
-	tempData, err := h.DB.GetTempData(ctx, adminID, "new_task_category")
-	if err != nil {
-		log.Printf("Ошибка при получении временных данных: %v", err)
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при получении категории задания.")
-		h.Bot.Send(msg)
+	description := strings.TrimSpace(update.Message.Text)
+	if description == "" || len([]rune(description)) > taskimport.MaxDescription {
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Описание должно быть непустым и не длиннее %d символов.", taskimport.MaxDescription)))
 		return
 	}
 
-	// Приведение типа interface{} к string
-	selectedCategory, ok := tempData.(models.Category)
+	draft.Description = description
+	h.nextDraftStep(ctx, chatID, adminID, draft, models.StateAwaitingTaskExample)
+}
+
+// HandleAdminTaskExample принимает пример выполнения или пропуск шага
+func (h *Handler) HandleAdminTaskExample(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+	draft, ok := h.loadTaskDraft(ctx, chatID, adminID)
 	if !ok {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Ошибка: Некорректный тип категории.")
-		if _, err := h.Bot.Send(msg); err != nil {
-			log.Printf("Ошибка при отправке сообщения: %v", err)
-		}
 		return
 	}
 
-	// Создание задания в базе данных
-	task := models.Task{
-		Category:         selectedCategory,
-		Description:      description,
-		Link:             "https://example.com",
-		IsActive:         true,
-		CreatedAt:        time.Now(),
-		Status:           "New",
-		ScreenshotFileID: "file_id_12345",
+	switch {
+	case len(update.Message.Photo) > 0:
+		// FileID самого большого размера фотографии
+		draft.ExampleFileID = update.Message.Photo[len(update.Message.Photo)-1].FileID
+	case update.Message.Text == skipText:
+		draft.ExampleFileID = ""
+	default:
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Пришлите скриншот или нажмите «"+skipText+"»."))
+		return
 	}
 
-	// Сохранение задания в базе данных
-	err = h.DB.CreateTask(ctx, &task)
-	if err != nil {
-		log.Printf("Ошибка при создании задачи: %v", err)
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при создании задачи.")
-		h.Bot.Send(msg)
+	h.nextDraftStep(ctx, chatID, adminID, draft, models.StateAwaitingTaskReward)
+}
+
+// HandleAdminTaskReward принимает вознаграждение и лимиты в формате /task_limits
+func (h *Handler) HandleAdminTaskReward(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+	draft, ok := h.loadTaskDraft(ctx, chatID, adminID)
+	if !ok {
 		return
 	}
 
-	// Уведомление об успешном добавлении задания
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Задание успешно добавлено!")
-	msg.ReplyMarkup = h.AdminMenuTask
-	if _, err := h.Bot.Send(msg); err != nil {
-		log.Printf("Ошибка при отправке сообщения: %v", err)
+	if update.Message.Text != skipText {
+		task := draft.Task()
+		if err := applyTaskLimits(task, strings.Fields(update.Message.Text)); err != nil {
+			h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+".\n"+draftRewardPrompt(draft)))
+			return
+		}
+		draft.SetLimits(task)
 	}
 
-	// Сброс состояния и временных данных
-	if err := h.DB.SetUserState(ctx, adminID, ""); err != nil {
-		log.Printf("Ошибка при сбросе состояния пользователя: %v", err)
-	}
-	if err := h.DB.DeleteTempData(ctx, adminID, "new_task_category"); err != nil {
-		log.Printf("Ошибка при удалении временных данных: %v", err)
+	h.nextDraftStep(ctx, chatID, adminID, draft, models.StateAwaitingTaskConfirm)
+}
+
+// HandleAdminTaskConfirm напоминает о кнопках предпросмотра
+func (h *Handler) HandleAdminTaskConfirm(ctx context.Context, update tgbotapi.Update) {
+	draft, ok := h.loadTaskDraft(ctx, update.Message.Chat.ID, update.Message.From.ID)
+	if !ok {
+		return
 	}
+	h.sendDraftPreview(ctx, update.Message.Chat.ID, draft)
 }
 
-func (h *Handler) HandleAdminCheckTasks(ctx context.Context, update tgbotapi.Update) {
-	tasks, err := h.DB.GetPendingTasks(ctx) // Задания со статусом "Pending"
-	if err != nil {
-		h.Bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при получении заданий для проверки."))
+// nextDraftStep сохраняет черновик и переходит к шагу to. После правки
+// поля из предпросмотра мастер возвращается к предпросмотру.
+func (h *Handler) nextDraftStep(ctx context.Context, chatID, adminID int64, draft *models.TaskDraft, to models.State) {
+	if draft.Editing {
+		draft.Editing = false
+		to = models.StateAwaitingTaskConfirm
+	}
+	if err := h.DB.SetTempValue(ctx, adminID, tempTaskDraft, draft); err != nil {
+		log.Printf("Ошибка при сохранении черновика задания: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка при сохранении черновика задания."))
 		return
 	}
+	if !h.transition(ctx, chatID, adminID, to) {
+		return
+	}
+	h.askDraftStep(ctx, chatID, draft, to)
+}
 
-	if len(tasks) == 0 {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Нет заданий для проверки.")
-		h.Bot.Send(msg)
+// askDraftStep отправляет вопрос шага мастера
+func (h *Handler) askDraftStep(ctx context.Context, chatID int64, draft *models.TaskDraft, state models.State) {
+	var msg tgbotapi.MessageConfig
+	switch state {
+	case models.StateAwaitingTaskLink:
+		text := "Отправьте ссылку на объявление или карточку организации."
+		if category, err := h.DB.GetCategoryBySlug(ctx, draft.Category); err == nil && len(category.Domains) > 0 {
+			text += "\nДопустимые домены: " + strings.Join(category.Domains, ", ") + "."
+		}
+		msg = tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = cancelKeyboard()
+	case models.StateAwaitingTaskDescription:
+		msg = tgbotapi.NewMessage(chatID, "Введите описание задания:")
+		msg.ReplyMarkup = cancelKeyboard()
+	case models.StateAwaitingTaskExample:
+		msg = tgbotapi.NewMessage(chatID,
+			"Пришлите пример выполнения - скриншот, который увидит исполнитель, или нажмите «"+skipText+"».")
+		msg.ReplyMarkup = skipKeyboard()
+	case models.StateAwaitingTaskReward:
+		msg = tgbotapi.NewMessage(chatID, draftRewardPrompt(draft))
+		msg.ReplyMarkup = skipKeyboard()
+	default:
+		h.sendDraftPreview(ctx, chatID, draft)
 		return
 	}
+	h.Bot.Send(msg)
+}
+
+// sendDraftPreview показывает черновик с кнопками подтверждения, правки и отмены
+func (h *Handler) sendDraftPreview(ctx context.Context, chatID int64, draft *models.TaskDraft) {
+	if draft.ExampleFileID != "" {
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(draft.ExampleFileID))
+		photo.Caption = "Пример выполнения"
+		h.Bot.Send(photo)
+	}
+	msg := tgbotapi.NewMessage(chatID, h.draftPreviewText(ctx, draft))
+	msg.ReplyMarkup = h.draftPreviewButtons()
+	h.Bot.Send(msg)
+}
 
-	for _, task := range tasks {
-		// Формирование информации о задании
-		taskInfo := fmt.Sprintf(
-			"👤 *Пользователь ID:* %d\n"+
-				"📂 *Категория:* %s\n"+
-				"📄 *Задание:* %s\n"+
-				"📝 *Описание:* %s\n"+
-				"🔗 *Ссылка:* %s\n"+
-				"📅 *Создано:* %s\n",
-			task.UserID,
-			task.Category,
-			task.ID, // Если ID задания необходимо отображать
-			task.Description,
-			task.Link,
-			task.CreatedAt.Format("2006-01-02 15:04:05"),
-		)
-		// Создание кнопок для одобрения и отклонения
-		approveButton := tgbotapi.NewInlineKeyboardButtonData("✅ Одобрить", fmt.Sprintf("approve_%d", task.ID))
-		rejectButton := tgbotapi.NewInlineKeyboardButtonData("❌ Отклонить", fmt.Sprintf("reject_%d", task.ID))
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(approveButton, rejectButton),
-		)
-
-		// Создание сообщения с фотографией
-		photoMsg := tgbotapi.NewPhoto(
-			update.Message.Chat.ID,
-			tgbotapi.FileID(task.ScreenshotFileID), // Используем file_id для отправки фото
-		)
-		photoMsg.Caption = taskInfo
-		photoMsg.ParseMode = "Markdown"
-		photoMsg.ReplyMarkup = keyboard
-
-		// Отправка сообщения
-		if _, err := h.Bot.Send(photoMsg); err != nil {
-			// Логирование ошибки, если отправка не удалась
-			fmt.Printf("Ошибка при отправке фото для задания ID %d: %v\n", task.ID, err)
+func (h *Handler) draftPreviewText(ctx context.Context, draft *models.TaskDraft) string {
+	template := "шаблон категории по умолчанию"
+	if draft.TemplateID != nil {
+		if t, err := h.DB.GetTemplate(ctx, *draft.TemplateID); err == nil {
+			template = fmt.Sprintf("%s (шагов: %d)", t.Name, len(t.Steps))
 		}
 	}
+	example := "нет"
+	if draft.ExampleFileID != "" {
+		example = "есть"
+	}
+	task := draft.Task()
+	return fmt.Sprintf("Предпросмотр задания\n\nКатегория: %s\nСсылка: %s\nШаблон: %s\nПример выполнения: %s\n%s\n\n%s",
+		h.categoryName(ctx, draft.Category), draft.Link, template, example, taskLimitsText(task), draft.Description)
+}
+
+func (h *Handler) draftPreviewButtons() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		h.Codec.Button("✅ Опубликовать", ActionDraftConfirm, 0),
+		h.Codec.Button("✏️ Изменить", ActionDraftEdit, 0),
+		h.Codec.Button("❌ Отменить", ActionDraftCancel, 0),
+	))
+}
+
+func (h *Handler) draftFieldButtons() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("Ссылка", ActionDraftEdit, draftFieldLink),
+			h.Codec.Button("Описание", ActionDraftEdit, draftFieldDescription),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("Пример", ActionDraftEdit, draftFieldExample),
+			h.Codec.Button("Награда и лимиты", ActionDraftEdit, draftFieldReward),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("✅ Опубликовать", ActionDraftConfirm, 0),
+			h.Codec.Button("❌ Отменить", ActionDraftCancel, 0),
+		),
+	)
+}
+
+// loadTaskDraft читает черновик администратора. Если черновика нет,
+// мастер завершается.
+func (h *Handler) loadTaskDraft(ctx context.Context, chatID, adminID int64) (*models.TaskDraft, bool) {
+	draft := &models.TaskDraft{}
+	err := h.DB.GetTempValue(ctx, adminID, tempTaskDraft, draft)
+	if err == nil {
+		return draft, true
+	}
+	if !errors.Is(err, database.ErrTempDataNotFound) {
+		log.Printf("Ошибка при получении черновика задания: %v", err)
+	}
+	if err := h.FSM.Cancel(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, "Черновик задания не найден. Начните добавление заново.")
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+	return nil, false
+}
+
+// inDraftPreview сообщает, находится ли администратор на предпросмотре
+// черновика. Кнопки старых предпросмотров не действуют.
+func (h *Handler) inDraftPreview(ctx context.Context, adminID int64) (bool, error) {
+	state, err := h.FSM.Current(ctx, adminID)
+	if err != nil {
+		return false, err
+	}
+	return state == models.StateAwaitingTaskConfirm, nil
+}
+
+func (h *Handler) handleDraftConfirm(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	adminID := q.From.ID
+	if ok, err := h.inDraftPreview(ctx, adminID); err != nil || !ok {
+		return "Черновик уже опубликован или отменён.", err
+	}
+	draft, ok := h.loadTaskDraft(ctx, q.Message.Chat.ID, adminID)
+	if !ok {
+		return "", nil
+	}
+
+	task := draft.Task()
+	task.CreatedAt = time.Now()
+	if err := h.DB.CreateTask(ctx, task); err != nil {
+		return "", fmt.Errorf("ошибка при создании задания: %w", err)
+	}
+	log.Printf("Администратор %d добавил задание %d категории %s", adminID, task.ID, task.Category)
+
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	h.clearTempData(tempTaskDraft)(ctx, adminID)
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+
+	msg := tgbotapi.NewMessage(q.Message.Chat.ID, fmt.Sprintf("Задание #%d опубликовано!\n\n%s", task.ID, taskLimitsText(task)))
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+	return "", nil
+}
+
+func (h *Handler) handleDraftEdit(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	adminID := q.From.ID
+	if ok, err := h.inDraftPreview(ctx, adminID); err != nil || !ok {
+		return "Черновик уже опубликован или отменён.", err
+	}
+
+	if d.ID == 0 {
+		markup := h.draftFieldButtons()
+		h.Bot.Send(tgbotapi.NewEditMessageReplyMarkup(q.Message.Chat.ID, q.Message.MessageID, markup))
+		return "Выберите, что изменить.", nil
+	}
+	state, ok := draftFieldStates[d.ID]
+	if !ok {
+		return "Неизвестное поле.", nil
+	}
+
+	draft, ok := h.loadTaskDraft(ctx, q.Message.Chat.ID, adminID)
+	if !ok {
+		return "", nil
+	}
+	draft.Editing = true
+	if err := h.DB.SetTempValue(ctx, adminID, tempTaskDraft, draft); err != nil {
+		return "", err
+	}
+	if !h.transition(ctx, q.Message.Chat.ID, adminID, state) {
+		return "", nil
+	}
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	h.askDraftStep(ctx, q.Message.Chat.ID, draft, state)
+	return "", nil
+}
+
+func (h *Handler) handleDraftCancel(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	adminID := q.From.ID
+	if ok, err := h.inDraftPreview(ctx, adminID); err != nil || !ok {
+		return "Черновик уже опубликован или отменён.", err
+	}
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		return "", err
+	}
+	h.clearTempData(tempTaskDraft)(ctx, adminID)
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+
+	msg := tgbotapi.NewMessage(q.Message.Chat.ID, "Добавление задания отменено.")
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+	return "", nil
+}
+
+func draftRewardPrompt(draft *models.TaskDraft) string {
+	return "Сейчас:\n" + taskLimitsText(draft.Task()) + "\n\n" +
+		"Чтобы изменить, отправьте параметры, например: reward=40 quota=100 budget=5000 per_user=1\n" +
+		"quota=- и budget=- снимают ограничение. Чтобы оставить как есть, нажмите «" + skipText + "»."
+}
+
+// skipKeyboard - клавиатура необязательного шага
+func skipKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(skipText),
+			tgbotapi.NewKeyboardButton(fsm.CancelText),
+		),
+	)
 }
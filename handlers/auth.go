@@ -6,42 +6,125 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
+
+	"telegram_bot/callback"
+	"telegram_bot/config"
 	"telegram_bot/database"
+	"telegram_bot/fsm"
+	"telegram_bot/ledger"
 	"telegram_bot/models"
+	"telegram_bot/payout"
+	"telegram_bot/referral"
+	"telegram_bot/scheduler"
+	"telegram_bot/taskimport"
+	"telegram_bot/triage"
+	"telegram_bot/withdrawal"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type Handler struct {
-	Bot           *tgbotapi.BotAPI
-	DB            database.DBInterface
-	AdminMenu     tgbotapi.ReplyKeyboardMarkup
-	Keyboard      tgbotapi.ReplyKeyboardMarkup
-	AdminMenuTask tgbotapi.ReplyKeyboardMarkup
-	KeyboardTask  tgbotapi.ReplyKeyboardMarkup
+	Bot         *tgbotapi.BotAPI
+	DB          database.DBInterface
+	AdminMenu   tgbotapi.ReplyKeyboardMarkup
+	Keyboard    tgbotapi.ReplyKeyboardMarkup
+	FSM         *fsm.Machine
+	Codec       *callback.Codec
+	Callbacks   *callback.Dispatcher
+	Scheduler   *scheduler.Scheduler
+	Ledger      *ledger.Ledger
+	Withdrawals *withdrawal.Service
+	Payouts     *payout.Store
+	Referrals   *referral.Program
+	TaskImport  *taskimport.Importer
+	Triage      *triage.Engine
+
+	// ModerationLease - на сколько выполнение закрепляется за модератором
+	ModerationLease time.Duration
+	// PHashDistance - до скольких различающихся битов скриншоты считаются похожими
+	PHashDistance int
 }
 
 // Конструктор для Handler
-func NewHandler(bot *tgbotapi.BotAPI, db database.DBInterface, admins map[int64]bool) *Handler {
-	// Инициализация админского меню задач
-	adminMenuTask := tgbotapi.NewReplyKeyboard(
+func NewHandler(bot *tgbotapi.BotAPI, db database.DBInterface, cfg *config.Config) (*Handler, error) {
+	vault, err := payout.NewVault(cfg.PayoutVaultKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Меню пользователя
+	userMenu := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Показать баланс"),
+			tgbotapi.NewKeyboardButton("Личный кабинет"),
+		),
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton(string(models.CategoryAvito)),
-			tgbotapi.NewKeyboardButton(string(models.CategoryYandex)),
+			tgbotapi.NewKeyboardButton("Вывести средства"),
+			tgbotapi.NewKeyboardButton("Мои выводы"),
 		),
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton(string(models.CategoryGoogle)),
-			tgbotapi.NewKeyboardButton(string(models.Category2GIS)),
+			tgbotapi.NewKeyboardButton("Взять задание"),
+			tgbotapi.NewKeyboardButton("История операций"),
 		),
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Отменить добавление"),
+			tgbotapi.NewKeyboardButton("Мои рефералы"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Мои реквизиты"),
+			tgbotapi.NewKeyboardButton("Обратиться в техподдержку"),
 		),
 	)
-	return &Handler{
-		Bot:           bot,
-		DB:            db,
-		AdminMenuTask: adminMenuTask,
+
+	// Меню администратора
+	adminMenu := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Добавить задание"),
+			tgbotapi.NewKeyboardButton("Проверить задания"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Заявки на вывод"),
+			tgbotapi.NewKeyboardButton("Реферальные уровни"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Категории"),
+			tgbotapi.NewKeyboardButton("Шаблоны заданий"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Причины отклонения"),
+			tgbotapi.NewKeyboardButton("Автопроверка"),
+		),
+	)
+
+	h := &Handler{
+		Bot:        bot,
+		DB:         db,
+		AdminMenu:  adminMenu,
+		Keyboard:   userMenu,
+		Codec:      callback.NewCodec([]byte(cfg.CallbackSecret)),
+		Scheduler:  scheduler.New(db, scheduler.Options{}),
+		Ledger:     ledger.New(db),
+		Payouts:    payout.NewStore(db, vault),
+		TaskImport: taskimport.New(db),
+		Triage:     triage.New(db, cfg.PHashDistance),
+
+		ModerationLease: cfg.ModerationLease,
+		PHashDistance:   cfg.PHashDistance,
 	}
+	h.Referrals = referral.New(db, h.Ledger, referral.Config{
+		Bonus:       cfg.Referral.Bonus,
+		PercentBP:   cfg.Referral.PercentBP,
+		PercentDays: cfg.Referral.PercentDays,
+		MaxLevels:   cfg.Referral.MaxLevels,
+	})
+	h.Withdrawals = withdrawal.New(db, h.Ledger, withdrawal.Limits{
+		Min: cfg.Withdrawal.Min,
+		Max: cfg.Withdrawal.Max,
+	})
+	h.FSM = h.newStateMachine()
+	h.Callbacks = h.newCallbackDispatcher()
+	h.registerJobs()
+	return h, nil
 }
 
 func (h *Handler) Start(ctx context.Context, update tgbotapi.Update) {
@@ -55,13 +138,16 @@ func (h *Handler) Start(ctx context.Context, update tgbotapi.Update) {
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Пользователь не найден, добавляем его в базу данных
-			_, err = h.DB.ExecContext(ctx, "INSERT INTO users (telegram_id, username, admin) VALUES ($1, $2, $3)", telegramUser.ID, telegramUser.UserName, false)
+			err = h.DB.QueryRowContext(ctx, "INSERT INTO users (telegram_id, username, admin) VALUES ($1, $2, $3) RETURNING id", telegramUser.ID, telegramUser.UserName, false).
+				Scan(&user.ID)
 			if err != nil {
 				log.Println("Ошибка при добавлении пользователя:", err)
 				msg := tgbotapi.NewMessage(chatID, "Произошла ошибка при регистрации. Пожалуйста, попробуйте позже.")
 				h.Bot.Send(msg)
 				return
 			}
+			// Реферальная ссылка учитывается только при первом /start
+			h.attributeReferral(ctx, user.ID, update.Message.CommandArguments())
 			// Устанавливаем статус администратора в false
 			user.Admin = false
 		} else {
@@ -73,40 +159,34 @@ func (h *Handler) Start(ctx context.Context, update tgbotapi.Update) {
 		}
 	}
 
-	// Создание клавиатуры
-	var UserMenu = tgbotapi.NewReplyKeyboard(
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Показать баланс"),
-			tgbotapi.NewKeyboardButton("Личный кабинет"),
-		),
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Вывести средства"),
-			tgbotapi.NewKeyboardButton("Обратиться в техподдержку"),
-		),
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Взять задание"),
-		),
-	)
-
-	AdminMenu := tgbotapi.NewReplyKeyboard(
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Добавить задание"),
-			tgbotapi.NewKeyboardButton("Проверить задания"),
-		),
-	)
 	var msg tgbotapi.MessageConfig
 
 	if user.Admin {
 		msg = tgbotapi.NewMessage(chatID, "Добро пожаловать, администратор!")
-		msg.ReplyMarkup = AdminMenu
+		msg.ReplyMarkup = h.AdminMenu
 	} else {
 		msg = tgbotapi.NewMessage(chatID, "Добро пожаловать! Что вы хотите сделать?")
-		msg.ReplyMarkup = UserMenu
+		msg.ReplyMarkup = h.Keyboard
 	}
 
 	h.Bot.Send(msg)
 }
 
+// attributeReferral привязывает нового пользователя к владельцу реферальной ссылки
+func (h *Handler) attributeReferral(ctx context.Context, userID int, payload string) {
+	referrerTelegramID, ok := referral.ParsePayload(payload)
+	if !ok {
+		return
+	}
+
+	referrerID, err := h.Referrals.Attribute(ctx, userID, referrerTelegramID)
+	if err != nil {
+		log.Printf("Реферальная ссылка %q пользователя %d не учтена: %v", payload, userID, err)
+		return
+	}
+	h.notifyUser(ctx, referrerID, "🎉 По вашей реферальной ссылке зарегистрировался новый пользователь!")
+}
+
 func (h *Handler) HandleSupport(ctx context.Context, update tgbotapi.Update) {
 	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Свяжитесь с нашей службой поддержки по адресу @support.")
 	h.Bot.Send(msg)
@@ -124,7 +204,7 @@ func (h *Handler) HandleShowAccount(ctx context.Context, update tgbotapi.Update)
 	}
 
 	// Формирование реферальной ссылки
-	referralLink := fmt.Sprintf("https://t.me/Co_Work_online_bot?start=%d", userID)
+	referralLink := referral.Link(h.Bot.Self.UserName, userID)
 
 	// Получение статистики рефералов
 	referralCount, err := h.DB.GetUserReferralCount(ctx, userID)
@@ -132,17 +212,17 @@ func (h *Handler) HandleShowAccount(ctx context.Context, update tgbotapi.Update)
 		referralCount = 0
 	}
 
-	// Получение количества выполненных заданий
-	completedTasks, err := h.DB.GetCompletedTasksCount(ctx, userID)
-	if err != nil {
-		completedTasks = 0
+	// Получение количества одобренных выполнений
+	completedTasks := 0
+	if stats, err := h.DB.GetExecutorStats(ctx, user.ID); err == nil {
+		completedTasks = stats.Approved
 	}
 
 	// Формирование сообщения
 	accountInfo := fmt.Sprintf(
 		"📋 *Личный кабинет*\n\n"+
 			"🆔 *Ваш ID:* %d\n"+
-			"💰 *Заработано денег:* %.2f руб.\n"+
+			"💰 *Заработано денег:* %s\n"+
 			"✅ *Выполнено заданий:* %d\n"+
 			"🔗 *Ваша реферальная ссылка:*\n%s\n"+
 			"👥 *Приглашено рефералов:* %d",
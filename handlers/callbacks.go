@@ -0,0 +1,299 @@
+// handlers/callbacks.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/ledger"
+	"telegram_bot/models"
+	"telegram_bot/referral"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действия inline-кнопок. ID в данных кнопки - это user_tasks.id.
+const (
+	ActionStartTask = "starttask"
+	ActionNextStage = "nextstage"
+	ActionResume    = "resume"
+	ActionApprove   = "approve"
+	ActionReject    = "reject"
+)
+
+// newCallbackDispatcher регистрирует обработчики inline-кнопок
+func (h *Handler) newCallbackDispatcher() *callback.Dispatcher {
+	d := callback.NewDispatcher(h.Codec, h.Bot)
+
+	d.Register(ActionStartTask, callback.Action{
+		Authorize: h.authorizeTaskOwner,
+		Handle:    h.handleStartTask,
+	})
+	d.Register(ActionNextStage, callback.Action{
+		Authorize: h.authorizeTaskOwner,
+		Handle:    h.handleNextStage,
+	})
+	d.Register(ActionResume, callback.Action{
+		Authorize: h.authorizeTaskOwner,
+		Handle:    h.handleResumeStage,
+	})
+	d.Register(ActionApprove, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleApprove,
+	})
+	d.Register(ActionReject, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleReject,
+	})
+	h.registerWithdrawalCallbacks(d)
+	h.registerPayoutCallbacks(d)
+	h.registerCategoryCallbacks(d)
+	h.registerTemplateCallbacks(d)
+	h.registerTaskDraftCallbacks(d)
+	h.registerRejectionCallbacks(d)
+	h.registerModerationCallbacks(d)
+	h.registerTriageCallbacks(d)
+
+	return d
+}
+
+// HandleCallback обрабатывает нажатие inline-кнопки
+func (h *Handler) HandleCallback(ctx context.Context, update tgbotapi.Update) {
+	h.Callbacks.Dispatch(ctx, update.CallbackQuery)
+}
+
+// authorizeAdmin разрешает действие только администраторам
+func (h *Handler) authorizeAdmin(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) error {
+	user, err := h.DB.GetUserByTelegramID(ctx, q.From.ID)
+	if err != nil {
+		return err
+	}
+	if !user.Admin {
+		return callback.ErrForbidden
+	}
+	return nil
+}
+
+// authorizeTaskOwner разрешает действие только исполнителю задания
+func (h *Handler) authorizeTaskOwner(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) error {
+	user, err := h.DB.GetUserByTelegramID(ctx, q.From.ID)
+	if err != nil {
+		return err
+	}
+	userTask, err := h.DB.GetUserTaskByID(ctx, d.ID)
+	if err != nil {
+		return err
+	}
+	if userTask.UserID != user.ID {
+		return callback.ErrForbidden
+	}
+	return nil
+}
+
+func (h *Handler) handleStartTask(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	userTask, err := h.DB.GetUserTaskByID(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	if userTask.Status != models.UserTaskInProgress {
+		return "Задание уже завершено.", nil
+	}
+
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	return "", h.enterStage(ctx, q.Message.Chat.ID, q.From.ID, userTask)
+}
+
+func (h *Handler) handleNextStage(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	userTask, err := h.DB.GetUserTaskByID(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	if userTask.Status != models.UserTaskInProgress {
+		return "Задание уже завершено.", nil
+	}
+
+	// Проверяем, доступен ли следующий шаг
+	availableAt, err := h.DB.GetUserAvailableAt(ctx, q.From.ID)
+	if err == nil && time.Now().Before(availableAt) {
+		return fmt.Sprintf("Этап ещё закрыт. Подождите %s.", humanDuration(time.Until(availableAt))), nil
+	}
+
+	// «Далее» подтверждает только шаги без доказательства
+	step, _, ok, err := h.currentStep(ctx, userTask)
+	if err != nil {
+		return "", err
+	}
+	if ok && step.ProofType != models.ProofNone {
+		return proofPrompt(step), nil
+	}
+
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	return h.advanceStage(ctx, q.Message.Chat.ID, q.From.ID, userTask)
+}
+
+func (h *Handler) handleApprove(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	var userTask *models.UserTask
+	var earnings []referral.Earning
+
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		// Аренда блокирует выполнение: второй модератор получит ErrLeaseTaken
+		if err := tx.RenewModerationLease(ctx, d.ID, q.From.ID, h.ModerationLease); err != nil {
+			return err
+		}
+		var err error
+		userTask, earnings, err = h.approveUserTask(ctx, tx, d.ID, models.UserTaskCompleted)
+		if err != nil {
+			return err
+		}
+		return recordDecision(ctx, tx, d.ID, q.From.ID, models.DecisionApproved, "", nil)
+	})
+	if done, text := h.moderationResult(q, err); done {
+		return text, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	h.markCopies(ctx, userTask.ID, q.Message, "одобрено "+moderatorName(q.From))
+	h.notifyUser(ctx, userTask.UserID, fmt.Sprintf("Ваше задание одобрено! Вам начислено %s.", userTask.Reward))
+	for _, e := range earnings {
+		h.notifyUser(ctx, e.ReferrerID, referralEarningText(e))
+	}
+	return "Задание одобрено.", nil
+}
+
+// moderationResult обрабатывает ожидаемые ошибки решения по выполнению:
+// выполнение уже проверено или его проверяет другой модератор
+func (h *Handler) moderationResult(q *tgbotapi.CallbackQuery, err error) (bool, string) {
+	switch {
+	case errors.Is(err, database.ErrStatusChanged):
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return true, "Задание уже проверено."
+	case errors.Is(err, database.ErrLeaseTaken):
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return true, "Это выполнение проверяет другой модератор."
+	}
+	return false, ""
+}
+
+// approveUserTask одобряет выполнение в статусе from: начисляет вознаграждение
+// и реферальные начисления. Вызывается в транзакции.
+func (h *Handler) approveUserTask(ctx context.Context, tx database.DBInterface, userTaskID int64, from string) (*models.UserTask, []referral.Earning, error) {
+	// Смена статуса первой: при двойном нажатии вторая транзакция
+	// получит ErrStatusChanged и вознаграждение не будет начислено повторно
+	if err := tx.SetUserTaskStatus(ctx, userTaskID, from, models.UserTaskApproved); err != nil {
+		return nil, nil, err
+	}
+
+	userTask, err := tx.GetUserTaskByID(ctx, userTaskID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Вознаграждение зафиксировано при выдаче задания
+	_, err = h.Ledger.Post(ctx, tx, ledger.Transfer(
+		ledger.KindTaskReward,
+		fmt.Sprintf("Вознаграждение за задание #%d", userTask.TaskID),
+		fmt.Sprintf("user_task:%d:reward", userTask.ID),
+		ledger.RewardExpense, ledger.UserWallet(userTask.UserID), userTask.Reward,
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Реферальные начисления фиксируются вместе с одобрением
+	earnings, err := h.Referrals.OnTaskApproved(ctx, tx, userTask.UserID, userTask.ID, userTask.Reward)
+	if err != nil {
+		return nil, nil, err
+	}
+	return userTask, earnings, nil
+}
+
+// recordDecision записывает решение в историю выполнения.
+// moderatorID = 0 - действие исполнителя.
+func recordDecision(ctx context.Context, db database.DBInterface, userTaskID, moderatorID int64, decision, reason string, reasonID *int) error {
+	d := &models.ModerationDecision{
+		UserTaskID: int(userTaskID),
+		Decision:   decision,
+		Reason:     reason,
+		ReasonID:   reasonID,
+	}
+	if moderatorID != 0 {
+		d.ModeratorID = &moderatorID
+	}
+	return db.AddModerationDecision(ctx, d)
+}
+
+// removeInlineKeyboard удаляет inline клавиатуру из сообщения
+func (h *Handler) removeInlineKeyboard(chatID int64, messageID int) {
+	editMsg := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	if _, err := h.Bot.Request(editMsg); err != nil {
+		log.Printf("Ошибка при удалении inline клавиатуры: %v", err)
+	}
+}
+
+// notifyUser отправляет сообщение пользователю по его внутреннему ID
+func (h *Handler) notifyUser(ctx context.Context, userID int, text string) {
+	h.notifyUserWith(ctx, userID, text, nil)
+}
+
+// notifyUserWith отправляет сообщение с клавиатурой пользователю по его внутреннему ID
+func (h *Handler) notifyUserWith(ctx context.Context, userID int, text string, markup interface{}) {
+	var telegramID int64
+	err := h.DB.QueryRowContext(ctx, "SELECT telegram_id FROM users WHERE id=$1", userID).Scan(&telegramID)
+	if err != nil {
+		log.Println("Ошибка при получении Telegram ID:", err)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(telegramID, text)
+	msg.ReplyMarkup = markup
+	if _, err := h.Bot.Send(msg); err != nil {
+		log.Printf("Ошибка при отправке уведомления пользователю %d: %v", userID, err)
+	}
+}
+
+// notifyAdmins отправляет сообщение всем администраторам
+func (h *Handler) notifyAdmins(ctx context.Context, text string) {
+	ids, err := h.adminTelegramIDs(ctx)
+	if err != nil {
+		log.Println("Ошибка при получении списка администраторов:", err)
+		return
+	}
+	for _, id := range ids {
+		if _, err := h.Bot.Send(tgbotapi.NewMessage(id, text)); err != nil {
+			log.Printf("Ошибка при отправке уведомления администратору %d: %v", id, err)
+		}
+	}
+}
+
+// adminTelegramIDs возвращает Telegram ID всех администраторов
+func (h *Handler) adminTelegramIDs(ctx context.Context) ([]int64, error) {
+	return h.telegramIDs(ctx, "SELECT telegram_id FROM users WHERE admin = TRUE")
+}
+
+// telegramIDs возвращает Telegram ID пользователей, выбранных запросом
+func (h *Handler) telegramIDs(ctx context.Context, query string) ([]int64, error) {
+	rows, err := h.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,331 @@
+// handlers/categories.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/fsm"
+	"telegram_bot/models"
+	"telegram_bot/money"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ActionCategoryToggle - скрыть или показать категорию. ID - categories.id.
+const ActionCategoryToggle = "cattoggle"
+
+// categoryButtonsPerRow - сколько категорий в одном ряду клавиатуры
+const categoryButtonsPerRow = 2
+
+var slugRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+var domainRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// categoryFormat - формат строки категории для администратора
+const categoryFormat = "slug; название; награда; эмодзи; порядок; название на английском; домены"
+
+// registerCategoryCallbacks регистрирует кнопки управления категориями
+func (h *Handler) registerCategoryCallbacks(d *callback.Dispatcher) {
+	d.Register(ActionCategoryToggle, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleCategoryToggle,
+	})
+}
+
+// --- Клавиатуры и выбор категории ---
+
+// categoryKeyboard строит клавиатуру из активных категорий.
+// Возвращает также число категорий на клавиатуре.
+func (h *Handler) categoryKeyboard(ctx context.Context, lang string) (tgbotapi.ReplyKeyboardMarkup, int, error) {
+	categories, err := h.DB.ListCategories(ctx, true)
+	if err != nil {
+		return tgbotapi.ReplyKeyboardMarkup{}, 0, err
+	}
+	return buildCategoryKeyboard(categories, lang), len(categories), nil
+}
+
+// availableCategoryKeyboard строит клавиатуру из активных категорий,
+// в которых есть задания, доступные пользователю
+func (h *Handler) availableCategoryKeyboard(ctx context.Context, userID int, lang string) (tgbotapi.ReplyKeyboardMarkup, int, error) {
+	categories, err := h.DB.ListCategories(ctx, true)
+	if err != nil {
+		return tgbotapi.ReplyKeyboardMarkup{}, 0, err
+	}
+	slugs, err := h.DB.GetAvailableCategories(ctx, int64(userID))
+	if err != nil {
+		return tgbotapi.ReplyKeyboardMarkup{}, 0, err
+	}
+
+	available := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		available[slug] = true
+	}
+	var filtered []*models.Category
+	for _, c := range categories {
+		if available[c.Slug] {
+			filtered = append(filtered, c)
+		}
+	}
+	return buildCategoryKeyboard(filtered, lang), len(filtered), nil
+}
+
+func buildCategoryKeyboard(categories []*models.Category, lang string) tgbotapi.ReplyKeyboardMarkup {
+	var rows [][]tgbotapi.KeyboardButton
+	for i := 0; i < len(categories); i += categoryButtonsPerRow {
+		var row []tgbotapi.KeyboardButton
+		for _, c := range categories[i:min(i+categoryButtonsPerRow, len(categories))] {
+			row = append(row, tgbotapi.NewKeyboardButton(c.Button(lang)))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(fsm.CancelText)))
+	return tgbotapi.NewReplyKeyboard(rows...)
+}
+
+// categoryChoices - допустимые ответы в состояниях выбора категории
+func (h *Handler) categoryChoices(ctx context.Context) ([]string, error) {
+	categories, err := h.DB.ListCategories(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	var choices []string
+	for _, c := range categories {
+		for lang, name := range c.Names {
+			choices = append(choices, name, c.Button(lang))
+		}
+	}
+	return choices, nil
+}
+
+// findCategory ищет активную категорию по тексту кнопки или названию
+func (h *Handler) findCategory(ctx context.Context, text string) (*models.Category, error) {
+	categories, err := h.DB.ListCategories(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		if c.Matches(text) {
+			return c, nil
+		}
+	}
+	return nil, database.ErrCategoryNotFound
+}
+
+// categoryName возвращает название категории по slug
+func (h *Handler) categoryName(ctx context.Context, slug string) string {
+	category, err := h.DB.GetCategoryBySlug(ctx, slug)
+	if err != nil {
+		return slug
+	}
+	return category.Button(models.DefaultLanguage)
+}
+
+// --- Управление категориями (администратор) ---
+
+// HandleAdminCategories показывает категории и запрашивает новую или изменённую
+func (h *Handler) HandleAdminCategories(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	categories, err := h.DB.ListCategories(ctx, false)
+	if err != nil {
+		log.Printf("Ошибка при получении категорий: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить список категорий."))
+		return
+	}
+
+	if len(categories) > 0 {
+		msg := tgbotapi.NewMessage(chatID, categoriesText(categories))
+		msg.ReplyMarkup = h.categoryButtons(categories)
+		h.Bot.Send(msg)
+	}
+
+	if !h.transition(ctx, chatID, update.Message.From.ID, models.StateAwaitingCategoryInput) {
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID,
+		"Чтобы добавить или изменить категорию, отправьте строку:\n"+
+			categoryFormat+"\n"+
+			"Например: ozon; Ozon; 40; 📦; 50; Ozon; ozon.ru, ozon.com\n"+
+			"slug - латиница, цифры, «-» и «_», после создания не меняется. "+
+			"Домены перечисляются через запятую: ссылки в заданиях категории должны вести на них. "+
+			"Обязательны первые три поля.")
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+}
+
+// HandleCategoryInput создаёт или изменяет категорию
+func (h *Handler) HandleCategoryInput(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+
+	// Существующая категория сохраняет видимость и незаданные поля
+	slug, _, _ := strings.Cut(update.Message.Text, ";")
+	existing, err := h.DB.GetCategoryBySlug(ctx, strings.ToLower(strings.TrimSpace(slug)))
+	if err != nil && !errors.Is(err, database.ErrCategoryNotFound) {
+		log.Printf("Ошибка при получении категории %s: %v", slug, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить категорию."))
+		return
+	}
+
+	category, err := parseCategory(update.Message.Text, existing)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+". Формат: "+categoryFormat))
+		return
+	}
+
+	if err := h.DB.SaveCategory(ctx, category); err != nil {
+		log.Printf("Ошибка при сохранении категории %s: %v", category.Slug, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить категорию."))
+		return
+	}
+	log.Printf("Администратор %d сохранил категорию %s", adminID, category.Slug)
+
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	text := "Категория добавлена: "
+	if existing != nil {
+		text = "Категория изменена: "
+	}
+	msg := tgbotapi.NewMessage(chatID, text+categoryLine(category))
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+}
+
+func (h *Handler) handleCategoryToggle(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	category, err := h.DB.GetCategoryByID(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := h.DB.SetCategoryActive(ctx, d.ID, !category.IsActive); err != nil {
+		return "", err
+	}
+	log.Printf("Администратор %d: категория %s активна = %t", q.From.ID, category.Slug, !category.IsActive)
+
+	categories, err := h.DB.ListCategories(ctx, false)
+	if err == nil {
+		edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, categoriesText(categories))
+		markup := h.categoryButtons(categories)
+		edit.ReplyMarkup = &markup
+		h.Bot.Send(edit)
+	}
+
+	if category.IsActive {
+		return "Категория " + category.Name(models.DefaultLanguage) + " скрыта.", nil
+	}
+	return "Категория " + category.Name(models.DefaultLanguage) + " снова доступна.", nil
+}
+
+// categoryButtons - кнопки скрытия и показа категорий
+func (h *Handler) categoryButtons(categories []*models.Category) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, c := range categories {
+		label := "🙈 Скрыть " + c.Name(models.DefaultLanguage)
+		if !c.IsActive {
+			label = "👁 Показать " + c.Name(models.DefaultLanguage)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button(label, ActionCategoryToggle, int64(c.ID)),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// categoriesText - список категорий в формате, пригодном для правки
+func categoriesText(categories []*models.Category) string {
+	var b strings.Builder
+	b.WriteString("Категории заданий:")
+	for _, c := range categories {
+		status := ""
+		if !c.IsActive {
+			status = " (скрыта)"
+		}
+		fmt.Fprintf(&b, "\n%s%s", categoryLine(c), status)
+	}
+	return b.String()
+}
+
+// categoryLine записывает категорию в формате ввода
+func categoryLine(c *models.Category) string {
+	return strings.Join([]string{
+		c.Slug,
+		c.Names[models.DefaultLanguage],
+		c.DefaultReward.Format(),
+		c.Emoji,
+		strconv.Itoa(c.SortOrder),
+		c.Names["en"],
+		strings.Join(c.Domains, ", "),
+	}, "; ")
+}
+
+// parseCategory разбирает строку «slug; название; награда; эмодзи; порядок; name; домены».
+// Незаданные необязательные поля берутся из existing, если категория уже есть.
+func parseCategory(input string, existing *models.Category) (*models.Category, error) {
+	fields := strings.Split(input, ";")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) < 3 || len(fields) > 7 {
+		return nil, errors.New("нужно от трёх до семи полей через «;»")
+	}
+
+	c := &models.Category{Names: make(map[string]string), IsActive: true}
+	if existing != nil {
+		*c = *existing
+		c.Names = make(map[string]string, len(existing.Names))
+		for lang, name := range existing.Names {
+			c.Names[lang] = name
+		}
+	}
+	c.Slug = strings.ToLower(fields[0])
+	c.Names[models.DefaultLanguage] = fields[1]
+	if !slugRe.MatchString(c.Slug) || len(c.Slug) > 50 {
+		return nil, errors.New("некорректный slug")
+	}
+	if c.Names[models.DefaultLanguage] == "" || len([]rune(fields[1])) > 100 {
+		return nil, errors.New("некорректное название")
+	}
+	if fsm.CancelText == fields[1] {
+		return nil, errors.New("название совпадает с кнопкой отмены")
+	}
+
+	reward, err := money.Parse(fields[2])
+	if err != nil || reward.IsNegative() {
+		return nil, errors.New("некорректная награда")
+	}
+	c.DefaultReward = reward
+
+	if len(fields) > 3 {
+		c.Emoji = fields[3]
+		if len([]rune(c.Emoji)) > 16 {
+			return nil, errors.New("слишком длинный эмодзи")
+		}
+	}
+	if len(fields) > 4 && fields[4] != "" {
+		c.SortOrder, err = strconv.Atoi(fields[4])
+		if err != nil {
+			return nil, errors.New("некорректный порядок")
+		}
+	}
+	if len(fields) > 5 && fields[5] != "" {
+		c.Names["en"] = fields[5]
+	}
+	if len(fields) > 6 && fields[6] != "" {
+		c.Domains = nil
+		for _, d := range strings.Split(fields[6], ",") {
+			d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "www.")
+			if !domainRe.MatchString(d) || len(d) > 253 {
+				return nil, fmt.Errorf("некорректный домен %q", d)
+			}
+			c.Domains = append(c.Domains, d)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,276 @@
+// handlers/jobs.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"telegram_bot/database"
+	"telegram_bot/models"
+	"telegram_bot/scheduler"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Виды отложенных задач
+const (
+	JobStageUnlock      = "stage_unlock"
+	JobStageDeadline    = "stage_deadline"
+	JobAssignmentExpiry = "assignment_expiry"
+	JobResubmitExpiry   = "resubmit_expiry"
+	JobTriage           = "triage"
+	JobProofHash        = "proof_hash"
+	JobLedgerReconcile  = "ledger_reconcile"
+)
+
+// AssignmentTTL - сколько пользователь может выполнять взятое задание
+const AssignmentTTL = 48 * time.Hour
+
+// ReconcileInterval - как часто сверять users.balance с журналом
+const ReconcileInterval = 24 * time.Hour
+
+// StageJob - параметры задач, относящихся к заданию пользователя.
+// Attempt - номер попытки (user_tasks.resubmits): задачи, запланированные
+// до повторной отправки доказательств, пропускаются.
+type StageJob struct {
+	UserTaskID int64 `json:"user_task_id"`
+	Stage      int   `json:"stage"`
+	Attempt    int   `json:"attempt,omitempty"`
+}
+
+// ProofHashJob - параметры вычисления перцептивного хэша скриншота
+type ProofHashJob struct {
+	ProofID int64  `json:"proof_id"`
+	FileID  string `json:"file_id"`
+}
+
+// humanDuration форматирует длительность для сообщений пользователю
+func humanDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case hours > 0 && minutes > 0:
+		return fmt.Sprintf("%d ч %d мин", hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%d ч", hours)
+	case minutes > 0:
+		return fmt.Sprintf("%d мин", minutes)
+	}
+	return "меньше минуты"
+}
+
+// registerJobs регистрирует обработчики отложенных задач
+func (h *Handler) registerJobs() {
+	h.Scheduler.Register(JobStageUnlock, scheduler.Typed(h.runStageUnlock))
+	h.Scheduler.Register(JobStageDeadline, scheduler.Typed(h.runStageDeadline))
+	h.Scheduler.Register(JobAssignmentExpiry, scheduler.Typed(h.runAssignmentExpiry))
+	h.Scheduler.Register(JobResubmitExpiry, scheduler.Typed(h.runResubmitExpiry))
+	h.Scheduler.Register(JobTriage, scheduler.Typed(h.runTriage))
+	h.Scheduler.Register(JobProofHash, scheduler.Typed(h.runProofHash))
+	h.Scheduler.Register(JobLedgerReconcile, scheduler.Typed(h.runLedgerReconcile))
+}
+
+// reconcileLockKey - ключ advisory lock для планирования сверки журнала
+const reconcileLockKey = 7_305_512_002
+
+// ScheduleReconciliation планирует сверку журнала, если она ещё не
+// запланирована. Дальше задача перепланирует себя сама. Проверка и вставка
+// выполняются под advisory lock: экземпляры бота, запущенные одновременно,
+// не создадут две цепочки сверок.
+func (h *Handler) ScheduleReconciliation(ctx context.Context) error {
+	return h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", reconcileLockKey); err != nil {
+			return err
+		}
+
+		var exists bool
+		err := tx.QueryRowContext(ctx, `
+            SELECT EXISTS (SELECT 1 FROM scheduled_jobs WHERE kind = $1 AND status IN ($2, $3))
+        `, JobLedgerReconcile, scheduler.StatusPending, scheduler.StatusRunning).Scan(&exists)
+		if err != nil || exists {
+			return err
+		}
+		_, err = h.Scheduler.Enqueue(ctx, tx, JobLedgerReconcile, struct{}{}, time.Now())
+		return err
+	})
+}
+
+// AssignTask назначает задание пользователю и планирует истечение срока
+// выполнения. Обе записи создаются в одной транзакции.
+func (h *Handler) AssignTask(ctx context.Context, taskID int64, userID int64) (int64, error) {
+	var userTaskID int64
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		var err error
+		userTaskID, err = tx.AssignTaskToUser(ctx, taskID, userID)
+		if err != nil {
+			return err
+		}
+		_, err = h.Scheduler.Enqueue(ctx, tx, JobAssignmentExpiry, StageJob{UserTaskID: userTaskID}, time.Now().Add(AssignmentTTL))
+		return err
+	})
+	return userTaskID, err
+}
+
+// releaseSlot возвращает заданию место и бюджет, занятые выполнением userTaskID.
+// Вызывается в транзакции вместе со сменой статуса выполнения.
+func releaseSlot(ctx context.Context, tx database.DBInterface, userTaskID int64) error {
+	userTask, err := tx.GetUserTaskByID(ctx, userTaskID)
+	if err != nil {
+		return err
+	}
+	return tx.ReleaseTaskSlot(ctx, int64(userTask.TaskID), userTask.Reward)
+}
+
+// lockStage закрывает этап до истечения задержки: сохраняет users.available_at
+// и планирует уведомление об открытии этапа.
+func (h *Handler) lockStage(ctx context.Context, telegramID int64, userTask *models.UserTask, delay time.Duration) error {
+	availableAt := time.Now().Add(delay)
+	return h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.SetUserAvailableAt(ctx, telegramID, availableAt); err != nil {
+			return err
+		}
+		job := StageJob{UserTaskID: int64(userTask.ID), Stage: userTask.CurrentStage, Attempt: userTask.Resubmits}
+		_, err := h.Scheduler.Enqueue(ctx, tx, JobStageUnlock, job, availableAt)
+		return err
+	})
+}
+
+// runStageUnlock уведомляет пользователя об открытии этапа и присылает его
+func (h *Handler) runStageUnlock(ctx context.Context, job StageJob) error {
+	userTask, err := h.DB.GetUserTaskByID(ctx, job.UserTaskID)
+	if err != nil {
+		return err
+	}
+	// Задание могли завершить или отменить, пока этап был закрыт
+	if userTask.Status != models.UserTaskInProgress || userTask.CurrentStage != job.Stage || userTask.Resubmits != job.Attempt {
+		return nil
+	}
+
+	var telegramID int64
+	err = h.DB.QueryRowContext(ctx, "SELECT telegram_id FROM users WHERE id=$1", userTask.UserID).Scan(&telegramID)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении Telegram ID: %w", err)
+	}
+
+	h.NotifyUserStage(ctx, userTask.UserID, userTask.TaskID, job.Stage)
+	// В личном чате ID чата совпадает с Telegram ID пользователя
+	return h.enterStage(ctx, telegramID, telegramID, userTask)
+}
+
+// runAssignmentExpiry снимает с пользователя задание, не выполненное в срок
+func (h *Handler) runAssignmentExpiry(ctx context.Context, job StageJob) error {
+	userTask, err := h.DB.GetUserTaskByID(ctx, job.UserTaskID)
+	if err != nil {
+		return err
+	}
+	// После повторной отправки действует срок новой попытки
+	if userTask.Resubmits != job.Attempt {
+		return nil
+	}
+
+	expired, err := h.expireUserTask(ctx, userTask,
+		"Срок выполнения задания истёк, оно снято с вас. Вы можете взять новое задание.")
+	if err != nil || !expired {
+		return err
+	}
+	log.Printf("Задание пользователя %d истекло", userTask.ID)
+	return nil
+}
+
+// expireUserTask снимает с пользователя выполнение в работе, освобождает
+// слот задания и выводит пользователя из диалога доказательств.
+// Возвращает false, если выполнение уже сдано или закрыто.
+func (h *Handler) expireUserTask(ctx context.Context, userTask *models.UserTask, text string) (bool, error) {
+	userTaskID := int64(userTask.ID)
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.SetUserTaskStatus(ctx, userTaskID, models.UserTaskInProgress, models.UserTaskExpired); err != nil {
+			return err
+		}
+		return releaseSlot(ctx, tx, userTaskID)
+	})
+	if errors.Is(err, database.ErrStatusChanged) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	h.leaveProofDialog(ctx, userTask.UserID, text)
+	return true, nil
+}
+
+// leaveProofDialog сбрасывает диалог доказательств пользователя и сообщает
+// ему text. Если открыт другой диалог, он и его клавиатура остаются.
+func (h *Handler) leaveProofDialog(ctx context.Context, userID int, text string) {
+	var telegramID int64
+	err := h.DB.QueryRowContext(ctx, "SELECT telegram_id FROM users WHERE id=$1", userID).Scan(&telegramID)
+	if err != nil {
+		log.Println("Ошибка при получении Telegram ID:", err)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(telegramID, text)
+	state, err := h.FSM.Current(ctx, telegramID)
+	if err != nil {
+		log.Printf("Ошибка при получении состояния пользователя %d: %v", telegramID, err)
+	} else if state == models.StateAwaitingTaskProof {
+		if err := h.FSM.Cancel(ctx, telegramID); err != nil {
+			log.Printf("Ошибка при сбросе состояния пользователя %d: %v", telegramID, err)
+		} else {
+			msg.ReplyMarkup = h.Keyboard
+		}
+	}
+	if _, err := h.Bot.Send(msg); err != nil {
+		log.Printf("Ошибка при отправке уведомления пользователю %d: %v", userID, err)
+	}
+}
+
+// runResubmitExpiry окончательно отклоняет выполнение, если исполнитель
+// не отправил доказательства заново в срок
+func (h *Handler) runResubmitExpiry(ctx context.Context, job StageJob) error {
+	userTask, err := h.DB.GetUserTaskByID(ctx, job.UserTaskID)
+	if err != nil {
+		return err
+	}
+	if userTask.Resubmits != job.Attempt {
+		return nil
+	}
+
+	err = h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.SetUserTaskStatus(ctx, job.UserTaskID, models.UserTaskResubmit, models.UserTaskRejected); err != nil {
+			return err
+		}
+		return releaseSlot(ctx, tx, job.UserTaskID)
+	})
+	if errors.Is(err, database.ErrStatusChanged) {
+		// Исполнитель уже начал повторную отправку
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Выполнение %d не отправлено повторно в срок", userTask.ID)
+	h.notifyUser(ctx, userTask.UserID, "Срок повторной отправки доказательств истёк, задание отклонено. Вы можете взять новое задание.")
+	return nil
+}
+
+// runLedgerReconcile сверяет балансы с журналом и сообщает администраторам
+// о расхождениях
+func (h *Handler) runLedgerReconcile(ctx context.Context, _ struct{}) error {
+	report, err := h.Ledger.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println(report.String())
+	if !report.OK() {
+		h.notifyAdmins(ctx, report.String())
+	}
+
+	_, err = h.Scheduler.Enqueue(ctx, nil, JobLedgerReconcile, struct{}{}, time.Now().Add(ReconcileInterval))
+	return err
+}
@@ -0,0 +1,344 @@
+// handlers/moderation.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/imagehash"
+	"telegram_bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ActionRelease - вернуть выполнение в очередь. ID - user_tasks.id.
+const ActionRelease = "release"
+
+// maxMediaGroup - предельное число фотографий в одной медиагруппе Telegram
+const maxMediaGroup = 10
+
+// maxQuoteLen - сколько символов описания и текстовых ответов показывать в карточке
+const maxQuoteLen = 500
+
+// maxSimilarShown - сколько похожих скриншотов перечислять в карточке
+const maxSimilarShown = 10
+
+// registerModerationCallbacks регистрирует кнопки очереди проверки
+func (h *Handler) registerModerationCallbacks(d *callback.Dispatcher) {
+	d.Register(ActionRelease, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleRelease,
+	})
+}
+
+// HandleAdminCheckTasks выдаёт модератору следующее выполнение из очереди,
+// начиная с самых старых. Выполнение закрепляется за ним на время аренды,
+// другие модераторы его не получат.
+func (h *Handler) HandleAdminCheckTasks(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	moderatorID := update.Message.From.ID
+
+	userTask, err := h.DB.ClaimSubmission(ctx, moderatorID, h.ModerationLease)
+	if errors.Is(err, database.ErrQueueEmpty) {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Нет заданий для проверки."))
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении задания для проверки: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка при получении заданий для проверки."))
+		return
+	}
+	log.Printf("Модератор %d взял на проверку выполнение %d", moderatorID, userTask.ID)
+
+	// Карточки, отправленные раньше, показывают, что выполнение уже проверяют
+	h.markCopies(ctx, userTask.ID, nil, "на проверке у "+moderatorName(update.Message.From))
+
+	text := fmt.Sprintf("Выполнение закреплено за вами на %s.", humanDuration(h.ModerationLease))
+	if waiting, leased, err := h.DB.CountSubmissions(ctx); err == nil {
+		text += fmt.Sprintf(" На проверке всего: %d, из них у модераторов: %d.", waiting, leased)
+	}
+	h.Bot.Send(tgbotapi.NewMessage(chatID, text+" После решения нажмите «Проверить задания» для следующего."))
+
+	if err := h.sendSubmission(ctx, chatID, userTask, h.moderationButtons(int64(userTask.ID))); err != nil {
+		log.Printf("Ошибка при отправке выполнения %d на проверку: %v", userTask.ID, err)
+	}
+}
+
+// handleRelease возвращает выполнение в очередь
+func (h *Handler) handleRelease(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	err := h.DB.ReleaseModerationLease(ctx, d.ID, q.From.ID)
+	if errors.Is(err, database.ErrStatusChanged) {
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return "Выполнение уже проверено или закреплено за другим модератором.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	log.Printf("Модератор %d вернул в очередь выполнение %d", q.From.ID, d.ID)
+	return "Выполнение возвращено в очередь.", nil
+}
+
+// sendSubmission отправляет модератору выполнение: скриншоты медиагруппой
+// и карточку с историей исполнителя и кнопками решения
+func (h *Handler) sendSubmission(ctx context.Context, chatID int64, userTask *models.UserTask, buttons tgbotapi.InlineKeyboardMarkup) error {
+	task, err := h.DB.GetTaskByID(ctx, int64(userTask.TaskID))
+	if err != nil {
+		return fmt.Errorf("ошибка при получении задания %d: %w", userTask.TaskID, err)
+	}
+	proofs, err := h.DB.ListUserTaskProofs(ctx, userTask.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении доказательств: %w", err)
+	}
+
+	var telegramID int64
+	var username string
+	err = h.DB.QueryRowContext(ctx, "SELECT telegram_id, COALESCE(username, '') FROM users WHERE id=$1", userTask.UserID).
+		Scan(&telegramID, &username)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении исполнителя: %w", err)
+	}
+	stats, err := h.DB.GetExecutorStats(ctx, userTask.UserID)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении истории исполнителя: %w", err)
+	}
+	decisions, err := h.DB.ListModerationDecisions(ctx, userTask.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении истории решений: %w", err)
+	}
+	similar, err := h.DB.FindSimilarProofs(ctx, userTask.ID, h.PHashDistance, maxSimilarShown)
+	if err != nil {
+		return fmt.Errorf("ошибка при поиске похожих скриншотов: %w", err)
+	}
+
+	// Скриншоты с подписью шага; старые выполнения хранят ссылки в user_tasks.screenshots
+	var photos []tgbotapi.InputMediaPhoto
+	for _, p := range proofs {
+		if p.Kind.IsPhoto() {
+			photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileID(p.FileID))
+			photo.Caption = fmt.Sprintf("Выполнение #%d, шаг %d", userTask.ID, p.Stage)
+			photos = append(photos, photo)
+		}
+	}
+	if len(photos) == 0 {
+		for _, url := range userTask.Screenshots {
+			photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileURL(url))
+			photo.Caption = fmt.Sprintf("Выполнение #%d", userTask.ID)
+			photos = append(photos, photo)
+		}
+	}
+	firstMessageID := h.sendPhotos(chatID, photos)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📝 Выполнение #%d\n", userTask.ID)
+	if userTask.Status == models.UserTaskAppealed {
+		b.WriteString("⚖️ Апелляция на отклонение\n")
+	}
+	executor := fmt.Sprintf("ID %d, Telegram %d", userTask.UserID, telegramID)
+	if username != "" {
+		executor = "@" + username + ", " + executor
+	}
+	fmt.Fprintf(&b, "👤 Исполнитель: %s\n", executor)
+	fmt.Fprintf(&b, "📊 История: одобрено %d, отклонено %d, просрочено %d, на проверке %d, заработано %s\n",
+		stats.Approved, stats.Rejected, stats.Expired, stats.Submitted, stats.Earned)
+	fmt.Fprintf(&b, "📂 Категория: %s\n", h.categoryName(ctx, task.Category))
+	fmt.Fprintf(&b, "📄 Задание #%d: %s\n", task.ID, truncateText(task.Description, maxQuoteLen))
+	if task.Link != "" {
+		fmt.Fprintf(&b, "🔗 Ссылка: %s\n", task.Link)
+	}
+	fmt.Fprintf(&b, "💰 Вознаграждение: %s\n", userTask.Reward)
+	fmt.Fprintf(&b, "📅 Сдано: %s\n", userTask.LastUpdated)
+	b.WriteString(proofsText(proofs, len(photos)))
+	b.WriteString(similarText(similar, userTask.UserID))
+	b.WriteString(decisionsText(decisions))
+
+	msg := tgbotapi.NewMessage(chatID, b.String())
+	msg.ReplyToMessageID = firstMessageID
+	msg.ReplyMarkup = buttons
+	sent, err := h.Bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	return h.DB.AddModerationMessage(ctx, models.ModerationMessage{
+		UserTaskID: userTask.ID, ChatID: chatID, MessageID: sent.MessageID,
+	})
+}
+
+// moderationButtons - кнопки решения по выполнению
+func (h *Handler) moderationButtons(userTaskID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("✅ Одобрить", ActionApprove, userTaskID),
+			h.Codec.Button("❌ Отклонить", ActionReject, userTaskID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("↩️ Вернуть в очередь", ActionRelease, userTaskID),
+		),
+	)
+}
+
+// markCopies заменяет текст карточек выполнения, отправленных модераторам,
+// на статус и убирает кнопки. Карточка except (обычно та, на которой нажата
+// кнопка) не меняется.
+func (h *Handler) markCopies(ctx context.Context, userTaskID int, except *tgbotapi.Message, status string) {
+	messages, err := h.DB.ListModerationMessages(ctx, userTaskID)
+	if err != nil {
+		log.Printf("Ошибка при получении карточек выполнения %d: %v", userTaskID, err)
+		return
+	}
+	text := fmt.Sprintf("📝 Выполнение #%d: %s", userTaskID, status)
+	for _, m := range messages {
+		if except != nil && m.ChatID == except.Chat.ID && m.MessageID == except.MessageID {
+			continue
+		}
+		edit := tgbotapi.NewEditMessageText(m.ChatID, m.MessageID, text)
+		if _, err := h.Bot.Request(edit); err != nil {
+			log.Printf("Ошибка при изменении карточки выполнения %d: %v", userTaskID, err)
+		}
+	}
+}
+
+// moderatorName - имя модератора для карточек других модераторов
+func moderatorName(u *tgbotapi.User) string {
+	if u.UserName != "" {
+		return "@" + u.UserName
+	}
+	if u.FirstName != "" {
+		return u.FirstName
+	}
+	return fmt.Sprintf("ID %d", u.ID)
+}
+
+// sendPhotos отправляет фотографии медиагруппами по maxMediaGroup штук.
+// Возвращает ID первого отправленного сообщения или 0.
+func (h *Handler) sendPhotos(chatID int64, photos []tgbotapi.InputMediaPhoto) int {
+	firstMessageID := 0
+	for start := 0; start < len(photos); start += maxMediaGroup {
+		chunk := photos[start:min(start+maxMediaGroup, len(photos))]
+
+		// Медиагруппа должна содержать хотя бы две фотографии
+		if len(chunk) == 1 {
+			photo := tgbotapi.NewPhoto(chatID, chunk[0].Media)
+			photo.Caption = chunk[0].Caption
+			sent, err := h.Bot.Send(photo)
+			if err != nil {
+				log.Printf("Ошибка при отправке скриншота: %v", err)
+				continue
+			}
+			if firstMessageID == 0 {
+				firstMessageID = sent.MessageID
+			}
+			continue
+		}
+
+		media := make([]interface{}, len(chunk))
+		for i, p := range chunk {
+			media[i] = p
+		}
+		sent, err := h.Bot.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media))
+		if err != nil {
+			log.Printf("Ошибка при отправке скриншотов: %v", err)
+			continue
+		}
+		if firstMessageID == 0 && len(sent) > 0 {
+			firstMessageID = sent[0].MessageID
+		}
+	}
+	return firstMessageID
+}
+
+// proofsText перечисляет доказательства по шагам
+func proofsText(proofs []*models.UserTaskProof, photos int) string {
+	if len(proofs) == 0 && photos == 0 {
+		return "\nДоказательств нет."
+	}
+	var b strings.Builder
+	b.WriteString("\nДоказательства:")
+	screenshots := make(map[int]int)
+	var stages []int
+	for _, p := range proofs {
+		if p.Kind.IsPhoto() {
+			if screenshots[p.Stage] == 0 {
+				stages = append(stages, p.Stage)
+			}
+			screenshots[p.Stage]++
+			continue
+		}
+		fmt.Fprintf(&b, "\nШаг %d: %s", p.Stage, truncateText(p.Content, maxQuoteLen))
+	}
+	for _, stage := range stages {
+		fmt.Fprintf(&b, "\nШаг %d: скриншотов %d", stage, screenshots[stage])
+	}
+	if len(stages) == 0 && photos > 0 {
+		fmt.Fprintf(&b, "\nСкриншотов: %d", photos)
+	}
+	return b.String()
+}
+
+// similarText предупреждает о скриншотах, похожих на присланные
+// в других выполнениях
+func similarText(similar []*models.SimilarProof, executorID int) string {
+	if len(similar) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n⚠️ Похожие скриншоты в других выполнениях:")
+	for _, s := range similar {
+		owner := "того же исполнителя"
+		if s.UserID != executorID {
+			owner = fmt.Sprintf("другого исполнителя (ID %d)", s.UserID)
+		}
+		match := "совпадает"
+		if s.Distance > 0 {
+			match = fmt.Sprintf("отличие %d из %d бит", s.Distance, imagehash.Bits)
+		}
+		fmt.Fprintf(&b, "\nШаг %d ≈ выполнение #%d %s, задание #%d: %s", s.Stage, s.UserTaskID, owner, s.TaskID, match)
+	}
+	return b.String()
+}
+
+// decisionNames - решения по выполнению в истории
+var decisionNames = map[string]string{
+	models.DecisionApproved:       "одобрено",
+	models.DecisionRejected:       "отклонено",
+	models.DecisionResubmit:       "возвращено на исправление",
+	models.DecisionAppealed:       "обжаловано исполнителем",
+	models.DecisionAppealApproved: "апелляция удовлетворена",
+	models.DecisionAppealRejected: "апелляция отклонена",
+	models.DecisionAutoApproved:   "одобрено автоматически",
+	models.DecisionAutoRejected:   "отклонено автоматически",
+	models.DecisionAutoReverted:   "автоматическое решение отменено",
+}
+
+// decisionsText перечисляет прежние решения по выполнению
+func decisionsText(decisions []*models.ModerationDecision) string {
+	if len(decisions) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nИстория решений:")
+	for _, d := range decisions {
+		fmt.Fprintf(&b, "\n%s %s", d.CreatedAt.Format("02.01.2006 15:04"), decisionNames[d.Decision])
+		if d.ModeratorID != nil {
+			fmt.Fprintf(&b, " (модератор %d)", *d.ModeratorID)
+		}
+		if d.Reason != "" {
+			fmt.Fprintf(&b, ": %s", truncateText(d.Reason, maxQuoteLen))
+		}
+	}
+	return b.String()
+}
+
+// truncateText обрезает текст до limit символов
+func truncateText(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return string(r[:limit-1]) + "…"
+}
@@ -0,0 +1,123 @@
+// handlers/payout_batch.go
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+
+	"telegram_bot/models"
+	"telegram_bot/tgfile"
+	"telegram_bot/withdrawal"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxResultsFileSize - предельный размер файла с результатами выплат
+const maxResultsFileSize = 5 << 20
+
+// HandleAdminPayoutExport выгружает одобренные заявки в CSV и реестр для банка.
+// Полные реквизиты получает только исполнитель выплат.
+func (h *Handler) HandleAdminPayoutExport(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	full := h.isPayoutOperator(ctx, update.Message.From.ID)
+
+	batch, err := h.Withdrawals.Approved(ctx, h.Payouts, full)
+	if err != nil {
+		log.Printf("Ошибка при выгрузке заявок на вывод: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось выгрузить заявки."))
+		return
+	}
+	if len(batch.Rows) == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Нет одобренных заявок, ожидающих перевода."))
+		return
+	}
+
+	caption := fmt.Sprintf("Заявок: %d, сумма: %s", len(batch.Rows), batch.Total)
+	if !full {
+		caption += "\nРеквизиты замаскированы: полные данные получает только исполнитель выплат."
+	}
+
+	date := batch.CreatedAt.Format("20060102_1504")
+	for _, f := range []struct{ format, name string }{
+		{withdrawal.FormatCSV, "payouts_" + date + ".csv"},
+		{withdrawal.FormatRegistry, "registry_" + date + ".csv"},
+	} {
+		data, err := batch.Encode(f.format)
+		if err != nil {
+			log.Printf("Ошибка при формировании выгрузки %s: %v", f.format, err)
+			continue
+		}
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: f.name, Bytes: data})
+		doc.Caption = caption
+		if _, err := h.Bot.Send(doc); err != nil {
+			log.Printf("Ошибка при отправке выгрузки %s: %v", f.name, err)
+		}
+	}
+}
+
+// HandleAdminPayoutImport запрашивает файл с результатами переводов
+func (h *Handler) HandleAdminPayoutImport(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	if !h.transition(ctx, chatID, update.Message.From.ID, models.StateAwaitingPayoutResults) {
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID,
+		"Отправьте CSV-файл с результатами переводов.\n"+
+			"Формат строки: withdrawal_id,status,comment\n"+
+			"status - paid (выплачено) или failed (не прошло, средства вернутся пользователю).")
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+}
+
+// HandlePayoutResultsFile загружает результаты переводов и проводит их по журналу
+func (h *Handler) HandlePayoutResultsFile(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+	doc := update.Message.Document
+
+	if doc.FileSize > maxResultsFileSize {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Файл слишком большой."))
+		return
+	}
+
+	body, err := tgfile.Download(ctx, h.Bot, doc.FileID, maxResultsFileSize)
+	if err != nil {
+		log.Printf("Ошибка при загрузке файла результатов выплат: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить файл. Попробуйте ещё раз."))
+		return
+	}
+
+	report, err := h.Withdrawals.ImportResults(ctx, bytes.NewReader(body), adminID)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+"."))
+		return
+	}
+
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, report.String())
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+
+	h.notifyWithdrawalResults(ctx, report)
+}
+
+// notifyWithdrawalResults сообщает пользователям о результатах переводов
+func (h *Handler) notifyWithdrawalResults(ctx context.Context, report *withdrawal.ImportReport) {
+	for _, id := range append(append([]int64{}, report.Paid...), report.Failed...) {
+		w, err := h.Withdrawals.Get(ctx, id)
+		if err != nil {
+			log.Printf("Ошибка при получении заявки %d: %v", id, err)
+			continue
+		}
+		text := fmt.Sprintf("Заявка #%d выплачена: %s отправлены на ваши реквизиты.", w.ID, w.Amount)
+		if w.Status == models.WithdrawalFailed {
+			text = fmt.Sprintf("Перевод по заявке #%d не прошёл. Сумма %s возвращена на баланс.", w.ID, w.Amount)
+		}
+		h.notifyUser(ctx, w.UserID, text)
+	}
+}
@@ -0,0 +1,101 @@
+// handlers/payout_methods.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"telegram_bot/callback"
+	"telegram_bot/models"
+	"telegram_bot/payout"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ActionPayoutDelete - удаление сохранённых реквизитов. ID - payout_methods.id.
+const ActionPayoutDelete = "pmdelete"
+
+// registerPayoutCallbacks регистрирует кнопки управления реквизитами
+func (h *Handler) registerPayoutCallbacks(d *callback.Dispatcher) {
+	d.Register(ActionPayoutDelete, callback.Action{
+		Authorize: h.authorizePayoutOwner,
+		Handle:    h.handlePayoutDelete,
+	})
+}
+
+// HandlePayoutMethods показывает сохранённые реквизиты (маскированные)
+func (h *Handler) HandlePayoutMethods(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	user, err := h.DB.GetUserByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось найти ваш профиль."))
+		return
+	}
+
+	methods, err := h.Payouts.List(ctx, user.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении реквизитов пользователя %d: %v", user.TelegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить реквизиты."))
+		return
+	}
+	if len(methods) == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			"У вас нет сохранённых реквизитов. Они добавляются при оформлении вывода средств."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Ваши реквизиты. Нажмите, чтобы удалить:")
+	msg.ReplyMarkup = h.payoutMethodButtons(methods)
+	h.Bot.Send(msg)
+}
+
+// payoutMethodButtons - кнопки удаления реквизитов
+func (h *Handler) payoutMethodButtons(methods []*models.PayoutMethod) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, m := range methods {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("🗑 "+m.Label, ActionPayoutDelete, m.ID),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// authorizePayoutOwner разрешает действие только владельцу реквизитов
+func (h *Handler) authorizePayoutOwner(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) error {
+	user, err := h.DB.GetUserByTelegramID(ctx, q.From.ID)
+	if err != nil {
+		return err
+	}
+	method, err := h.Payouts.Get(ctx, d.ID)
+	if err != nil {
+		return err
+	}
+	if method.UserID != user.ID {
+		return callback.ErrForbidden
+	}
+	return nil
+}
+
+func (h *Handler) handlePayoutDelete(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	method, err := h.Payouts.Get(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	err = h.Payouts.Delete(ctx, method.UserID, d.ID)
+	if errors.Is(err, payout.ErrNotFound) {
+		return "Реквизиты уже удалены.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Оставляем в сообщении кнопки остальных реквизитов
+	methods, err := h.Payouts.List(ctx, method.UserID)
+	if err != nil || len(methods) == 0 {
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	} else {
+		h.Bot.Request(tgbotapi.NewEditMessageReplyMarkup(q.Message.Chat.ID, q.Message.MessageID, h.payoutMethodButtons(methods)))
+	}
+	return "Реквизиты " + method.Label + " удалены.", nil
+}
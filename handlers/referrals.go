@@ -0,0 +1,195 @@
+// handlers/referrals.go
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"telegram_bot/models"
+	"telegram_bot/referral"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandleMyReferrals показывает приглашённых пользователей и начисления за них
+func (h *Handler) HandleMyReferrals(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	telegramID := update.Message.From.ID
+
+	user, err := h.DB.GetUserByTelegramID(ctx, telegramID)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось найти ваш профиль."))
+		return
+	}
+
+	report, err := h.Referrals.Report(ctx, user.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении рефералов пользователя %d: %v", telegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить список рефералов."))
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("👥 Мои рефералы\n\n")
+	b.WriteString(h.referralTerms(ctx))
+	fmt.Fprintf(&b, "\n\nВаша ссылка:\n%s\n\n", referral.Link(h.Bot.Self.UserName, telegramID))
+
+	if len(report.Referees) == 0 {
+		b.WriteString("Вы пока никого не пригласили.")
+		h.Bot.Send(tgbotapi.NewMessage(chatID, b.String()))
+		return
+	}
+
+	fmt.Fprintf(&b, "Приглашено: %d, заработано: %s\n\n", len(report.Referees), report.Total)
+	for _, r := range report.Referees {
+		name := "пользователь " + maskTelegramID(r.TelegramID)
+		if r.Username != "" {
+			name = "@" + r.Username
+		}
+		fmt.Fprintf(&b, "• %s, с %s: заданий %d, начислено %s\n",
+			name, r.JoinedAt.Format("02.01.2006"), r.ApprovedTasks, r.Earned)
+	}
+	if report.Indirect.IsPositive() {
+		fmt.Fprintf(&b, "\nЗа рефералов следующих уровней: %s\n", report.Indirect)
+	}
+	h.Bot.Send(tgbotapi.NewMessage(chatID, b.String()))
+}
+
+// referralTerms описывает текущие условия программы
+func (h *Handler) referralTerms(ctx context.Context) string {
+	cfg := h.Referrals.Config()
+	var terms []string
+	if cfg.Bonus.IsPositive() {
+		terms = append(terms, fmt.Sprintf("%s за первое одобренное задание приглашённого", cfg.Bonus))
+	}
+
+	tiers, err := h.Referrals.Tiers(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении реферальных уровней: %v", err)
+	}
+	levels := make([]string, 0, len(tiers))
+	for _, t := range tiers {
+		if t.PercentBP > 0 {
+			levels = append(levels, fmt.Sprintf("%s%% за %d-й уровень", formatPercent(t.PercentBP), t.Level))
+		}
+	}
+	if len(levels) > 0 {
+		terms = append(terms, fmt.Sprintf("%s от вознаграждений рефералов в течение %d дн. после приглашения",
+			strings.Join(levels, ", "), cfg.PercentDays))
+	}
+	if len(terms) == 0 {
+		return "Сейчас начисления за приглашения не производятся."
+	}
+	return "Вы получаете: " + strings.Join(terms, " и ") + "."
+}
+
+// referralEarningText - уведомление рефереру о начислении
+func referralEarningText(e referral.Earning) string {
+	switch {
+	case e.Kind == referral.KindBonus:
+		return fmt.Sprintf("🎁 Ваш реферал выполнил первое задание! Вам начислен бонус %s.", e.Amount)
+	case e.Level > 1:
+		return fmt.Sprintf("💸 Начисление за задание реферала %d-го уровня: %s.", e.Level, e.Amount)
+	}
+	return fmt.Sprintf("💸 Начисление за задание вашего реферала: %s.", e.Amount)
+}
+
+// maskTelegramID скрывает часть Telegram ID в отчётах
+func maskTelegramID(id int64) string {
+	s := fmt.Sprint(id)
+	if len(s) <= 4 {
+		return s
+	}
+	return "…" + s[len(s)-4:]
+}
+
+// formatPercent переводит базисные пункты в проценты: 1250 -> "12,5"
+func formatPercent(basisPoints int64) string {
+	s := fmt.Sprintf("%d,%02d", basisPoints/100, basisPoints%100)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ",")
+}
+
+// --- Настройка уровней (администратор) ---
+
+// HandleAdminReferralTiers показывает уровни и запрашивает изменения
+func (h *Handler) HandleAdminReferralTiers(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	tiers, err := h.Referrals.Tiers(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении реферальных уровней: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить реферальные уровни."))
+		return
+	}
+	if !h.transition(ctx, chatID, update.Message.From.ID, models.StateAwaitingReferralTier) {
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, tiersText(tiers)+fmt.Sprintf(
+		"\n\nЧтобы изменить уровень, отправьте: уровень процент [лимит в месяц].\n"+
+			"Например, «2 3 500» - 3%% со второго уровня, не больше 500 ₽ одному рефереру в месяц.\n"+
+			"Лимит 0 или без лимита - без ограничения, процент 0 - отключить уровень.\n"+
+			"Уровней не больше %d.", h.Referrals.Config().MaxLevels))
+	if bp := h.Referrals.Config().PercentBP; bp > 0 {
+		msg.Text += fmt.Sprintf("\nПока ни один уровень не сохранён, для 1-го уровня действует REFERRAL_PERCENT (%s%%).",
+			formatPercent(bp))
+	}
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+}
+
+// HandleReferralTierInput сохраняет изменённый уровень
+func (h *Handler) HandleReferralTierInput(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+
+	t, err := referral.ParseTier(update.Message.Text)
+	if err == nil && t.Level > h.Referrals.Config().MaxLevels {
+		err = referral.ErrInvalidTier
+	}
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось разобрать уровень. Формат: уровень процент [лимит], например «1 10 1000»."))
+		return
+	}
+
+	if err := h.Referrals.SetTier(ctx, t); err != nil {
+		log.Printf("Ошибка при сохранении реферального уровня %d: %v", t.Level, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить уровень."))
+		return
+	}
+	log.Printf("Администратор %d изменил реферальный уровень %d: %d б.п., лимит %s",
+		adminID, t.Level, t.PercentBP, t.MonthlyCap.Decimal())
+
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	tiers, err := h.Referrals.Tiers(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении реферальных уровней: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, "Сохранено.\n\n"+tiersText(tiers))
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+}
+
+// tiersText - таблица реферальных уровней для администратора
+func tiersText(tiers []referral.Tier) string {
+	if len(tiers) == 0 {
+		return "Реферальные уровни не настроены: процент с заданий рефералов не начисляется."
+	}
+	var b strings.Builder
+	b.WriteString("Реферальные уровни:")
+	for _, t := range tiers {
+		if t.PercentBP == 0 {
+			fmt.Fprintf(&b, "\n%d-й уровень: отключён", t.Level)
+			continue
+		}
+		limit := "без лимита"
+		if t.MonthlyCap.IsPositive() {
+			limit = "до " + t.MonthlyCap.String() + " в месяц"
+		}
+		fmt.Fprintf(&b, "\n%d-й уровень: %s%%, %s", t.Level, formatPercent(t.PercentBP), limit)
+	}
+	return b.String()
+}
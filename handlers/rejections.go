@@ -0,0 +1,774 @@
+// handlers/rejections.go
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/models"
+	"telegram_bot/referral"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действия отклонения и апелляции
+const (
+	ActionRejectReason  = "rejreason"    // ID - rejection_reasons.id
+	ActionRejectCustom  = "rejcustom"    // ID 1 - с повторной отправкой, 0 - без
+	ActionRejectBack    = "rejback"      // ID - user_tasks.id
+	ActionResubmit      = "resubmit"     // ID - user_tasks.id
+	ActionAppeal        = "appeal"       // ID - user_tasks.id
+	ActionAppealApprove = "appealok"     // ID - user_tasks.id
+	ActionAppealReject  = "appealno"     // ID - user_tasks.id
+	ActionReasonToggle  = "reasontoggle" // ID - rejection_reasons.id
+)
+
+// Ключи временных данных
+const (
+	tempRejection = "rejection" // выполнение, для которого модератор выбирает причину
+	tempAppeal    = "appeal"    // выполнение, которое обжалует исполнитель
+)
+
+// maxReasonLen - длина причины отклонения и текста апелляции
+const maxReasonLen = 500
+
+// maxAppealsShown - сколько апелляций присылать командой /appeals
+const maxAppealsShown = 10
+
+// seniorUsage - подсказка к команде /senior
+const seniorUsage = "Формат: /senior <Telegram ID> <да|нет>, например /senior 123456789 да"
+
+// ResubmitTTL - сколько исполнитель может ждать с повторной отправкой доказательств
+const ResubmitTTL = AssignmentTTL
+
+// pendingRejection - выполнение, для которого модератор выбирает причину.
+// Кнопки причин несут только ID причины, поэтому выбор сверяется с карточкой.
+type pendingRejection struct {
+	UserTaskID int64 `json:"user_task_id"`
+	ChatID     int64 `json:"chat_id"`
+	MessageID  int   `json:"message_id"`
+	Resubmit   bool  `json:"resubmit"` // для своей причины
+}
+
+// registerRejectionCallbacks регистрирует кнопки отклонения, повторной отправки и апелляции
+func (h *Handler) registerRejectionCallbacks(d *callback.Dispatcher) {
+	d.Register(ActionRejectReason, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleRejectReason,
+	})
+	d.Register(ActionRejectCustom, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleRejectCustom,
+	})
+	d.Register(ActionRejectBack, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleRejectBack,
+	})
+	d.Register(ActionResubmit, callback.Action{
+		Authorize: h.authorizeTaskOwner,
+		Handle:    h.handleResubmit,
+	})
+	d.Register(ActionAppeal, callback.Action{
+		Authorize: h.authorizeTaskOwner,
+		Handle:    h.handleAppeal,
+	})
+	d.Register(ActionAppealApprove, callback.Action{
+		Authorize: h.authorizeSenior,
+		Handle:    h.handleAppealApprove,
+	})
+	d.Register(ActionAppealReject, callback.Action{
+		Authorize: h.authorizeSenior,
+		Handle:    h.handleAppealReject,
+	})
+	d.Register(ActionReasonToggle, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleReasonToggle,
+	})
+}
+
+// authorizeSenior разрешает действие только тем, кто рассматривает апелляции
+func (h *Handler) authorizeSenior(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) error {
+	if !h.isSeniorModerator(ctx, q.From.ID) {
+		return callback.ErrForbidden
+	}
+	return nil
+}
+
+// isSeniorModerator проверяет, что администратор рассматривает апелляции.
+// Пока старшие модераторы не назначены, апелляции рассматривают все администраторы.
+func (h *Handler) isSeniorModerator(ctx context.Context, telegramID int64) bool {
+	var senior bool
+	err := h.DB.QueryRowContext(ctx, `
+        SELECT admin AND (senior_moderator OR NOT EXISTS (
+            SELECT 1 FROM users WHERE admin AND senior_moderator))
+        FROM users WHERE telegram_id=$1
+    `, telegramID).Scan(&senior)
+	if err != nil {
+		log.Printf("Ошибка при проверке прав старшего модератора %d: %v", telegramID, err)
+		return false
+	}
+	return senior
+}
+
+// seniorModeratorIDs возвращает Telegram ID тех, кто рассматривает апелляции:
+// старших модераторов, а если их нет - всех администраторов
+func (h *Handler) seniorModeratorIDs(ctx context.Context) ([]int64, error) {
+	ids, err := h.telegramIDs(ctx, "SELECT telegram_id FROM users WHERE admin AND senior_moderator")
+	if err != nil || len(ids) > 0 {
+		return ids, err
+	}
+	return h.adminTelegramIDs(ctx)
+}
+
+// --- Отклонение (модератор) ---
+
+// handleReject показывает на карточке выполнения кнопки причин отклонения
+func (h *Handler) handleReject(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	// Пока модератор выбирает причину, выполнение закреплено за ним
+	err := h.DB.RenewModerationLease(ctx, d.ID, q.From.ID, h.ModerationLease)
+	if done, text := h.moderationResult(q, err); done {
+		return text, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	reasons, err := h.DB.ListRejectionReasons(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	pending := pendingRejection{UserTaskID: d.ID, ChatID: q.Message.Chat.ID, MessageID: q.Message.MessageID}
+	if err := h.DB.SetTempValue(ctx, q.From.ID, tempRejection, pending); err != nil {
+		return "", err
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(q.Message.Chat.ID, q.Message.MessageID, h.reasonButtons(reasons, d.ID))
+	if _, err := h.Bot.Request(edit); err != nil {
+		return "", err
+	}
+	return "Выберите причину отклонения.", nil
+}
+
+func (h *Handler) handleRejectReason(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	pending, text, err := h.pendingRejectionFor(ctx, q)
+	if pending == nil {
+		return text, err
+	}
+	reason, err := h.DB.GetRejectionReason(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	return h.rejectUserTask(ctx, q.From, pending, reason.Text, &reason.ID, reason.AllowResubmit)
+}
+
+// handleRejectCustom запрашивает у модератора свою причину текстом
+func (h *Handler) handleRejectCustom(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	pending, text, err := h.pendingRejectionFor(ctx, q)
+	if pending == nil {
+		return text, err
+	}
+	pending.Resubmit = d.ID == 1
+	if err := h.DB.SetTempValue(ctx, q.From.ID, tempRejection, pending); err != nil {
+		return "", err
+	}
+
+	if !h.transition(ctx, q.Message.Chat.ID, q.From.ID, models.StateAwaitingRejectionText) {
+		return "", nil
+	}
+	prompt := fmt.Sprintf("Напишите причину отклонения выполнения #%d одним сообщением.", pending.UserTaskID)
+	if pending.Resubmit {
+		prompt += " Исполнитель сможет исправить доказательства и отправить их заново."
+	}
+	msg := tgbotapi.NewMessage(q.Message.Chat.ID, prompt)
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+	return "", nil
+}
+
+// handleRejectBack возвращает на карточку кнопки решения
+func (h *Handler) handleRejectBack(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	h.clearTempData(tempRejection)(ctx, q.From.ID)
+	edit := tgbotapi.NewEditMessageReplyMarkup(q.Message.Chat.ID, q.Message.MessageID, h.moderationButtons(d.ID))
+	if _, err := h.Bot.Request(edit); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// HandleRejectionText отклоняет выполнение со своей причиной модератора
+func (h *Handler) HandleRejectionText(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	moderatorID := update.Message.From.ID
+
+	reason := strings.TrimSpace(update.Message.Text)
+	if reason == "" || len([]rune(reason)) > maxReasonLen {
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Причина должна быть непустой и не длиннее %d символов.", maxReasonLen)))
+		return
+	}
+
+	var pending pendingRejection
+	err := h.DB.GetTempValue(ctx, moderatorID, tempRejection, &pending)
+	if err != nil {
+		log.Printf("Ошибка при получении отклоняемого выполнения: %v", err)
+		h.HandleCancel(ctx, update, true)
+		return
+	}
+
+	text, err := h.rejectUserTask(ctx, update.Message.From, &pending, reason, nil, pending.Resubmit)
+	if err != nil {
+		log.Printf("Ошибка при отклонении выполнения %d: %v", pending.UserTaskID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось отклонить задание. Попробуйте снова."))
+		return
+	}
+
+	if err := h.FSM.Finish(ctx, moderatorID); err != nil {
+		log.Printf("Ошибка при сбросе состояния модератора: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+}
+
+// pendingRejectionFor возвращает выполнение, для которого модератор выбирает
+// причину. Если кнопка нажата не на той карточке, возвращает nil и подсказку.
+func (h *Handler) pendingRejectionFor(ctx context.Context, q *tgbotapi.CallbackQuery) (*pendingRejection, string, error) {
+	var pending pendingRejection
+	err := h.DB.GetTempValue(ctx, q.From.ID, tempRejection, &pending)
+	if err != nil && !errors.Is(err, database.ErrTempDataNotFound) {
+		return nil, "", err
+	}
+	if err != nil || pending.ChatID != q.Message.Chat.ID || pending.MessageID != q.Message.MessageID {
+		return nil, "Нажмите «Отклонить» на этой карточке ещё раз.", nil
+	}
+	return &pending, "", nil
+}
+
+// rejectUserTask отклоняет выполнение с причиной. При resubmit исполнитель может
+// исправить доказательства, иначе отклонение окончательное и место задания
+// освобождается.
+func (h *Handler) rejectUserTask(ctx context.Context, moderator *tgbotapi.User, p *pendingRejection, reason string, reasonID *int, resubmit bool) (string, error) {
+	moderatorID := moderator.ID
+	var userTask *models.UserTask
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.RenewModerationLease(ctx, p.UserTaskID, moderatorID, h.ModerationLease); err != nil {
+			return err
+		}
+		var err error
+		userTask, err = tx.GetUserTaskByID(ctx, p.UserTaskID)
+		if err != nil {
+			return err
+		}
+		if resubmit {
+			if err := tx.SetUserTaskStatus(ctx, p.UserTaskID, models.UserTaskCompleted, models.UserTaskResubmit); err != nil {
+				return err
+			}
+			job := StageJob{UserTaskID: p.UserTaskID, Attempt: userTask.Resubmits}
+			if _, err := h.Scheduler.Enqueue(ctx, tx, JobResubmitExpiry, job, time.Now().Add(ResubmitTTL)); err != nil {
+				return err
+			}
+			return recordDecision(ctx, tx, p.UserTaskID, moderatorID, models.DecisionResubmit, reason, reasonID)
+		}
+
+		if err := tx.SetUserTaskStatus(ctx, p.UserTaskID, models.UserTaskCompleted, models.UserTaskRejected); err != nil {
+			return err
+		}
+		if err := releaseSlot(ctx, tx, p.UserTaskID); err != nil {
+			return err
+		}
+		return recordDecision(ctx, tx, p.UserTaskID, moderatorID, models.DecisionRejected, reason, reasonID)
+	})
+	switch {
+	case errors.Is(err, database.ErrStatusChanged):
+		h.clearTempData(tempRejection)(ctx, moderatorID)
+		h.removeInlineKeyboard(p.ChatID, p.MessageID)
+		return "Задание уже проверено.", nil
+	case errors.Is(err, database.ErrLeaseTaken):
+		h.clearTempData(tempRejection)(ctx, moderatorID)
+		h.removeInlineKeyboard(p.ChatID, p.MessageID)
+		return "Это выполнение проверяет другой модератор.", nil
+	case err != nil:
+		return "", err
+	}
+	h.clearTempData(tempRejection)(ctx, moderatorID)
+	h.removeInlineKeyboard(p.ChatID, p.MessageID)
+	log.Printf("Модератор %d отклонил выполнение %d: %s", moderatorID, p.UserTaskID, reason)
+
+	status := "отклонено "
+	if resubmit {
+		status = "возвращено на исправление "
+	}
+	card := &tgbotapi.Message{MessageID: p.MessageID, Chat: &tgbotapi.Chat{ID: p.ChatID}}
+	h.markCopies(ctx, int(p.UserTaskID), card, status+moderatorName(moderator))
+
+	text := fmt.Sprintf("Выполнение задания #%d отклонено.\nПричина: %s", userTask.TaskID, reason)
+	if resubmit {
+		text += fmt.Sprintf("\n\nИсправьте доказательства и отправьте их заново в течение %s.", humanDuration(ResubmitTTL))
+		h.notifyUserWith(ctx, userTask.UserID, text, tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("📎 Отправить заново", ActionResubmit, p.UserTaskID),
+		)))
+		return "Задание возвращено исполнителю на исправление.", nil
+	}
+
+	text += "\n\nЕсли вы не согласны с решением, его можно один раз обжаловать."
+	h.notifyUserWith(ctx, userTask.UserID, text, tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		h.Codec.Button("⚖️ Обжаловать", ActionAppeal, p.UserTaskID),
+	)))
+	return "Задание отклонено.", nil
+}
+
+// reasonButtons - кнопки причин отклонения на карточке выполнения
+func (h *Handler) reasonButtons(reasons []*models.RejectionReason, userTaskID int64) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, r := range reasons {
+		label := r.Text
+		if r.AllowResubmit {
+			label = "🔁 " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button(label, ActionRejectReason, int64(r.ID)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(h.Codec.Button("✍️ Своя причина", ActionRejectCustom, 0)),
+		tgbotapi.NewInlineKeyboardRow(h.Codec.Button("✍️ Своя причина, 🔁 исправить", ActionRejectCustom, 1)),
+		tgbotapi.NewInlineKeyboardRow(h.Codec.Button("↩️ Назад", ActionRejectBack, userTaskID)),
+	)
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// --- Повторная отправка (исполнитель) ---
+
+// handleResubmit возвращает исполнителя на шаг с доказательствами
+func (h *Handler) handleResubmit(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	userTask, err := h.DB.GetUserTaskByID(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	if userTask.Status != models.UserTaskResubmit {
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return "Повторная отправка уже недоступна.", nil
+	}
+	if _, err := h.activeUserTask(ctx, q.From.ID); err == nil {
+		return "Сначала завершите задание, которое выполняете сейчас.", nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	stage, err := h.resubmitStage(ctx, userTask)
+	if err != nil {
+		return "", err
+	}
+	err = h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		resubmits, err := tx.ResubmitUserTask(ctx, d.ID, stage)
+		if err != nil {
+			return err
+		}
+		userTask.Resubmits = resubmits
+		// Новый срок выполнения; срок прежней попытки больше не действует
+		job := StageJob{UserTaskID: d.ID, Attempt: resubmits}
+		_, err = h.Scheduler.Enqueue(ctx, tx, JobAssignmentExpiry, job, time.Now().Add(AssignmentTTL))
+		return err
+	})
+	if errors.Is(err, database.ErrStatusChanged) {
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return "Повторная отправка уже недоступна.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	userTask.Status = models.UserTaskInProgress
+	userTask.CurrentStage = stage
+
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	return "", h.enterStage(ctx, q.Message.Chat.ID, q.From.ID, userTask)
+}
+
+// resubmitStage - шаг, с которого доказательства отправляются заново:
+// последний шаг шаблона, требующий доказательства
+func (h *Handler) resubmitStage(ctx context.Context, userTask *models.UserTask) (int, error) {
+	template, err := h.taskTemplate(ctx, userTask.TaskID)
+	if err != nil {
+		return 0, err
+	}
+	for i := len(template.Steps) - 1; i >= 0; i-- {
+		if template.Steps[i].ProofType != models.ProofNone {
+			return i + 1, nil
+		}
+	}
+	return 1, nil
+}
+
+// --- Апелляция (исполнитель и старший модератор) ---
+
+// handleAppeal запрашивает у исполнителя текст апелляции
+func (h *Handler) handleAppeal(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	userTask, err := h.DB.GetUserTaskByID(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	if userTask.Status != models.UserTaskRejected || userTask.AppealedAt != nil {
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return "Это решение уже нельзя обжаловать.", nil
+	}
+
+	if err := h.DB.SetTempValue(ctx, q.From.ID, tempAppeal, d.ID); err != nil {
+		return "", err
+	}
+	if !h.transition(ctx, q.Message.Chat.ID, q.From.ID, models.StateAwaitingAppealText) {
+		return "", nil
+	}
+	msg := tgbotapi.NewMessage(q.Message.Chat.ID,
+		"Опишите одним сообщением, почему вы не согласны с решением. "+
+			"Апелляцию рассмотрит старший модератор. Обжаловать решение можно только один раз.")
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+	return "", nil
+}
+
+// HandleAppealText отправляет апелляцию старшим модераторам
+func (h *Handler) HandleAppealText(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	telegramID := update.Message.From.ID
+
+	text := strings.TrimSpace(update.Message.Text)
+	if text == "" || len([]rune(text)) > maxReasonLen {
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Текст апелляции должен быть непустым и не длиннее %d символов.", maxReasonLen)))
+		return
+	}
+
+	var userTaskID int64
+	if err := h.DB.GetTempValue(ctx, telegramID, tempAppeal, &userTaskID); err != nil {
+		log.Printf("Ошибка при получении обжалуемого выполнения: %v", err)
+		h.HandleCancel(ctx, update, false)
+		return
+	}
+
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.AppealUserTask(ctx, userTaskID); err != nil {
+			return err
+		}
+		return recordDecision(ctx, tx, userTaskID, 0, models.DecisionAppealed, text, nil)
+	})
+	if err != nil && !errors.Is(err, database.ErrStatusChanged) {
+		log.Printf("Ошибка при сохранении апелляции %d: %v", userTaskID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось отправить апелляцию. Попробуйте снова."))
+		return
+	}
+
+	if err := h.FSM.Finish(ctx, telegramID); err != nil {
+		log.Printf("Ошибка при сбросе состояния пользователя: %v", err)
+	}
+	reply := "Апелляция отправлена. Мы сообщим о решении."
+	if err != nil {
+		reply = "Это решение уже нельзя обжаловать."
+	}
+	msg := tgbotapi.NewMessage(chatID, reply)
+	msg.ReplyMarkup = h.Keyboard
+	h.Bot.Send(msg)
+	if err == nil {
+		log.Printf("Пользователь %d обжаловал отклонение выполнения %d", telegramID, userTaskID)
+		h.sendAppeal(ctx, userTaskID)
+	}
+}
+
+// sendAppeal отправляет обжалованное выполнение старшим модераторам
+func (h *Handler) sendAppeal(ctx context.Context, userTaskID int64) {
+	userTask, err := h.DB.GetUserTaskByID(ctx, userTaskID)
+	if err != nil {
+		log.Printf("Ошибка при получении выполнения %d: %v", userTaskID, err)
+		return
+	}
+	ids, err := h.seniorModeratorIDs(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении старших модераторов: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := h.sendSubmission(ctx, id, userTask, h.appealButtons(userTaskID)); err != nil {
+			log.Printf("Ошибка при отправке апелляции %d модератору %d: %v", userTaskID, id, err)
+		}
+	}
+}
+
+func (h *Handler) appealButtons(userTaskID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		h.Codec.Button("✅ Одобрить", ActionAppealApprove, userTaskID),
+		h.Codec.Button("❌ Оставить отклонение", ActionAppealReject, userTaskID),
+	))
+}
+
+// HandleAdminAppeals присылает старшему модератору нерассмотренные апелляции,
+// начиная с самых старых
+func (h *Handler) HandleAdminAppeals(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	if !h.isSeniorModerator(ctx, update.Message.From.ID) {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Апелляции рассматривают старшие модераторы."))
+		return
+	}
+
+	ids, err := h.DB.ListAppealedUserTasks(ctx, maxAppealsShown)
+	if err != nil {
+		log.Printf("Ошибка при получении апелляций: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить апелляции."))
+		return
+	}
+	if len(ids) == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Нерассмотренных апелляций нет."))
+		return
+	}
+
+	h.Bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Нерассмотренные апелляции: %d (показаны самые старые).", len(ids))))
+	for _, id := range ids {
+		userTask, err := h.DB.GetUserTaskByID(ctx, id)
+		if err == nil {
+			err = h.sendSubmission(ctx, chatID, userTask, h.appealButtons(id))
+		}
+		if err != nil {
+			log.Printf("Ошибка при отправке апелляции %d: %v", id, err)
+		}
+	}
+}
+
+// HandleAdminSenior назначает администратора старшим модератором или снимает назначение
+func (h *Handler) HandleAdminSenior(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) != 2 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, seniorUsage))
+		return
+	}
+	telegramID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, seniorUsage))
+		return
+	}
+	var senior bool
+	switch strings.ToLower(args[1]) {
+	case "да":
+		senior = true
+	case "нет":
+	default:
+		h.Bot.Send(tgbotapi.NewMessage(chatID, seniorUsage))
+		return
+	}
+
+	err = h.DB.SetSeniorModerator(ctx, telegramID, senior)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Администратор не найден."))
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при назначении старшего модератора %d: %v", telegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить назначение."))
+		return
+	}
+	log.Printf("Администратор %d: старший модератор %d - %v", update.Message.From.ID, telegramID, senior)
+	text := fmt.Sprintf("Администратор %d назначен старшим модератором.", telegramID)
+	if !senior {
+		text = fmt.Sprintf("Администратор %d больше не старший модератор.", telegramID)
+	}
+	h.Bot.Send(tgbotapi.NewMessage(chatID, text))
+}
+
+func (h *Handler) handleAppealApprove(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	var userTask *models.UserTask
+	var earnings []referral.Earning
+
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.CheckAppealUserLimit(ctx, d.ID); err != nil {
+			return err
+		}
+		var err error
+		userTask, earnings, err = h.approveUserTask(ctx, tx, d.ID, models.UserTaskAppealed)
+		if err != nil {
+			return err
+		}
+		// Место было освобождено при отклонении
+		if err := tx.RetakeTaskSlot(ctx, int64(userTask.TaskID), userTask.Reward); err != nil {
+			return err
+		}
+		return recordDecision(ctx, tx, d.ID, q.From.ID, models.DecisionAppealApproved, "", nil)
+	})
+	if errors.Is(err, database.ErrStatusChanged) {
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return "Апелляция уже рассмотрена.", nil
+	}
+	if errors.Is(err, database.ErrTaskUserLimit) {
+		return "Исполнитель уже выполняет это задание снова, лимит на пользователя исчерпан. Апелляцию можно только отклонить.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	h.markCopies(ctx, userTask.ID, q.Message, "апелляция удовлетворена "+moderatorName(q.From))
+	log.Printf("Старший модератор %d удовлетворил апелляцию по выполнению %d", q.From.ID, d.ID)
+	h.notifyUser(ctx, userTask.UserID, fmt.Sprintf(
+		"Апелляция удовлетворена! Задание #%d одобрено, вам начислено %s.", userTask.TaskID, userTask.Reward))
+	for _, e := range earnings {
+		h.notifyUser(ctx, e.ReferrerID, referralEarningText(e))
+	}
+	return "Апелляция удовлетворена.", nil
+}
+
+func (h *Handler) handleAppealReject(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.SetUserTaskStatus(ctx, d.ID, models.UserTaskAppealed, models.UserTaskRejected); err != nil {
+			return err
+		}
+		return recordDecision(ctx, tx, d.ID, q.From.ID, models.DecisionAppealRejected, "", nil)
+	})
+	if errors.Is(err, database.ErrStatusChanged) {
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return "Апелляция уже рассмотрена.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	userTask, err := h.DB.GetUserTaskByID(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+	h.markCopies(ctx, userTask.ID, q.Message, "апелляция отклонена "+moderatorName(q.From))
+	log.Printf("Старший модератор %d отклонил апелляцию по выполнению %d", q.From.ID, d.ID)
+	h.notifyUser(ctx, userTask.UserID, fmt.Sprintf(
+		"Апелляция по заданию #%d отклонена. Решение окончательное.", userTask.TaskID))
+	return "Отклонение оставлено в силе.", nil
+}
+
+// --- Причины отклонения (администратор) ---
+
+// HandleAdminRejectionReasons показывает причины отклонения и запрашивает новую
+func (h *Handler) HandleAdminRejectionReasons(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	reasons, err := h.DB.ListRejectionReasons(ctx, false)
+	if err != nil {
+		log.Printf("Ошибка при получении причин отклонения: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить список причин."))
+		return
+	}
+
+	if len(reasons) > 0 {
+		msg := tgbotapi.NewMessage(chatID, reasonsText(reasons))
+		msg.ReplyMarkup = h.reasonToggleButtons(reasons)
+		h.Bot.Send(msg)
+	}
+
+	if !h.transition(ctx, chatID, update.Message.From.ID, models.StateAwaitingReasonInput) {
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID,
+		"Чтобы добавить причину, отправьте строку:\n"+
+			"текст причины; исправление\n"+
+			"Исправление - «да», если исполнитель может отправить доказательства заново.\n"+
+			"Например: Не виден текст отзыва; да")
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+}
+
+// HandleReasonInput добавляет причину отклонения
+func (h *Handler) HandleReasonInput(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+
+	text, resubmit, _ := strings.Cut(update.Message.Text, ";")
+	reason := &models.RejectionReason{Text: strings.TrimSpace(text)}
+	if reason.Text == "" || len([]rune(reason.Text)) > maxReasonLen {
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Текст причины должен быть непустым и не длиннее %d символов.", maxReasonLen)))
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(resubmit)) {
+	case "", "нет":
+	case "да":
+		reason.AllowResubmit = true
+	default:
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "После «;» укажите «да» или «нет»."))
+		return
+	}
+
+	if err := h.DB.CreateRejectionReason(ctx, reason); err != nil {
+		log.Printf("Ошибка при сохранении причины отклонения: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить причину."))
+		return
+	}
+	log.Printf("Администратор %d добавил причину отклонения %d", adminID, reason.ID)
+
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, "Причина добавлена: "+reasonLine(reason))
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+}
+
+func (h *Handler) handleReasonToggle(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	reason, err := h.DB.GetRejectionReason(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := h.DB.SetRejectionReasonActive(ctx, d.ID, !reason.IsActive); err != nil {
+		return "", err
+	}
+	log.Printf("Администратор %d: причина отклонения %d активна = %t", q.From.ID, reason.ID, !reason.IsActive)
+
+	reasons, err := h.DB.ListRejectionReasons(ctx, false)
+	if err == nil {
+		edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, reasonsText(reasons))
+		markup := h.reasonToggleButtons(reasons)
+		edit.ReplyMarkup = &markup
+		h.Bot.Send(edit)
+	}
+
+	if reason.IsActive {
+		return "Причина скрыта.", nil
+	}
+	return "Причина снова доступна.", nil
+}
+
+// reasonToggleButtons - кнопки скрытия и показа причин
+func (h *Handler) reasonToggleButtons(reasons []*models.RejectionReason) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, r := range reasons {
+		label := fmt.Sprintf("🙈 Скрыть #%d", r.ID)
+		if !r.IsActive {
+			label = fmt.Sprintf("👁 Показать #%d", r.ID)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button(label, ActionReasonToggle, int64(r.ID)),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// reasonsText - список причин отклонения
+func reasonsText(reasons []*models.RejectionReason) string {
+	var b strings.Builder
+	b.WriteString("Причины отклонения (🔁 - можно исправить и отправить заново):")
+	for _, r := range reasons {
+		status := ""
+		if !r.IsActive {
+			status = " (скрыта)"
+		}
+		fmt.Fprintf(&b, "\n#%d %s%s", r.ID, reasonLine(r), status)
+	}
+	return b.String()
+}
+
+func reasonLine(r *models.RejectionReason) string {
+	if r.AllowResubmit {
+		return "🔁 " + r.Text
+	}
+	return r.Text
+}
@@ -0,0 +1,403 @@
+// handlers/stages.go
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/fsm"
+	"telegram_bot/imagehash"
+	"telegram_bot/models"
+	"telegram_bot/tgfile"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fallbackTemplate используется, если у категории нет шаблона
+var fallbackTemplate = &models.TaskTemplate{
+	Name: "Без шагов",
+	Steps: []models.TemplateStep{{
+		Position:    1,
+		Instruction: "Выполните задание по описанию и нажмите «Далее».",
+		ProofType:   models.ProofNone,
+		ProofCount:  1,
+	}},
+}
+
+// taskTemplate возвращает шаблон шагов задания
+func (h *Handler) taskTemplate(ctx context.Context, taskID int) (*models.TaskTemplate, error) {
+	task, err := h.DB.GetTaskByID(ctx, int64(taskID))
+	if err != nil {
+		return nil, err
+	}
+
+	var template *models.TaskTemplate
+	if task.TemplateID != nil {
+		template, err = h.DB.GetTemplate(ctx, *task.TemplateID)
+	} else {
+		template, err = h.DB.GetDefaultTemplate(ctx, task.Category)
+	}
+	if errors.Is(err, database.ErrTemplateNotFound) || (err == nil && len(template.Steps) == 0) {
+		return fallbackTemplate, nil
+	}
+	return template, err
+}
+
+// currentStep возвращает шаг, на котором находится пользователь.
+// ok = false, если все шаги пройдены.
+func (h *Handler) currentStep(ctx context.Context, userTask *models.UserTask) (step models.TemplateStep, total int, ok bool, err error) {
+	template, err := h.taskTemplate(ctx, userTask.TaskID)
+	if err != nil {
+		return step, 0, false, err
+	}
+	total = len(template.Steps)
+	if userTask.CurrentStage < 1 || userTask.CurrentStage > total {
+		return step, total, false, nil
+	}
+	return template.Steps[userTask.CurrentStage-1], total, true, nil
+}
+
+// enterStage открывает текущий шаг: планирует срок его выполнения
+// и присылает инструкцию
+func (h *Handler) enterStage(ctx context.Context, chatID, telegramID int64, userTask *models.UserTask) error {
+	step, _, ok, err := h.currentStep(ctx, userTask)
+	if err != nil {
+		return err
+	}
+	if ok && step.Deadline > 0 {
+		job := StageJob{UserTaskID: int64(userTask.ID), Stage: userTask.CurrentStage, Attempt: userTask.Resubmits}
+		if _, err := h.Scheduler.Enqueue(ctx, nil, JobStageDeadline, job, time.Now().Add(step.Deadline)); err != nil {
+			return err
+		}
+	}
+	h.SendTaskStage(ctx, chatID, telegramID, userTask)
+	return nil
+}
+
+// SendTaskStage присылает инструкцию текущего шага. Если шаги закончились,
+// задание отправляется на проверку.
+func (h *Handler) SendTaskStage(ctx context.Context, chatID, telegramID int64, userTask *models.UserTask) {
+	step, total, ok, err := h.currentStep(ctx, userTask)
+	if err != nil {
+		log.Println("Ошибка при получении шага задания:", err)
+		return
+	}
+
+	if !ok {
+		// Задание сдано: статус completed и автоматическая проверка по правилам
+		err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+			if err := tx.SetUserTaskStatus(ctx, int64(userTask.ID), models.UserTaskInProgress, models.UserTaskCompleted); err != nil {
+				return err
+			}
+			job := StageJob{UserTaskID: int64(userTask.ID), Attempt: userTask.Resubmits}
+			_, err := h.Scheduler.Enqueue(ctx, tx, JobTriage, job, time.Now())
+			return err
+		})
+		if err != nil {
+			log.Println("Ошибка при обновлении статуса задания:", err)
+			return
+		}
+		// Уведомить пользователя о проверке
+		h.NotifyUserForVerification(ctx, userTask.UserID, userTask.TaskID)
+		return
+	}
+
+	text := fmt.Sprintf("Шаг %d из %d\n\n%s", step.Position, total, step.Instruction)
+	if step.Deadline > 0 {
+		text += fmt.Sprintf("\n\nСрок выполнения шага: %s.", humanDuration(step.Deadline))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	if step.ProofType == models.ProofNone {
+		button := h.Codec.Button("Далее", ActionNextStage, int64(userTask.ID))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(button))
+		h.Bot.Send(msg)
+		return
+	}
+
+	// Шаг с доказательством: ждём сообщение пользователя
+	msg.Text += "\n\n" + proofPrompt(step)
+	button := h.Codec.Button("Продолжить", ActionResume, int64(userTask.ID))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(button))
+	if state, err := h.FSM.Current(ctx, telegramID); err == nil &&
+		state != models.StateNone && state != models.StateAwaitingTaskProof {
+		// Пользователь в другом диалоге: шаг ждёт, пока он нажмёт «Продолжить»
+		msg.Text += "\n\nСначала завершите текущее действие или нажмите «" + fsm.CancelText +
+			"», затем нажмите «Продолжить»."
+		h.Bot.Send(msg)
+		return
+	}
+	if !h.transition(ctx, chatID, telegramID, models.StateAwaitingTaskProof) {
+		return
+	}
+	h.Bot.Send(msg)
+}
+
+// advanceStage переводит задание на следующий шаг. Возвращает текст для
+// пользователя, если шаг уже пройден другим запросом.
+func (h *Handler) advanceStage(ctx context.Context, chatID, telegramID int64, userTask *models.UserTask) (string, error) {
+	// Условное обновление: повторное нажатие той же кнопки не сдвинет этап дважды
+	result, err := h.DB.ExecContext(ctx, `
+        UPDATE user_tasks SET current_stage = current_stage + 1, last_updated = NOW()
+        WHERE id = $1 AND current_stage = $2 AND status = $3
+    `, userTask.ID, userTask.CurrentStage, models.UserTaskInProgress)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при обновлении этапа задания: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return "", err
+	} else if n == 0 {
+		return "Этот этап уже пройден.", nil
+	}
+	userTask.CurrentStage++
+
+	// Шаг с задержкой откроется по расписанию, остальные - сразу
+	step, _, ok, err := h.currentStep(ctx, userTask)
+	if err != nil {
+		return "", err
+	}
+	if ok && step.Delay > 0 {
+		if err := h.lockStage(ctx, telegramID, userTask, step.Delay); err != nil {
+			return "", err
+		}
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Следующий шаг станет доступен через %s. Мы пришлём уведомление.", humanDuration(step.Delay))))
+		return "", nil
+	}
+	return "", h.enterStage(ctx, chatID, telegramID, userTask)
+}
+
+func (h *Handler) handleResumeStage(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	userTask, err := h.DB.GetUserTaskByID(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	if userTask.Status != models.UserTaskInProgress {
+		return "Задание уже завершено.", nil
+	}
+	if availableAt, err := h.DB.GetUserAvailableAt(ctx, q.From.ID); err == nil && time.Now().Before(availableAt) {
+		return fmt.Sprintf("Шаг ещё закрыт. Подождите %s.", humanDuration(time.Until(availableAt))), nil
+	}
+	h.SendTaskStage(ctx, q.Message.Chat.ID, q.From.ID, userTask)
+	return "", nil
+}
+
+// HandleTaskProof принимает доказательство выполнения текущего шага
+func (h *Handler) HandleTaskProof(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	telegramID := update.Message.From.ID
+
+	userTask, err := h.activeUserTask(ctx, telegramID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Задание сняли, пока пользователь был в диалоге доказательств
+		if err := h.FSM.Cancel(ctx, telegramID); err != nil {
+			log.Printf("Ошибка при сбросе состояния пользователя: %v", err)
+		}
+		msg := tgbotapi.NewMessage(chatID, "У вас нет задания в работе.")
+		msg.ReplyMarkup = h.Keyboard
+		h.Bot.Send(msg)
+		return
+	}
+	if err != nil {
+		log.Println("Ошибка при получении активного задания:", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось найти активное задание."))
+		return
+	}
+
+	if availableAt, err := h.DB.GetUserAvailableAt(ctx, telegramID); err == nil && time.Now().Before(availableAt) {
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Шаг ещё закрыт. Подождите %s.", humanDuration(time.Until(availableAt)))))
+		return
+	}
+
+	step, _, ok, err := h.currentStep(ctx, userTask)
+	if err != nil {
+		log.Println("Ошибка при получении шага задания:", err)
+		return
+	}
+	if !ok || step.ProofType == models.ProofNone {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "На этом шаге доказательство не требуется."))
+		return
+	}
+
+	// Кнопки меню остаются на экране во время шага и не считаются ответом
+	if step.ProofType == models.ProofText && h.isMenuButton(update.Message.Text) {
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			"Сейчас ожидается ответ на шаг задания. Пришлите его текстом или нажмите «"+fsm.CancelText+"», чтобы выйти."))
+		return
+	}
+
+	proof, problem := readProof(step, update.Message)
+	if problem != "" {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, problem))
+		return
+	}
+	proof.UserTaskID = userTask.ID
+	proof.Stage = userTask.CurrentStage
+
+	count, err := h.DB.AddUserTaskProof(ctx, proof)
+	if err != nil {
+		log.Println("Ошибка при сохранении доказательства:", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить. Попробуйте снова."))
+		return
+	}
+	if proof.Kind.IsPhoto() {
+		// Скачивание и хэширование не задерживают обработку сообщений пользователя.
+		// Без задачи хэш досчитает проверка или команда proofs phash.
+		job := ProofHashJob{ProofID: proof.ID, FileID: proof.FileID}
+		if _, err := h.Scheduler.Enqueue(ctx, nil, JobProofHash, job, time.Now()); err != nil {
+			log.Printf("Ошибка при планировании хэша скриншота %d: %v", proof.ID, err)
+		}
+	}
+	if count < step.ProofCount {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Получено %d из %d.", count, step.ProofCount)))
+		return
+	}
+
+	// Шаг выполнен: выходим из ожидания и переходим дальше
+	if err := h.FSM.Finish(ctx, telegramID); err != nil {
+		log.Println("Ошибка при сбросе состояния пользователя:", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, "Принято!")
+	msg.ReplyMarkup = h.Keyboard
+	h.Bot.Send(msg)
+
+	if text, err := h.advanceStage(ctx, chatID, telegramID, userTask); err != nil {
+		log.Println("Ошибка при переходе к следующему шагу:", err)
+	} else if text != "" {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, text))
+	}
+}
+
+// activeUserTask возвращает задание, которое пользователь выполняет сейчас
+func (h *Handler) activeUserTask(ctx context.Context, telegramID int64) (*models.UserTask, error) {
+	var userTaskID int64
+	err := h.DB.QueryRowContext(ctx, `
+        SELECT user_tasks.id
+        FROM user_tasks
+        JOIN users ON users.id = user_tasks.user_id
+        WHERE users.telegram_id = $1 AND user_tasks.status = $2
+        ORDER BY user_tasks.last_updated DESC
+        LIMIT 1
+    `, telegramID, models.UserTaskInProgress).Scan(&userTaskID)
+	if err != nil {
+		return nil, err
+	}
+	return h.DB.GetUserTaskByID(ctx, userTaskID)
+}
+
+// readProof проверяет, что сообщение подходит под вид доказательства шага.
+// Возвращает подсказку пользователю, если не подходит.
+func readProof(step models.TemplateStep, m *tgbotapi.Message) (*models.UserTaskProof, string) {
+	switch step.ProofType {
+	case models.ProofScreenshot, models.ProofScreenshots:
+		if len(m.Photo) == 0 {
+			return nil, "Пожалуйста, отправьте скриншот."
+		}
+		// Самый большой размер фотографии
+		photo := m.Photo[len(m.Photo)-1]
+		return &models.UserTaskProof{Kind: step.ProofType, FileID: photo.FileID, UniqueID: photo.FileUniqueID}, ""
+	case models.ProofText:
+		text := strings.TrimSpace(m.Text)
+		if text == "" {
+			return nil, "Пожалуйста, отправьте ответ текстом."
+		}
+		return &models.UserTaskProof{Kind: step.ProofType, Content: text}, ""
+	case models.ProofLink:
+		link := strings.TrimSpace(m.Text)
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, "Пожалуйста, отправьте ссылку, начинающуюся с http:// или https://."
+		}
+		return &models.UserTaskProof{Kind: step.ProofType, Content: link}, ""
+	}
+	return nil, "На этом шаге доказательство не требуется."
+}
+
+// runProofHash скачивает скриншот и сохраняет его перцептивный хэш для
+// поиска повторно присланных изображений. Ошибка приводит к повтору задачи.
+func (h *Handler) runProofHash(ctx context.Context, job ProofHashJob) error {
+	hash, err := h.proofHash(ctx, job.FileID)
+	if err != nil {
+		return err
+	}
+	return h.DB.SetProofHash(ctx, job.ProofID, hash)
+}
+
+// hashProofs досчитывает хэши скриншотов выполнения, задачи которых ещё
+// не отработали, чтобы проверка сравнивала все скриншоты. Ошибки только
+// логируются: скриншот без хэша просто не участвует в сравнении.
+func (h *Handler) hashProofs(ctx context.Context, userTaskID int) {
+	proofs, err := h.DB.ListUserTaskProofs(ctx, userTaskID)
+	if err != nil {
+		log.Printf("Ошибка при получении скриншотов выполнения %d: %v", userTaskID, err)
+		return
+	}
+	for _, p := range proofs {
+		if !p.Kind.IsPhoto() || p.PHash != nil || p.FileID == "" {
+			continue
+		}
+		hash, err := h.proofHash(ctx, p.FileID)
+		if err == nil {
+			err = h.DB.SetProofHash(ctx, p.ID, hash)
+		}
+		if err != nil {
+			log.Printf("Скриншот %d: %v", p.ID, err)
+		}
+	}
+}
+
+// proofHash скачивает скриншот и вычисляет его перцептивный хэш
+func (h *Handler) proofHash(ctx context.Context, fileID string) (uint64, error) {
+	fileURL, err := tgfile.DirectURL(h.Bot, fileID)
+	if err != nil {
+		return 0, err
+	}
+	hash, err := imagehash.Fetch(ctx, fileURL)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при вычислении хэша скриншота: %w", err)
+	}
+	return hash, nil
+}
+
+// proofPrompt - что пользователь должен прислать на шаге
+func proofPrompt(step models.TemplateStep) string {
+	switch step.ProofType {
+	case models.ProofScreenshot:
+		return "📎 Пришлите скриншот."
+	case models.ProofScreenshots:
+		return fmt.Sprintf("📎 Пришлите скриншоты: %d шт.", step.ProofCount)
+	case models.ProofText:
+		return "📎 Пришлите ответ текстом."
+	case models.ProofLink:
+		return "📎 Пришлите ссылку."
+	}
+	return ""
+}
+
+// runStageDeadline снимает задание, если шаг не выполнен в срок
+func (h *Handler) runStageDeadline(ctx context.Context, job StageJob) error {
+	userTask, err := h.DB.GetUserTaskByID(ctx, job.UserTaskID)
+	if err != nil {
+		return err
+	}
+	if userTask.Status != models.UserTaskInProgress || userTask.CurrentStage != job.Stage || userTask.Resubmits != job.Attempt {
+		return nil
+	}
+
+	expired, err := h.expireUserTask(ctx, userTask, fmt.Sprintf(
+		"Шаг %d не выполнен в срок, задание снято с вас. Вы можете взять новое задание.", job.Stage))
+	if err != nil || !expired {
+		return err
+	}
+	log.Printf("Шаг %d задания пользователя %d не выполнен в срок", job.Stage, userTask.ID)
+	return nil
+}
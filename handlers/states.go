@@ -0,0 +1,300 @@
+// handlers/states.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"telegram_bot/database"
+	"telegram_bot/fsm"
+	"telegram_bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newStateMachine описывает многошаговые диалоги бота
+func (h *Handler) newStateMachine() *fsm.Machine {
+	m := fsm.New(h.DB)
+
+	// Мастер добавления задания (администратор)
+	m.Add(fsm.State{
+		Name: models.StateAwaitingTaskCategory,
+		Prompt: "Пожалуйста, выберите категорию кнопкой на клавиатуре " +
+			"или нажмите «" + fsm.CancelText + "».",
+		Inputs:      []fsm.Input{fsm.InputText},
+		ChoicesFunc: h.categoryChoices,
+		Next:        []models.State{models.StateAwaitingTaskLink},
+		Timeout:     30 * time.Minute,
+	})
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingTaskLink,
+		Prompt:  "Отправьте ссылку текстом.",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Next:    []models.State{models.StateAwaitingTaskDescription, models.StateAwaitingTaskConfirm},
+		Timeout: 30 * time.Minute,
+	})
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingTaskDescription,
+		Prompt:  "Введите описание задания текстом.",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Next:    []models.State{models.StateAwaitingTaskExample, models.StateAwaitingTaskConfirm},
+		Timeout: 30 * time.Minute,
+	})
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingTaskExample,
+		Prompt:  "Пришлите скриншот или нажмите «" + skipText + "».",
+		Inputs:  []fsm.Input{fsm.InputText, fsm.InputPhoto},
+		Next:    []models.State{models.StateAwaitingTaskReward, models.StateAwaitingTaskConfirm},
+		Timeout: 30 * time.Minute,
+	})
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingTaskReward,
+		Prompt:  "Отправьте параметры текстом или нажмите «" + skipText + "».",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Next:    []models.State{models.StateAwaitingTaskConfirm},
+		Timeout: 30 * time.Minute,
+	})
+	m.Add(fsm.State{
+		Name:   models.StateAwaitingTaskConfirm,
+		Prompt: "Опубликуйте, измените или отмените задание кнопками под предпросмотром.",
+		Inputs: []fsm.Input{fsm.InputText},
+		Next: []models.State{
+			models.StateAwaitingTaskLink, models.StateAwaitingTaskDescription,
+			models.StateAwaitingTaskExample, models.StateAwaitingTaskReward,
+		},
+		Timeout: 30 * time.Minute,
+	})
+
+	// Выполнение задания (пользователь)
+	m.Add(fsm.State{
+		Name:        models.StateawaitingTaskCategoryUser,
+		Prompt:      "Пожалуйста, выберите тип задания кнопкой на клавиатуре.",
+		Inputs:      []fsm.Input{fsm.InputText},
+		ChoicesFunc: h.categoryChoices,
+		Timeout:     15 * time.Minute,
+	})
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingTaskProof,
+		Prompt:  "Пожалуйста, отправьте доказательство выполнения шага.",
+		Inputs:  []fsm.Input{fsm.InputText, fsm.InputPhoto},
+		Timeout: AssignmentTTL,
+	})
+
+	// Отклонение и апелляция
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingRejectionText,
+		Prompt:  "Напишите причину отклонения текстом или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Timeout: 30 * time.Minute,
+		OnExit:  h.clearTempData(tempRejection),
+	})
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingAppealText,
+		Prompt:  "Опишите текстом, почему вы не согласны с решением, или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Timeout: 30 * time.Minute,
+		OnExit:  h.clearTempData(tempAppeal),
+	})
+
+	// Вывод средств. Сумма и способ выплаты сохраняются до перехода в
+	// следующее состояние, поэтому удаляются только при выходе из диалога.
+	clearWithdrawal := h.clearTempData(tempWithdrawAmount, tempPayoutKind)
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingWithdrawalAmount,
+		Prompt:  "Пожалуйста, введите сумму вывода текстом.",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Next:    []models.State{models.StateAwaitingPayoutMethod},
+		Timeout: 15 * time.Minute,
+		OnLeave: clearWithdrawal,
+	})
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingPayoutMethod,
+		Prompt:  "Пожалуйста, выберите реквизиты кнопкой.",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Next:    []models.State{models.StateAwaitingCardNumder},
+		Timeout: 15 * time.Minute,
+		OnLeave: clearWithdrawal,
+	})
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingCardNumder,
+		Prompt:  "Пожалуйста, введите реквизиты текстом.",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Timeout: 15 * time.Minute,
+		OnLeave: clearWithdrawal,
+	})
+
+	// Загрузка результатов выплат (администратор)
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingPayoutResults,
+		Prompt:  "Отправьте CSV-файл документом или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputDocument},
+		Timeout: 30 * time.Minute,
+	})
+
+	// Загрузка заданий из файла (администратор)
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingTaskImport,
+		Prompt:  "Отправьте CSV- или JSON-файл документом или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputDocument},
+		Timeout: 30 * time.Minute,
+		OnExit:  h.clearTempData(tempTaskImportDryRun),
+	})
+
+	// Управление категориями (администратор)
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingCategoryInput,
+		Prompt:  "Отправьте категорию строкой «slug; название; награда» или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Timeout: 30 * time.Minute,
+	})
+
+	// Шаблоны заданий (администратор)
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingTemplateInput,
+		Prompt:  "Отправьте шаблон: первая строка «slug; название», далее шаги по одному в строке, или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Timeout: 30 * time.Minute,
+	})
+
+	// Причины отклонения (администратор)
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingReasonInput,
+		Prompt:  "Отправьте причину строкой «текст; исправление» или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Timeout: 30 * time.Minute,
+	})
+
+	// Правила автоматической проверки (администратор)
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingTriageRule,
+		Prompt:  "Отправьте правило строкой «категория; действие; условие; ...» или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Timeout: 30 * time.Minute,
+	})
+
+	// Настройка реферальных уровней (администратор)
+	m.Add(fsm.State{
+		Name:    models.StateAwaitingReferralTier,
+		Prompt:  "Введите уровень текстом, например «2 3 500», или нажмите «" + fsm.CancelText + "».",
+		Inputs:  []fsm.Input{fsm.InputText},
+		Timeout: 30 * time.Minute,
+	})
+
+	return m
+}
+
+// clearTempData возвращает хук, удаляющий временные данные по ключам
+func (h *Handler) clearTempData(keys ...string) fsm.Hook {
+	return func(ctx context.Context, telegramID int64) error {
+		for _, key := range keys {
+			err := h.DB.DeleteTempData(ctx, telegramID, key)
+			if err != nil && !errors.Is(err, database.ErrTempDataNotFound) {
+				log.Printf("Ошибка при удалении временных данных %q: %v", key, err)
+			}
+		}
+		return nil
+	}
+}
+
+// GuardState выполняет общие для всех состояний проверки: универсальную
+// отмену, истечение времени ожидания и допустимость ввода.
+// Возвращает true, если сообщение обработано и дальше его передавать не нужно.
+func (h *Handler) GuardState(ctx context.Context, update tgbotapi.Update, state models.State, isAdmin bool) bool {
+	if state == models.StateNone || update.Message == nil {
+		return false
+	}
+	telegramID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if update.Message.Text == fsm.CancelText {
+		h.HandleCancel(ctx, update, isAdmin)
+		return true
+	}
+
+	expired, err := h.FSM.Expired(ctx, telegramID, state)
+	if err != nil {
+		log.Printf("Ошибка при проверке времени ожидания: %v", err)
+	}
+	if expired {
+		if err := h.FSM.Cancel(ctx, telegramID); err != nil {
+			log.Printf("Ошибка при сбросе состояния: %v", err)
+		}
+		msg := tgbotapi.NewMessage(chatID, "Время ожидания ответа истекло, действие отменено.")
+		msg.ReplyMarkup = h.menuFor(isAdmin)
+		h.Bot.Send(msg)
+		return true
+	}
+
+	if prompt, ok := h.FSM.Accepts(ctx, state, update.Message); !ok {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, prompt))
+		return true
+	}
+	return false
+}
+
+// HandleCancel выходит из любого многошагового диалога
+func (h *Handler) HandleCancel(ctx context.Context, update tgbotapi.Update, isAdmin bool) {
+	if err := h.FSM.Cancel(ctx, update.Message.From.ID); err != nil {
+		log.Printf("Ошибка при отмене действия: %v", err)
+	}
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Действие отменено.")
+	msg.ReplyMarkup = h.menuFor(isAdmin)
+	h.Bot.Send(msg)
+}
+
+// transition переводит пользователя в новое состояние и сообщает ему,
+// если переход недопустим. Возвращает false при ошибке.
+func (h *Handler) transition(ctx context.Context, chatID, telegramID int64, to models.State) bool {
+	err := h.FSM.Transition(ctx, telegramID, to)
+	if err == nil {
+		return true
+	}
+
+	var te *fsm.TransitionError
+	if errors.As(err, &te) {
+		log.Printf("Пользователь %d: %v", telegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID,
+			"Это действие сейчас недоступно: сначала завершите текущее. "+
+				"Нажмите «"+fsm.CancelText+"», чтобы выйти из него."))
+		return false
+	}
+
+	log.Printf("Ошибка при установке состояния: %v", err)
+	h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка при установке состояния."))
+	return false
+}
+
+func (h *Handler) menuFor(isAdmin bool) tgbotapi.ReplyKeyboardMarkup {
+	if isAdmin {
+		return h.AdminMenu
+	}
+	return h.Keyboard
+}
+
+// isMenuButton сообщает, совпадает ли текст с кнопкой меню пользователя
+// или администратора
+func (h *Handler) isMenuButton(text string) bool {
+	return keyboardHas(h.Keyboard, text) || keyboardHas(h.AdminMenu, text)
+}
+
+func keyboardHas(kb tgbotapi.ReplyKeyboardMarkup, text string) bool {
+	for _, row := range kb.Keyboard {
+		for _, b := range row {
+			if b.Text == text {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cancelKeyboard - клавиатура с единственной кнопкой отмены
+func cancelKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton(fsm.CancelText),
+		),
+	)
+}
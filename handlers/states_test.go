@@ -0,0 +1,33 @@
+// handlers/states_test.go
+package handlers
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestKeyboardHas(t *testing.T) {
+	kb := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Показать баланс"),
+			tgbotapi.NewKeyboardButton("Личный кабинет"),
+		),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("Мои рефералы")),
+	)
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Показать баланс", true},
+		{"Мои рефералы", true},
+		{"показать баланс", false},
+		{"Мой ответ", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := keyboardHas(kb, tt.text); got != tt.want {
+			t.Errorf("keyboardHas(%q) = %v, ожидалось %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// handlers/task_import.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"telegram_bot/database"
+	"telegram_bot/models"
+	"telegram_bot/taskimport"
+	"telegram_bot/tgfile"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// tempTaskImportDryRun - ключ временных данных: загрузка только для проверки
+const tempTaskImportDryRun = "task_import_dry_run"
+
+// maxImportFileSize - предельный размер файла заданий
+const maxImportFileSize = 5 << 20
+
+// reportLimit - сколько заданий и ошибок перечислять в отчёте в чате
+const reportLimit = 10
+
+// maxMessageLen - предельная длина текста сообщения Telegram
+const maxMessageLen = 4096
+
+// HandleAdminTaskImport запрашивает файл заданий.
+// /tasks_import dry - только проверить файл, ничего не создавая.
+func (h *Handler) HandleAdminTaskImport(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+	dryRun := strings.EqualFold(strings.TrimSpace(update.Message.CommandArguments()), "dry")
+
+	if !h.transition(ctx, chatID, adminID, models.StateAwaitingTaskImport) {
+		return
+	}
+	if err := h.DB.SetTempValue(ctx, adminID, tempTaskImportDryRun, dryRun); err != nil {
+		log.Printf("Ошибка при сохранении режима загрузки: %v", err)
+	}
+
+	text := "Отправьте CSV- или JSON-файл с заданиями документом.\n" +
+		"Колонки CSV: category,link,description,reward,quota,template\n" +
+		"JSON - массив объектов с теми же ключами.\n" +
+		"category - slug категории. reward, quota и template необязательны: по умолчанию " +
+		"вознаграждение категории, квота 1 и шаблон категории по умолчанию. " +
+		"quota «-» - без ограничения, template - ID или название шаблона.\n" +
+		"Строки с ошибками пропускаются, остальные задания создаются вместе."
+	if dryRun {
+		text = "Режим проверки: задания не будут созданы.\n\n" + text
+	} else {
+		text += "\nЧтобы сначала проверить файл, используйте /tasks_import dry."
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+}
+
+// HandleTaskImportFile проверяет файл заданий и создаёт задания из правильных строк
+func (h *Handler) HandleTaskImportFile(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+	doc := update.Message.Document
+
+	if doc.FileSize > maxImportFileSize {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Файл слишком большой."))
+		return
+	}
+
+	var dryRun bool
+	if err := h.DB.GetTempValue(ctx, adminID, tempTaskImportDryRun, &dryRun); err != nil &&
+		!errors.Is(err, database.ErrTempDataNotFound) {
+		log.Printf("Ошибка при получении режима загрузки: %v", err)
+	}
+
+	body, err := tgfile.Download(ctx, h.Bot, doc.FileID, maxImportFileSize)
+	if err != nil {
+		log.Printf("Ошибка при загрузке файла заданий: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить файл. Попробуйте ещё раз."))
+		return
+	}
+
+	format := taskimport.DetectFormat(doc.FileName, body)
+	report, err := h.TaskImport.Import(ctx, body, format, dryRun)
+	if err != nil {
+		// Администратор остаётся в состоянии загрузки и может прислать исправленный файл
+		log.Printf("Ошибка при загрузке заданий из %s: %v", doc.FileName, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+"."))
+		return
+	}
+	if !dryRun {
+		log.Printf("Администратор %d загрузил заданий: %d из файла %s", adminID, len(report.Tasks), doc.FileName)
+	}
+
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	text := []rune(report.Text(reportLimit))
+	truncated := len(text) > maxMessageLen
+	if truncated {
+		text = append(text[:maxMessageLen-1], '…')
+	}
+	msg := tgbotapi.NewMessage(chatID, string(text))
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+
+	// Полный отчёт файлом, если в сообщение он не поместился
+	if truncated || len(report.Tasks) > reportLimit || len(report.Errors) > reportLimit {
+		file := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: "import_report.txt", Bytes: []byte(report.Text(0))})
+		h.Bot.Send(file)
+	}
+}
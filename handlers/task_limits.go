@@ -0,0 +1,124 @@
+// handlers/task_limits.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"telegram_bot/models"
+	"telegram_bot/money"
+	"telegram_bot/taskimport"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// taskLimitsUsage - подсказка к команде /task_limits
+const taskLimitsUsage = "Формат: /task_limits <ID задания> reward=40 quota=100 budget=5000 per_user=1\n" +
+	"Указывайте только меняемые параметры. quota=- и budget=- снимают ограничение."
+
+// HandleAdminTaskLimits меняет вознаграждение, квоту, бюджет и лимит задания
+func (h *Handler) HandleAdminTaskLimits(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, taskLimitsUsage))
+		return
+	}
+
+	taskID, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, taskLimitsUsage))
+		return
+	}
+	task, err := h.DB.GetTaskByID(ctx, taskID)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Задание #%d не найдено.", taskID)))
+		return
+	}
+
+	if len(args) > 1 {
+		if err := applyTaskLimits(task, args[1:]); err != nil {
+			h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+".\n"+taskLimitsUsage))
+			return
+		}
+		if err := h.DB.UpdateTaskLimits(ctx, task); err != nil {
+			log.Printf("Ошибка при изменении лимитов задания %d: %v", taskID, err)
+			h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить задание."))
+			return
+		}
+		log.Printf("Администратор %d изменил лимиты задания %d: %s",
+			update.Message.From.ID, taskID, strings.Join(args[1:], " "))
+
+		if task, err = h.DB.GetTaskByID(ctx, taskID); err != nil {
+			log.Printf("Ошибка при получении задания %d: %v", taskID, err)
+			return
+		}
+	}
+
+	h.Bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Задание #%d\n%s", task.ID, taskLimitsText(task))))
+}
+
+// applyTaskLimits применяет к заданию параметры вида key=value
+func applyTaskLimits(task *models.Task, args []string) error {
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("ожидается параметр=значение, получено %q", arg)
+		}
+		switch key {
+		case "reward":
+			reward, err := taskimport.ParseReward(value)
+			if err != nil {
+				return err
+			}
+			task.Reward = reward
+		case "quota":
+			quota, err := taskimport.ParseQuota(value)
+			if err != nil {
+				return err
+			}
+			task.MaxCompletions = quota
+		case "budget":
+			if value == taskimport.Unlimited {
+				task.Budget = nil
+				continue
+			}
+			budget, err := money.Parse(value)
+			if err != nil || budget.IsNegative() {
+				return errors.New("некорректный бюджет")
+			}
+			task.Budget = &budget
+		case "per_user":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return errors.New("лимит на пользователя должен быть положительным числом")
+			}
+			task.PerUserLimit = n
+		default:
+			return fmt.Errorf("неизвестный параметр %q", key)
+		}
+	}
+	return nil
+}
+
+// taskLimitsText описывает вознаграждение и остаток квоты и бюджета
+func taskLimitsText(task *models.Task) string {
+	quota := "без ограничения"
+	if task.MaxCompletions != nil {
+		quota = fmt.Sprintf("занято %d из %d", task.Taken, *task.MaxCompletions)
+	}
+	budget := "без ограничения"
+	if task.Budget != nil {
+		budget = fmt.Sprintf("израсходовано %s из %s", task.Spent, *task.Budget)
+	}
+	status := "активно"
+	if !task.IsActive {
+		status = "снято с публикации"
+	}
+	return fmt.Sprintf("Вознаграждение: %s\nКвота: %s\nБюджет: %s\nНа одного пользователя: %d\nСтатус: %s",
+		task.Reward, quota, budget, task.PerUserLimit, status)
+}
@@ -0,0 +1,290 @@
+// handlers/templates.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ActionTemplateDefault - сделать шаблон шаблоном по умолчанию. ID - task_templates.id.
+const ActionTemplateDefault = "tpldefault"
+
+// maxTemplateSteps - сколько шагов может быть в шаблоне
+const maxTemplateSteps = 20
+
+// registerTemplateCallbacks регистрирует кнопки управления шаблонами
+func (h *Handler) registerTemplateCallbacks(d *callback.Dispatcher) {
+	d.Register(ActionTemplateDefault, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleTemplateDefault,
+	})
+}
+
+// HandleAdminTemplates показывает шаблоны и запрашивает новый
+func (h *Handler) HandleAdminTemplates(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	templates, err := h.DB.ListTemplates(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении шаблонов: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить список шаблонов."))
+		return
+	}
+
+	if len(templates) > 0 {
+		msg := tgbotapi.NewMessage(chatID, templatesText(templates))
+		msg.ReplyMarkup = h.templateButtons(templates)
+		h.Bot.Send(msg)
+	}
+
+	if !h.transition(ctx, chatID, update.Message.From.ID, models.StateAwaitingTemplateInput) {
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID,
+		"Чтобы добавить шаблон, отправьте его одним сообщением.\n"+
+			"Первая строка: slug категории; название шаблона\n"+
+			"Далее по строке на шаг: инструкция | доказательство | задержка | срок\n"+
+			"Доказательство: none, screenshot, text, link или screenshots:N. "+
+			"Задержка и срок - например 30m или 5h, «-» - без них. "+
+			"В сумме по всем шагам - не больше "+humanDuration(AssignmentTTL)+".\n"+
+			"Например:\n"+
+			"avito; Отзыв Авито\n"+
+			"Найдите объявление по ссылке | none\n"+
+			"Добавьте объявление в избранное | screenshot | 1h | 24h\n"+
+			"Оставьте отзыв | screenshots:2 | 5h\n"+
+			"Новый шаблон станет шаблоном по умолчанию для новых заданий категории.")
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+}
+
+// HandleTemplateInput создаёт шаблон
+func (h *Handler) HandleTemplateInput(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+
+	template, err := parseTemplate(update.Message.Text)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+"."))
+		return
+	}
+
+	if _, err := h.DB.GetCategoryBySlug(ctx, template.Category); errors.Is(err, database.ErrCategoryNotFound) {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Категория "+template.Category+" не найдена."))
+		return
+	} else if err != nil {
+		log.Printf("Ошибка при получении категории %s: %v", template.Category, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить шаблон."))
+		return
+	}
+
+	template.IsDefault = true
+	if err := h.DB.CreateTemplate(ctx, template); err != nil {
+		log.Printf("Ошибка при сохранении шаблона: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить шаблон."))
+		return
+	}
+	log.Printf("Администратор %d создал шаблон %d для категории %s", adminID, template.ID, template.Category)
+
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, "Шаблон добавлен:\n"+templateText(template))
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+}
+
+func (h *Handler) handleTemplateDefault(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	if err := h.DB.SetDefaultTemplate(ctx, int(d.ID)); err != nil {
+		return "", err
+	}
+	log.Printf("Администратор %d выбрал шаблон %d по умолчанию", q.From.ID, d.ID)
+
+	templates, err := h.DB.ListTemplates(ctx)
+	if err == nil {
+		edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, templatesText(templates))
+		markup := h.templateButtons(templates)
+		edit.ReplyMarkup = &markup
+		h.Bot.Send(edit)
+	}
+	return "Шаблон будет использоваться для новых заданий категории.", nil
+}
+
+// templateButtons - кнопки выбора шаблона по умолчанию
+func (h *Handler) templateButtons(templates []*models.TaskTemplate) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, t := range templates {
+		if t.IsDefault {
+			continue
+		}
+		label := fmt.Sprintf("⭐ По умолчанию: #%d %s", t.ID, t.Name)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button(label, ActionTemplateDefault, int64(t.ID)),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// templatesText - список шаблонов с шагами
+func templatesText(templates []*models.TaskTemplate) string {
+	var b strings.Builder
+	b.WriteString("Шаблоны заданий:")
+	for _, t := range templates {
+		b.WriteString("\n\n")
+		b.WriteString(templateText(t))
+	}
+	return b.String()
+}
+
+// templateText записывает шаблон в формате ввода
+func templateText(t *models.TaskTemplate) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#%d %s; %s", t.ID, t.Category, t.Name)
+	if t.IsDefault {
+		b.WriteString(" ⭐")
+	}
+	for _, s := range t.Steps {
+		fmt.Fprintf(&b, "\n%d. %s | %s | %s | %s", s.Position, s.Instruction, proofSpec(s), durationSpec(s.Delay), durationSpec(s.Deadline))
+	}
+	return b.String()
+}
+
+func proofSpec(s models.TemplateStep) string {
+	if s.ProofType == models.ProofScreenshots {
+		return fmt.Sprintf("%s:%d", s.ProofType, s.ProofCount)
+	}
+	return string(s.ProofType)
+}
+
+func durationSpec(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	// 1h30m0s -> 1h30m, 2h0m0s -> 2h
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// parseTemplate разбирает шаблон: первая строка «slug; название»,
+// далее строки шагов «инструкция | доказательство | задержка | срок»
+func parseTemplate(input string) (*models.TaskTemplate, error) {
+	var lines []string
+	for _, line := range strings.Split(input, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) < 2 {
+		return nil, errors.New("нужна строка «slug; название» и хотя бы один шаг")
+	}
+	if len(lines)-1 > maxTemplateSteps {
+		return nil, fmt.Errorf("не больше %d шагов", maxTemplateSteps)
+	}
+
+	slug, name, ok := strings.Cut(lines[0], ";")
+	t := &models.TaskTemplate{
+		Category: strings.ToLower(strings.TrimSpace(slug)),
+		Name:     strings.TrimSpace(name),
+	}
+	if !ok || !slugRe.MatchString(t.Category) {
+		return nil, errors.New("первая строка должна быть «slug; название»")
+	}
+	if t.Name == "" || len([]rune(t.Name)) > 100 {
+		return nil, errors.New("некорректное название шаблона")
+	}
+
+	var total time.Duration
+	for i, line := range lines[1:] {
+		step, err := parseTemplateStep(line)
+		if err != nil {
+			return nil, fmt.Errorf("шаг %d: %w", i+1, err)
+		}
+		t.Steps = append(t.Steps, step)
+		total += step.Delay + step.Deadline
+	}
+	// Срок выполнения задания не продлевается на время задержек: иначе
+	// задание снимется, пока исполнитель ждёт открытия шага
+	if total > AssignmentTTL {
+		return nil, fmt.Errorf("задержки и сроки шагов в сумме не должны превышать %s - срок выполнения задания",
+			humanDuration(AssignmentTTL))
+	}
+	return t, nil
+}
+
+// parseTemplateStep разбирает строку «инструкция | доказательство | задержка | срок»
+func parseTemplateStep(line string) (models.TemplateStep, error) {
+	fields := strings.Split(line, "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	step := models.TemplateStep{ProofType: models.ProofNone, ProofCount: 1}
+	if len(fields) > 4 {
+		return step, errors.New("не больше четырёх полей через «|»")
+	}
+	step.Instruction = fields[0]
+	if step.Instruction == "" {
+		return step, errors.New("пустая инструкция")
+	}
+
+	if len(fields) > 1 && fields[1] != "" {
+		kind, count, hasCount := strings.Cut(strings.ToLower(fields[1]), ":")
+		step.ProofType = models.ProofType(kind)
+		switch step.ProofType {
+		case models.ProofNone, models.ProofScreenshot, models.ProofText, models.ProofLink:
+			if hasCount {
+				return step, errors.New("количество указывается только для screenshots")
+			}
+		case models.ProofScreenshots:
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 1 || n > 10 {
+				return step, errors.New("для screenshots укажите количество от 1 до 10, например screenshots:2")
+			}
+			step.ProofCount = n
+		default:
+			return step, fmt.Errorf("неизвестный вид доказательства %q", fields[1])
+		}
+	}
+
+	var err error
+	if len(fields) > 2 {
+		if step.Delay, err = parseStepDuration(fields[2]); err != nil {
+			return step, fmt.Errorf("некорректная задержка: %w", err)
+		}
+	}
+	if len(fields) > 3 {
+		if step.Deadline, err = parseStepDuration(fields[3]); err != nil {
+			return step, fmt.Errorf("некорректный срок: %w", err)
+		}
+	}
+	return step, nil
+}
+
+// parseStepDuration разбирает длительность шага; «-», «0» и пустая строка - без ограничения
+func parseStepDuration(s string) (time.Duration, error) {
+	if s == "" || s == "-" || s == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.New("ожидается, например, 30m или 5h")
+	}
+	if d < time.Minute || d > AssignmentTTL {
+		return 0, fmt.Errorf("от минуты до %s", humanDuration(AssignmentTTL))
+	}
+	return d.Truncate(time.Second), nil
+}
@@ -0,0 +1,67 @@
+// handlers/templates_test.go
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationSpec(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "-"},
+		{time.Minute, "1m"},
+		{10 * time.Minute, "10m"},
+		{30 * time.Minute, "30m"},
+		{time.Hour, "1h"},
+		{10 * time.Hour, "10h"},
+		{90 * time.Minute, "1h30m"},
+		{time.Hour + 10*time.Minute, "1h10m"},
+		{time.Hour + 30*time.Second, "1h0m30s"},
+		{10*time.Minute + 10*time.Second, "10m10s"},
+	}
+	for _, tt := range tests {
+		if got := durationSpec(tt.d); got != tt.want {
+			t.Errorf("durationSpec(%v) = %q, ожидалось %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestParseStepDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"-", 0, false},
+		{"0", 0, false},
+		{"10m", 10 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"5h", 5 * time.Hour, false},
+		{"30s", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseStepDuration(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStepDuration(%q): ошибка %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseStepDuration(%q) = %v, ожидалось %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Шаги в списке шаблонов можно скопировать обратно без изменения смысла
+func TestDurationSpecRoundTrip(t *testing.T) {
+	for _, d := range []time.Duration{time.Minute, 10 * time.Minute, 30 * time.Minute, time.Hour, 90 * time.Minute, 20 * time.Hour} {
+		got, err := parseStepDuration(durationSpec(d))
+		if err != nil || got != d {
+			t.Errorf("parseStepDuration(durationSpec(%v)) = %v, %v", d, got, err)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"time"
 
+	"telegram_bot/money"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -20,31 +22,18 @@ func (h *Handler) ShowTransactionHistory(ctx context.Context, chatID int64, tele
 		return
 	}
 
-	rows, err := h.DB.QueryContext(ctx, `
-        SELECT amount, description, created_at
-        FROM transactions
-        WHERE user_id = $1
-        ORDER BY created_at DESC
-        LIMIT 10
-    `, userID)
+	// История строится по строкам журнала, относящимся к кошельку пользователя
+	history, err := h.Ledger.History(ctx, userID, 10)
 	if err != nil {
 		log.Println("Ошибка при получении транзакций:", err)
 		msg := tgbotapi.NewMessage(chatID, "Не удалось получить историю транзакций.")
 		h.Bot.Send(msg)
 		return
 	}
-	defer rows.Close()
 
 	var response string
-	for rows.Next() {
-		var amount float64
-		var description, createdAt string
-		err := rows.Scan(&amount, &description, &createdAt)
-		if err != nil {
-			log.Println("Ошибка при сканировании транзакции:", err)
-			continue
-		}
-		response += fmt.Sprintf("%s: %.2f руб. — %s\n", createdAt, amount, description)
+	for _, t := range history {
+		response += fmt.Sprintf("%s: %s%s — %s\n", t.CreatedAt, sign(t.Amount), t.Amount, t.Description)
 	}
 
 	if response == "" {
@@ -78,5 +67,26 @@ func (h *Handler) HandleNextTaskStep(ctx context.Context, update tgbotapi.Update
 }
 
 func (h *Handler) StartWaitingPeriod(ctx context.Context, userID int64, delay time.Duration) {
-	h.DB.SetUserAvailableAt(ctx, userID)
+	if err := h.DB.SetUserAvailableAt(ctx, userID, time.Now().Add(delay)); err != nil {
+		log.Printf("Ошибка при сохранении времени доступности шага: %v", err)
+	}
+}
+
+// sign возвращает "+" для зачислений, у списаний минус уже есть в сумме
+func sign(a money.Amount) string {
+	if a.IsPositive() {
+		return "+"
+	}
+	return ""
+}
+
+// HandleReconcile запускает сверку журнала по запросу администратора
+func (h *Handler) HandleReconcile(ctx context.Context, update tgbotapi.Update) {
+	report, err := h.Ledger.Reconcile(ctx)
+	if err != nil {
+		log.Printf("Ошибка при сверке журнала: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Не удалось выполнить сверку."))
+		return
+	}
+	h.Bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, report.String()))
 }
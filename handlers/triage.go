@@ -0,0 +1,493 @@
+// handlers/triage.go
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/ledger"
+	"telegram_bot/models"
+	"telegram_bot/referral"
+	"telegram_bot/triage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действия автоматической проверки
+const (
+	ActionTriageToggle = "triagetoggle" // ID - triage_rules.id
+	ActionTriageRevert = "triagerevert" // ID - user_tasks.id
+)
+
+// triageLease - на сколько автоматическая проверка закрепляет выполнение.
+// Выполнение, которое уже проверяет модератор, автоматически не решается.
+const triageLease = time.Minute
+
+// triageModerator - moderator_id аренды автоматической проверки
+const triageModerator = 0
+
+// triageLogLimit - сколько автоматических решений показывать в журнале
+const triageLogLimit = 15
+
+// trustUsage - подсказка к команде /trust
+const trustUsage = "Формат: /trust <Telegram ID> <уровень>, например /trust 123456789 2"
+
+// registerTriageCallbacks регистрирует кнопки правил и журнала автоматической проверки
+func (h *Handler) registerTriageCallbacks(d *callback.Dispatcher) {
+	d.Register(ActionTriageToggle, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleTriageToggle,
+	})
+	d.Register(ActionTriageRevert, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleTriageRevert,
+	})
+}
+
+// runTriage оценивает сданное выполнение по правилам. Если правило
+// одобряет или отклоняет выполнение, решение принимается без модератора.
+func (h *Handler) runTriage(ctx context.Context, job StageJob) error {
+	userTask, err := h.DB.GetUserTaskByID(ctx, job.UserTaskID)
+	if err != nil {
+		return err
+	}
+	if userTask.Status != models.UserTaskCompleted || userTask.Resubmits != job.Attempt {
+		return nil
+	}
+	task, err := h.DB.GetTaskByID(ctx, int64(userTask.TaskID))
+	if err != nil {
+		return err
+	}
+
+	// Хэши последних скриншотов могли ещё не посчитаться задачами JobProofHash
+	h.hashProofs(ctx, userTask.ID)
+	rule, signals, err := h.Triage.Evaluate(ctx, userTask, task.Category)
+	if err != nil {
+		return err
+	}
+	if rule == nil || rule.Action == models.TriageReview {
+		return nil
+	}
+	reason := fmt.Sprintf("правило #%d: %s", rule.ID, signals.Describe(rule.Conditions))
+
+	if rule.Action == models.TriageApprove {
+		return h.autoApprove(ctx, job.UserTaskID, rule.ID, reason)
+	}
+	return h.autoReject(ctx, job.UserTaskID, rule.ID, reason)
+}
+
+// autoApprove одобряет выполнение по правилу
+func (h *Handler) autoApprove(ctx context.Context, userTaskID int64, ruleID int, reason string) error {
+	var userTask *models.UserTask
+	var earnings []referral.Earning
+
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.RenewModerationLease(ctx, userTaskID, triageModerator, triageLease); err != nil {
+			return err
+		}
+		var err error
+		userTask, earnings, err = h.approveUserTask(ctx, tx, userTaskID, models.UserTaskCompleted)
+		if err != nil {
+			return err
+		}
+		return recordAutoDecision(ctx, tx, userTaskID, models.DecisionAutoApproved, reason, ruleID)
+	})
+	if errors.Is(err, database.ErrStatusChanged) || errors.Is(err, database.ErrLeaseTaken) {
+		// Выполнение уже проверено или его проверяет модератор
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Выполнение %d одобрено автоматически: %s", userTaskID, reason)
+	h.markCopies(ctx, userTask.ID, nil, "одобрено автоматически")
+	h.notifyUser(ctx, userTask.UserID, fmt.Sprintf("Ваше задание одобрено! Вам начислено %s.", userTask.Reward))
+	for _, e := range earnings {
+		h.notifyUser(ctx, e.ReferrerID, referralEarningText(e))
+	}
+	return nil
+}
+
+// autoReject отклоняет выполнение по правилу. Исполнитель может обжаловать
+// решение, апелляцию рассмотрит старший модератор.
+func (h *Handler) autoReject(ctx context.Context, userTaskID int64, ruleID int, reason string) error {
+	var userTask *models.UserTask
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.RenewModerationLease(ctx, userTaskID, triageModerator, triageLease); err != nil {
+			return err
+		}
+		if err := tx.SetUserTaskStatus(ctx, userTaskID, models.UserTaskCompleted, models.UserTaskRejected); err != nil {
+			return err
+		}
+		if err := releaseSlot(ctx, tx, userTaskID); err != nil {
+			return err
+		}
+		var err error
+		userTask, err = tx.GetUserTaskByID(ctx, userTaskID)
+		if err != nil {
+			return err
+		}
+		return recordAutoDecision(ctx, tx, userTaskID, models.DecisionAutoRejected, reason, ruleID)
+	})
+	if errors.Is(err, database.ErrStatusChanged) || errors.Is(err, database.ErrLeaseTaken) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Выполнение %d отклонено автоматически: %s", userTaskID, reason)
+	h.markCopies(ctx, userTask.ID, nil, "отклонено автоматически")
+	text := fmt.Sprintf("Выполнение задания #%d не прошло автоматическую проверку и отклонено.\n\n"+
+		"Если вы не согласны с решением, его можно один раз обжаловать: апелляцию рассмотрит модератор.", userTask.TaskID)
+	h.notifyUserWith(ctx, userTask.UserID, text, tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		h.Codec.Button("⚖️ Обжаловать", ActionAppeal, userTaskID),
+	)))
+	return nil
+}
+
+// recordAutoDecision записывает автоматическое решение в историю выполнения
+func recordAutoDecision(ctx context.Context, db database.DBInterface, userTaskID int64, decision, reason string, ruleID int) error {
+	return db.AddModerationDecision(ctx, &models.ModerationDecision{
+		UserTaskID: int(userTaskID),
+		Decision:   decision,
+		Reason:     reason,
+		RuleID:     &ruleID,
+	})
+}
+
+// --- Журнал автоматических решений (администратор) ---
+
+// HandleAdminTriageLog показывает последние автоматические решения
+// с кнопками отмены
+func (h *Handler) HandleAdminTriageLog(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	text, markup, err := h.triageLog(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении автоматических решений: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить журнал автоматических решений."))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	if len(markup.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = markup
+	}
+	h.Bot.Send(msg)
+}
+
+// triageLog - текст журнала и кнопки отмены решений, после которых
+// других решений не было
+func (h *Handler) triageLog(ctx context.Context) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	decisions, err := h.DB.ListAutoDecisions(ctx, triageLogLimit)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	// Пустая клавиатура, а не nil: при правке сообщения она убирает кнопки
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if len(decisions) == 0 {
+		return "Автоматических решений пока нет.", tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Последние автоматические решения:")
+	for _, d := range decisions {
+		fmt.Fprintf(&b, "\n\n%s выполнение #%d (задание #%d): %s",
+			d.CreatedAt.Format("02.01.2006 15:04"), d.UserTaskID, d.TaskID, decisionNames[d.Decision])
+		if d.Reason != "" {
+			fmt.Fprintf(&b, "\n%s", d.Reason)
+		}
+		if !d.Latest {
+			b.WriteString("\nРешение уже пересмотрено.")
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button(fmt.Sprintf("↩️ Отменить #%d", d.UserTaskID), ActionTriageRevert, int64(d.UserTaskID)),
+		))
+	}
+	return b.String(), tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
+}
+
+// handleTriageRevert отменяет последнее автоматическое решение по выполнению:
+// одобрение превращается в отклонение, отклонение - в одобрение
+func (h *Handler) handleTriageRevert(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	decisions, err := h.DB.ListModerationDecisions(ctx, int(d.ID))
+	if err != nil {
+		return "", err
+	}
+	if len(decisions) == 0 {
+		return "По выполнению нет решений.", nil
+	}
+
+	var text string
+	switch decisions[len(decisions)-1].Decision {
+	case models.DecisionAutoApproved:
+		text, err = h.revertAutoApproval(ctx, q.From, d.ID)
+	case models.DecisionAutoRejected:
+		text, err = h.revertAutoRejection(ctx, q.From, d.ID)
+	default:
+		text = "Решение уже пересмотрено."
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if logText, markup, err := h.triageLog(ctx); err == nil {
+		edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, logText)
+		edit.ReplyMarkup = &markup
+		h.Bot.Send(edit)
+	}
+	return text, nil
+}
+
+// revertAutoApproval отклоняет автоматически одобренное выполнение:
+// списывает вознаграждение и процентные начисления рефереров
+func (h *Handler) revertAutoApproval(ctx context.Context, admin *tgbotapi.User, userTaskID int64) (string, error) {
+	var userTask *models.UserTask
+	var reverted []referral.Earning
+
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		if err := tx.SetUserTaskStatus(ctx, userTaskID, models.UserTaskApproved, models.UserTaskRejected); err != nil {
+			return err
+		}
+		var err error
+		userTask, err = tx.GetUserTaskByID(ctx, userTaskID)
+		if err != nil {
+			return err
+		}
+		_, err = h.Ledger.Post(ctx, tx, ledger.Transfer(
+			ledger.KindTaskRewardReversal,
+			fmt.Sprintf("Отмена вознаграждения за задание #%d", userTask.TaskID),
+			fmt.Sprintf("user_task:%d:reward:reversal", userTask.ID),
+			ledger.UserWallet(userTask.UserID), ledger.RewardExpense, userTask.Reward,
+		))
+		if err != nil {
+			return err
+		}
+		reverted, err = h.Referrals.OnTaskReverted(ctx, tx, userTask.ID)
+		if err != nil {
+			return err
+		}
+		if err := releaseSlot(ctx, tx, userTaskID); err != nil {
+			return err
+		}
+		return recordDecision(ctx, tx, userTaskID, admin.ID, models.DecisionAutoReverted, "автоматическое одобрение отменено", nil)
+	})
+	switch {
+	case errors.Is(err, database.ErrStatusChanged):
+		return "Статус выполнения уже изменился.", nil
+	case errors.Is(err, ledger.ErrInsufficientFunds):
+		return "Недостаточно средств у исполнителя или реферера: начисление уже выведено.", nil
+	case err != nil:
+		return "", err
+	}
+
+	log.Printf("Администратор %d отменил автоматическое одобрение выполнения %d", admin.ID, userTaskID)
+	h.markCopies(ctx, userTask.ID, nil, "автоматическое одобрение отменено "+moderatorName(admin))
+	h.notifyUser(ctx, userTask.UserID, fmt.Sprintf(
+		"Одобрение задания #%d отменено после проверки модератором. С баланса списано %s.", userTask.TaskID, userTask.Reward))
+	for _, e := range reverted {
+		h.notifyUser(ctx, e.ReferrerID, fmt.Sprintf(
+			"↩️ Начисление %s за задание реферала отменено: задание не прошло проверку.", e.Amount))
+	}
+	return "Одобрение отменено, выполнение отклонено.", nil
+}
+
+// revertAutoRejection одобряет автоматически отклонённое выполнение
+func (h *Handler) revertAutoRejection(ctx context.Context, admin *tgbotapi.User, userTaskID int64) (string, error) {
+	var userTask *models.UserTask
+	var earnings []referral.Earning
+
+	err := h.DB.RunInTx(ctx, func(tx database.DBInterface) error {
+		var err error
+		userTask, earnings, err = h.approveUserTask(ctx, tx, userTaskID, models.UserTaskRejected)
+		if err != nil {
+			return err
+		}
+		// Место было освобождено при отклонении
+		if err := tx.RetakeTaskSlot(ctx, int64(userTask.TaskID), userTask.Reward); err != nil {
+			return err
+		}
+		return recordDecision(ctx, tx, userTaskID, admin.ID, models.DecisionAutoReverted, "автоматическое отклонение отменено", nil)
+	})
+	if errors.Is(err, database.ErrStatusChanged) {
+		return "Статус выполнения уже изменился: возможно, исполнитель подал апелляцию.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Администратор %d отменил автоматическое отклонение выполнения %d", admin.ID, userTaskID)
+	h.markCopies(ctx, userTask.ID, nil, "автоматическое отклонение отменено "+moderatorName(admin))
+	h.notifyUser(ctx, userTask.UserID, fmt.Sprintf(
+		"Отклонение задания #%d отменено модератором. Задание одобрено, вам начислено %s.", userTask.TaskID, userTask.Reward))
+	for _, e := range earnings {
+		h.notifyUser(ctx, e.ReferrerID, referralEarningText(e))
+	}
+	return "Отклонение отменено, выполнение одобрено.", nil
+}
+
+// --- Правила автоматической проверки (администратор) ---
+
+// HandleAdminTriageRules показывает правила и запрашивает новое
+func (h *Handler) HandleAdminTriageRules(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	rules, err := h.DB.ListTriageRules(ctx, false)
+	if err != nil {
+		log.Printf("Ошибка при получении правил автоматической проверки: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить правила."))
+		return
+	}
+
+	if len(rules) > 0 {
+		msg := tgbotapi.NewMessage(chatID, triageRulesText(rules))
+		msg.ReplyMarkup = h.triageToggleButtons(rules)
+		h.Bot.Send(msg)
+	}
+
+	if !h.transition(ctx, chatID, update.Message.From.ID, models.StateAwaitingTriageRule) {
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID,
+		"Чтобы добавить правило, отправьте строку:\n"+
+			"категория; действие; условие; условие; ...\n"+
+			"Категория - slug или «*» для любой. Действие - одобрить, отклонить или проверить "+
+			"(отправить модератору). Условие - сигнал, сравнение и порог.\n\n"+
+			"Сигналы:\n"+triage.SignalsHelp()+"\n\n"+
+			"Правила применяются по порядку, срабатывает первое подходящее. "+
+			"Если ни одно не подошло, выполнение проверяет модератор.\n"+
+			"Например: 2gis; одобрить; trust>=1; rate>=0.9; duplicates=0; gap>=5\n"+
+			"Журнал автоматических решений: /triage_log")
+	msg.ReplyMarkup = cancelKeyboard()
+	h.Bot.Send(msg)
+}
+
+// HandleTriageRuleInput добавляет правило автоматической проверки
+func (h *Handler) HandleTriageRuleInput(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	adminID := update.Message.From.ID
+
+	rule, err := triage.ParseRule(update.Message.Text)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+"."))
+		return
+	}
+	if rule.Category != models.TriageAnyCategory {
+		if _, err := h.DB.GetCategoryBySlug(ctx, rule.Category); errors.Is(err, database.ErrCategoryNotFound) {
+			h.Bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Категория «%s» не найдена.", rule.Category)))
+			return
+		} else if err != nil {
+			log.Printf("Ошибка при получении категории %s: %v", rule.Category, err)
+			h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось проверить категорию."))
+			return
+		}
+	}
+
+	if err := h.DB.CreateTriageRule(ctx, rule); err != nil {
+		log.Printf("Ошибка при сохранении правила автоматической проверки: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить правило."))
+		return
+	}
+	log.Printf("Администратор %d добавил правило автоматической проверки %d: %s", adminID, rule.ID, triage.FormatRule(rule))
+
+	if err := h.FSM.Finish(ctx, adminID); err != nil {
+		log.Printf("Ошибка при сбросе состояния администратора: %v", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Правило #%d добавлено: %s", rule.ID, triage.FormatRule(rule)))
+	msg.ReplyMarkup = h.AdminMenu
+	h.Bot.Send(msg)
+}
+
+func (h *Handler) handleTriageToggle(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	rule, err := h.DB.GetTriageRule(ctx, d.ID)
+	if err != nil {
+		return "", err
+	}
+	if err := h.DB.SetTriageRuleActive(ctx, d.ID, !rule.IsActive); err != nil {
+		return "", err
+	}
+	log.Printf("Администратор %d: правило автоматической проверки %d активно = %t", q.From.ID, rule.ID, !rule.IsActive)
+
+	rules, err := h.DB.ListTriageRules(ctx, false)
+	if err == nil {
+		edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, triageRulesText(rules))
+		markup := h.triageToggleButtons(rules)
+		edit.ReplyMarkup = &markup
+		h.Bot.Send(edit)
+	}
+
+	if rule.IsActive {
+		return "Правило выключено.", nil
+	}
+	return "Правило включено.", nil
+}
+
+// triageToggleButtons - кнопки включения и выключения правил
+func (h *Handler) triageToggleButtons(rules []*models.TriageRule) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, r := range rules {
+		label := fmt.Sprintf("⏸ Выключить #%d", r.ID)
+		if !r.IsActive {
+			label = fmt.Sprintf("▶️ Включить #%d", r.ID)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button(label, ActionTriageToggle, int64(r.ID)),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// triageRulesText - список правил в порядке применения
+func triageRulesText(rules []*models.TriageRule) string {
+	var b strings.Builder
+	b.WriteString("Правила автоматической проверки (по порядку применения):")
+	for _, r := range rules {
+		status := ""
+		if !r.IsActive {
+			status = " (выключено)"
+		}
+		fmt.Fprintf(&b, "\n#%d %s%s", r.ID, triage.FormatRule(r), status)
+	}
+	return b.String()
+}
+
+// HandleAdminTrust задаёт уровень доверия исполнителя для правил автоматической проверки
+func (h *Handler) HandleAdminTrust(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) != 2 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, trustUsage))
+		return
+	}
+	telegramID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, trustUsage))
+		return
+	}
+	level, err := strconv.Atoi(args[1])
+	if err != nil || level < 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Уровень доверия должен быть целым числом от 0."))
+		return
+	}
+
+	err = h.DB.SetUserTrustLevel(ctx, telegramID, level)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Пользователь не найден."))
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при изменении уровня доверия пользователя %d: %v", telegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить уровень доверия."))
+		return
+	}
+	log.Printf("Администратор %d задал пользователю %d уровень доверия %d", update.Message.From.ID, telegramID, level)
+	h.Bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Уровень доверия пользователя %d: %d.", telegramID, level)))
+}
@@ -0,0 +1,577 @@
+// handlers/withdrawals.go
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"telegram_bot/callback"
+	"telegram_bot/database"
+	"telegram_bot/fsm"
+	"telegram_bot/ledger"
+	"telegram_bot/models"
+	"telegram_bot/money"
+	"telegram_bot/payout"
+	"telegram_bot/withdrawal"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действия inline-кнопок заявок на вывод. ID в данных кнопки - это withdrawals.id.
+const (
+	ActionWithdrawApprove = "wdapprove"
+	ActionWithdrawPaid    = "wdpaid"
+	ActionWithdrawReject  = "wdreject"
+)
+
+// ActionWithdrawMethod - выбор сохранённых реквизитов для вывода. ID - payout_methods.id.
+const ActionWithdrawMethod = "wdmethod"
+
+// Ключи временных данных диалога вывода
+const (
+	tempWithdrawAmount = "withdraw_amount" // сумма вывода
+	tempPayoutKind     = "payout_kind"     // способ выплаты для новых реквизитов
+)
+
+// newPayoutMethodButtons - кнопки добавления реквизитов
+var newPayoutMethodButtons = map[string]payout.Kind{
+	"➕ Новая карта":     payout.KindCard,
+	"➕ СБП по телефону": payout.KindSBP,
+	"➕ Кошелёк ЮMoney":  payout.KindYooMoney,
+}
+
+// registerWithdrawalCallbacks регистрирует кнопки администратора для заявок
+func (h *Handler) registerWithdrawalCallbacks(d *callback.Dispatcher) {
+	d.Register(ActionWithdrawApprove, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleWithdrawApprove,
+	})
+	d.Register(ActionWithdrawPaid, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleWithdrawPaid,
+	})
+	d.Register(ActionWithdrawReject, callback.Action{
+		Authorize: h.authorizeAdmin,
+		Handle:    h.handleWithdrawReject,
+	})
+	d.Register(ActionWithdrawMethod, callback.Action{
+		Authorize: h.authorizePayoutOwner,
+		Handle:    h.handleWithdrawMethod,
+	})
+}
+
+// HandleWithdrawRequest начинает оформление заявки: спрашивает сумму
+func (h *Handler) HandleWithdrawRequest(ctx context.Context, update tgbotapi.Update) {
+	telegramID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	user, err := h.DB.GetUserByTelegramID(ctx, telegramID)
+	if err != nil {
+		log.Printf("Ошибка при получении пользователя %d: %v", telegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+		return
+	}
+
+	limits := h.Withdrawals.Limits()
+	if user.Balance.Cmp(limits.Min) < 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"У вас недостаточно средств для вывода. Минимальная сумма: %s, ваш баланс: %s.",
+			limits.Min, user.Balance)))
+		return
+	}
+
+	if !h.transition(ctx, chatID, telegramID, models.StateAwaitingWithdrawalAmount) {
+		return
+	}
+
+	// Кнопка с максимально доступной суммой
+	maxAmount := user.Balance
+	if !limits.Max.IsZero() && maxAmount.Cmp(limits.Max) > 0 {
+		maxAmount = limits.Max
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"Введите сумму вывода в рублях.\nДоступно: %s\nМинимум: %s%s",
+		user.Balance, limits.Min, maxText(limits)))
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(maxAmount.Format())),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(fsm.CancelText)),
+	)
+	h.Bot.Send(msg)
+}
+
+// HandleWithdrawAmount принимает сумму вывода и запрашивает реквизиты
+func (h *Handler) HandleWithdrawAmount(ctx context.Context, update tgbotapi.Update) {
+	telegramID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	amount, err := money.Parse(update.Message.Text)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось разобрать сумму. Введите число, например 500 или 750,50."))
+		return
+	}
+
+	user, err := h.DB.GetUserByTelegramID(ctx, telegramID)
+	if err != nil {
+		log.Printf("Ошибка при получении пользователя %d: %v", telegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+		return
+	}
+	if err := h.Withdrawals.Validate(amount, user.Balance); err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, h.withdrawalErrorText(err, user.Balance)))
+		return
+	}
+
+	if err := h.DB.SetTempValue(ctx, telegramID, tempWithdrawAmount, amount); err != nil {
+		log.Printf("Ошибка при сохранении суммы вывода: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+		return
+	}
+
+	if !h.transition(ctx, chatID, telegramID, models.StateAwaitingPayoutMethod) {
+		return
+	}
+
+	methods, err := h.Payouts.List(ctx, user.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении реквизитов пользователя %d: %v", telegramID, err)
+	}
+
+	h.sendPayoutMethodChoice(chatID, fmt.Sprintf("Сумма к выводу: %s.", amount), methods)
+}
+
+// HandleWithdrawMethod принимает выбор сохранённых реквизитов или способа
+// выплаты для новых
+func (h *Handler) HandleWithdrawMethod(ctx context.Context, update tgbotapi.Update) {
+	telegramID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+	choice := update.Message.Text
+
+	if kind, ok := newPayoutMethodButtons[choice]; ok {
+		if err := h.DB.SetTempValue(ctx, telegramID, tempPayoutKind, kind); err != nil {
+			log.Printf("Ошибка при сохранении способа выплаты: %v", err)
+			h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+			return
+		}
+		if !h.transition(ctx, chatID, telegramID, models.StateAwaitingCardNumder) {
+			return
+		}
+		msg := tgbotapi.NewMessage(chatID, payoutDetailsPrompt(kind))
+		msg.ReplyMarkup = cancelKeyboard()
+		h.Bot.Send(msg)
+		return
+	}
+
+	user, err := h.DB.GetUserByTelegramID(ctx, telegramID)
+	if err != nil {
+		log.Printf("Ошибка при получении пользователя %d: %v", telegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+		return
+	}
+	methods, err := h.Payouts.List(ctx, user.ID)
+	if err != nil {
+		log.Printf("Ошибка при получении реквизитов пользователя %d: %v", telegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Произошла ошибка. Попробуйте позже."))
+		return
+	}
+	h.sendPayoutMethodChoice(chatID, "Пожалуйста, выберите реквизиты кнопкой.", methods)
+}
+
+// handleWithdrawMethod создаёт заявку на выбранные сохранённые реквизиты.
+// Кнопки действуют, только пока пользователь выбирает реквизиты.
+func (h *Handler) handleWithdrawMethod(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	telegramID := q.From.ID
+	chatID := q.Message.Chat.ID
+
+	state, err := h.FSM.Current(ctx, telegramID)
+	if err != nil {
+		return "", err
+	}
+	if state != models.StateAwaitingPayoutMethod {
+		h.removeInlineKeyboard(chatID, q.Message.MessageID)
+		return "Оформление вывода уже завершено.", nil
+	}
+
+	user, err := h.DB.GetUserByTelegramID(ctx, telegramID)
+	if err != nil {
+		return "", err
+	}
+	method, err := h.Payouts.GetActive(ctx, d.ID)
+	if errors.Is(err, payout.ErrNotFound) {
+		return "Эти реквизиты удалены. Выберите другие.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	h.removeInlineKeyboard(chatID, q.Message.MessageID)
+	h.createWithdrawal(ctx, chatID, user, method)
+	return "", nil
+}
+
+// sendPayoutMethodChoice предлагает добавить новые реквизиты кнопками
+// клавиатуры и выбрать сохранённые inline-кнопками
+func (h *Handler) sendPayoutMethodChoice(chatID int64, text string, methods []*models.PayoutMethod) {
+	if len(methods) == 0 {
+		msg := tgbotapi.NewMessage(chatID, text+"\nДобавьте реквизиты для выплаты.")
+		msg.ReplyMarkup = payoutMethodKeyboard()
+		h.Bot.Send(msg)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text+"\nВыберите сохранённые реквизиты или добавьте новые.")
+	msg.ReplyMarkup = payoutMethodKeyboard()
+	h.Bot.Send(msg)
+
+	choice := tgbotapi.NewMessage(chatID, "Сохранённые реквизиты:")
+	choice.ReplyMarkup = h.withdrawMethodButtons(methods)
+	h.Bot.Send(choice)
+}
+
+// withdrawMethodButtons - кнопки выбора сохранённых реквизитов.
+// Реквизиты выбираются по ID: подписи разных реквизитов могут совпадать.
+func (h *Handler) withdrawMethodButtons(methods []*models.PayoutMethod) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, m := range methods {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button(m.Label, ActionWithdrawMethod, m.ID),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// HandleCardNumberReceived проверяет и сохраняет новые реквизиты,
+// после чего создаёт заявку на вывод
+func (h *Handler) HandleCardNumberReceived(ctx context.Context, update tgbotapi.Update) {
+	telegramID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	var kind payout.Kind
+	if err := h.DB.GetTempValue(ctx, telegramID, tempPayoutKind, &kind); err != nil {
+		log.Printf("Не удалось получить способ выплаты пользователя %d: %v", telegramID, err)
+		h.finishWithdrawalDialog(ctx, chatID, telegramID, "Не удалось получить способ выплаты. Начните оформление заново.")
+		return
+	}
+
+	details, err := payout.Parse(kind, update.Message.Text)
+	if err != nil {
+		// Пользователь остаётся в состоянии ввода и может исправить реквизиты
+		h.Bot.Send(tgbotapi.NewMessage(chatID, capitalize(err.Error())+"."))
+		return
+	}
+
+	// Сообщение с реквизитами удаляется из чата, дальше они хранятся только зашифрованными
+	h.Bot.Request(tgbotapi.NewDeleteMessage(chatID, update.Message.MessageID))
+
+	user, err := h.DB.GetUserByTelegramID(ctx, telegramID)
+	if err != nil {
+		log.Printf("Ошибка при получении пользователя %d: %v", telegramID, err)
+		h.finishWithdrawalDialog(ctx, chatID, telegramID, "Произошла ошибка. Попробуйте позже.")
+		return
+	}
+	method, err := h.Payouts.Save(ctx, user.ID, details)
+	if err != nil {
+		log.Printf("Ошибка при сохранении реквизитов пользователя %d: %v", telegramID, err)
+		h.finishWithdrawalDialog(ctx, chatID, telegramID, "Не удалось сохранить реквизиты. Попробуйте позже.")
+		return
+	}
+
+	h.createWithdrawal(ctx, chatID, user, method)
+}
+
+// createWithdrawal создаёт заявку: сумма блокируется до решения администратора
+func (h *Handler) createWithdrawal(ctx context.Context, chatID int64, user *models.User, method *models.PayoutMethod) {
+	telegramID := user.TelegramID
+
+	var amount money.Amount
+	if err := h.DB.GetTempValue(ctx, telegramID, tempWithdrawAmount, &amount); err != nil {
+		log.Printf("Не удалось получить сумму вывода пользователя %d: %v", telegramID, err)
+		h.finishWithdrawalDialog(ctx, chatID, telegramID, "Не удалось получить сумму вывода. Начните оформление заново.")
+		return
+	}
+
+	w, err := h.Withdrawals.Request(ctx, user.ID, amount, method)
+	if err != nil {
+		if !errors.Is(err, ledger.ErrInsufficientFunds) && !errors.Is(err, withdrawal.ErrBelowMinimum) &&
+			!errors.Is(err, withdrawal.ErrAboveMaximum) && !errors.Is(err, withdrawal.ErrMethodDeleted) {
+			log.Printf("Ошибка при создании заявки на вывод пользователя %d: %v", telegramID, err)
+		}
+		h.finishWithdrawalDialog(ctx, chatID, telegramID, h.withdrawalErrorText(err, user.Balance))
+		return
+	}
+
+	h.sendWithdrawalToAdmins(ctx, w)
+	h.finishWithdrawalDialog(ctx, chatID, telegramID, fmt.Sprintf(
+		"Заявка #%d на вывод %s на %s создана. Сумма заблокирована до решения администратора.\n"+
+			"Статус заявок - кнопка «Мои выводы».", w.ID, w.Amount, w.Destination))
+}
+
+// HandleMyWithdrawals показывает пользователю его последние заявки
+func (h *Handler) HandleMyWithdrawals(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	user, err := h.DB.GetUserByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось найти ваш профиль."))
+		return
+	}
+
+	list, err := h.Withdrawals.ListByUser(ctx, user.ID, 10)
+	if err != nil {
+		log.Printf("Ошибка при получении заявок на вывод: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить заявки на вывод."))
+		return
+	}
+	if len(list) == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "У вас пока нет заявок на вывод."))
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("Ваши заявки на вывод:\n\n")
+	for _, w := range list {
+		fmt.Fprintf(&b, "#%d от %s: %s — %s", w.ID, w.CreatedAt.Format("02.01.2006"), w.Amount, withdrawal.StatusTitle(w.Status))
+		if w.Comment != "" {
+			fmt.Fprintf(&b, " (%s)", w.Comment)
+		}
+		b.WriteString("\n")
+	}
+	h.Bot.Send(tgbotapi.NewMessage(chatID, b.String()))
+}
+
+// HandleAdminWithdrawals показывает администратору заявки, ожидающие решения или выплаты
+func (h *Handler) HandleAdminWithdrawals(ctx context.Context, update tgbotapi.Update) {
+	chatID := update.Message.Chat.ID
+	list, err := h.Withdrawals.ListOpen(ctx)
+	if err != nil {
+		log.Printf("Ошибка при получении заявок на вывод: %v", err)
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить заявки на вывод."))
+		return
+	}
+	if len(list) == 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, "Нет заявок на вывод."))
+		return
+	}
+
+	reveal := h.isPayoutOperator(ctx, update.Message.From.ID)
+	for _, w := range list {
+		msg := tgbotapi.NewMessage(chatID, h.withdrawalCard(ctx, w, reveal))
+		if markup, ok := h.withdrawalKeyboard(w); ok {
+			msg.ReplyMarkup = markup
+		}
+		h.Bot.Send(msg)
+	}
+}
+
+func (h *Handler) handleWithdrawApprove(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	w, err := h.Withdrawals.Approve(ctx, d.ID, q.From.ID)
+	if done, text := h.withdrawalActionResult(ctx, q, d.ID, err); done {
+		return text, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	h.updateWithdrawalMessage(ctx, q, w)
+	h.notifyUser(ctx, w.UserID, fmt.Sprintf("Заявка #%d на вывод %s одобрена и ожидает перевода.", w.ID, w.Amount))
+	return "Заявка одобрена.", nil
+}
+
+func (h *Handler) handleWithdrawPaid(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	w, err := h.Withdrawals.MarkPaid(ctx, d.ID, q.From.ID, "")
+	if done, text := h.withdrawalActionResult(ctx, q, d.ID, err); done {
+		return text, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	h.updateWithdrawalMessage(ctx, q, w)
+	h.notifyUser(ctx, w.UserID, fmt.Sprintf("Заявка #%d выплачена: %s отправлены на ваши реквизиты.", w.ID, w.Amount))
+	return "Выплата отмечена.", nil
+}
+
+func (h *Handler) handleWithdrawReject(ctx context.Context, q *tgbotapi.CallbackQuery, d callback.Data) (string, error) {
+	w, err := h.Withdrawals.Reject(ctx, d.ID, q.From.ID, "Отклонено администратором")
+	if done, text := h.withdrawalActionResult(ctx, q, d.ID, err); done {
+		return text, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	h.updateWithdrawalMessage(ctx, q, w)
+	h.notifyUser(ctx, w.UserID, fmt.Sprintf("Заявка #%d на вывод отклонена. Сумма %s возвращена на баланс.", w.ID, w.Amount))
+	return "Заявка отклонена.", nil
+}
+
+// withdrawalActionResult обрабатывает ожидаемые ошибки действий с заявкой:
+// заявку уже обработал другой администратор или её нет
+func (h *Handler) withdrawalActionResult(ctx context.Context, q *tgbotapi.CallbackQuery, id int64, err error) (bool, string) {
+	switch {
+	case errors.Is(err, database.ErrStatusChanged):
+		if w, getErr := h.Withdrawals.Get(ctx, id); getErr == nil {
+			h.updateWithdrawalMessage(ctx, q, w)
+		}
+		return true, "Заявка уже обработана."
+	case errors.Is(err, withdrawal.ErrNotFound):
+		h.removeInlineKeyboard(q.Message.Chat.ID, q.Message.MessageID)
+		return true, "Заявка не найдена."
+	}
+	return false, ""
+}
+
+// updateWithdrawalMessage показывает в сообщении администратора текущий статус заявки
+func (h *Handler) updateWithdrawalMessage(ctx context.Context, q *tgbotapi.CallbackQuery, w *models.Withdrawal) {
+	edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, h.withdrawalCard(ctx, w, h.isPayoutOperator(ctx, q.From.ID)))
+	if markup, ok := h.withdrawalKeyboard(w); ok {
+		edit.ReplyMarkup = &markup
+	}
+	if _, err := h.Bot.Request(edit); err != nil {
+		log.Printf("Ошибка при обновлении сообщения заявки %d: %v", w.ID, err)
+	}
+}
+
+// sendWithdrawalToAdmins рассылает новую заявку администраторам
+func (h *Handler) sendWithdrawalToAdmins(ctx context.Context, w *models.Withdrawal) {
+	ids, err := h.adminTelegramIDs(ctx)
+	if err != nil {
+		log.Println("Ошибка при получении списка администраторов:", err)
+		return
+	}
+
+	markup, _ := h.withdrawalKeyboard(w)
+	for _, id := range ids {
+		text := "📥 Новая заявка на вывод\n\n" + h.withdrawalCard(ctx, w, h.isPayoutOperator(ctx, id))
+		msg := tgbotapi.NewMessage(id, text)
+		msg.ReplyMarkup = markup
+		if _, err := h.Bot.Send(msg); err != nil {
+			log.Printf("Ошибка при отправке заявки администратору %d: %v", id, err)
+		}
+	}
+}
+
+// withdrawalCard - описание заявки для администратора. Полные реквизиты
+// показываются только исполнителю выплат (reveal).
+func (h *Handler) withdrawalCard(ctx context.Context, w *models.Withdrawal, reveal bool) string {
+	var telegramID int64
+	if err := h.DB.QueryRowContext(ctx, "SELECT telegram_id FROM users WHERE id=$1", w.UserID).Scan(&telegramID); err != nil {
+		log.Println("Ошибка при получении Telegram ID:", err)
+	}
+
+	destination := w.Destination
+	if reveal && w.PayoutMethodID != nil {
+		details, err := h.Payouts.Reveal(ctx, *w.PayoutMethodID)
+		if err != nil {
+			log.Printf("Не удалось расшифровать реквизиты заявки %d: %v", w.ID, err)
+		} else {
+			destination = details.Full()
+		}
+	}
+
+	card := fmt.Sprintf(
+		"Заявка #%d\n"+
+			"👤 Пользователь: %d\n"+
+			"💰 Сумма: %s\n"+
+			"💳 Реквизиты: %s\n"+
+			"📅 Создана: %s\n"+
+			"📌 Статус: %s",
+		w.ID, telegramID, w.Amount, destination,
+		w.CreatedAt.Format("02.01.2006 15:04"), withdrawal.StatusTitle(w.Status),
+	)
+	if w.Comment != "" {
+		card += "\n💬 " + w.Comment
+	}
+	return card
+}
+
+// isPayoutOperator сообщает, может ли пользователь видеть полные реквизиты
+func (h *Handler) isPayoutOperator(ctx context.Context, telegramID int64) bool {
+	var operator bool
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT admin AND payout_operator FROM users WHERE telegram_id=$1", telegramID).Scan(&operator)
+	if err != nil {
+		log.Printf("Ошибка при проверке прав исполнителя выплат %d: %v", telegramID, err)
+		return false
+	}
+	return operator
+}
+
+// withdrawalKeyboard - кнопки, доступные для заявки в текущем статусе
+func (h *Handler) withdrawalKeyboard(w *models.Withdrawal) (tgbotapi.InlineKeyboardMarkup, bool) {
+	reject := h.Codec.Button("❌ Отклонить", ActionWithdrawReject, w.ID)
+	switch w.Status {
+	case models.WithdrawalHeld:
+		return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("✅ Одобрить", ActionWithdrawApprove, w.ID), reject)), true
+	case models.WithdrawalApproved:
+		return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			h.Codec.Button("💸 Выплачено", ActionWithdrawPaid, w.ID), reject)), true
+	}
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}, false
+}
+
+// withdrawalErrorText переводит ошибку проверки суммы в сообщение пользователю
+func (h *Handler) withdrawalErrorText(err error, balance money.Amount) string {
+	limits := h.Withdrawals.Limits()
+	switch {
+	case errors.Is(err, withdrawal.ErrBelowMinimum):
+		return fmt.Sprintf("Минимальная сумма вывода: %s.", limits.Min)
+	case errors.Is(err, withdrawal.ErrAboveMaximum):
+		return fmt.Sprintf("Максимальная сумма вывода: %s.", limits.Max)
+	case errors.Is(err, ledger.ErrInsufficientFunds):
+		return fmt.Sprintf("Недостаточно средств. Ваш баланс: %s.", balance)
+	case errors.Is(err, withdrawal.ErrMethodDeleted):
+		return "Эти реквизиты удалены. Начните оформление вывода заново."
+	}
+	return "Не удалось создать заявку. Пожалуйста, обратитесь в техподдержку."
+}
+
+// finishWithdrawalDialog завершает диалог вывода и возвращает меню
+func (h *Handler) finishWithdrawalDialog(ctx context.Context, chatID, telegramID int64, text string) {
+	if err := h.FSM.Finish(ctx, telegramID); err != nil {
+		log.Printf("Ошибка при сбросе состояния пользователя: %v", err)
+	}
+	h.clearTempData(tempWithdrawAmount, tempPayoutKind)(ctx, telegramID)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = h.Keyboard
+	h.Bot.Send(msg)
+}
+
+func maxText(limits withdrawal.Limits) string {
+	if limits.Max.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("\nМаксимум: %s", limits.Max)
+}
+
+// payoutMethodKeyboard - кнопки добавления новых реквизитов
+func payoutMethodKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton("➕ Новая карта")),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("➕ СБП по телефону"),
+			tgbotapi.NewKeyboardButton("➕ Кошелёк ЮMoney"),
+		),
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(fsm.CancelText)),
+	)
+}
+
+// payoutDetailsPrompt - подсказка для ввода реквизитов
+func payoutDetailsPrompt(kind payout.Kind) string {
+	switch kind {
+	case payout.KindSBP:
+		return "Введите номер телефона, привязанный к СБП, например +7 912 345-67-89."
+	case payout.KindYooMoney:
+		return "Введите номер кошелька ЮMoney (начинается с 4100)."
+	}
+	return "Введите номер банковской карты."
+}
+
+// capitalize делает первую букву сообщения заглавной
+func capitalize(s string) string {
+	for i, r := range s {
+		return strings.ToUpper(string(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
@@ -0,0 +1,137 @@
+// imagehash/imagehash.go
+package imagehash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Telegram пересылает фотографии в JPEG
+	_ "image/png"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Размер уменьшенного изображения: 9 столбцов дают 8 сравнений соседей в строке
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// Bits - длина хэша в битах
+const Bits = 64
+
+// MaxFileSize ограничивает размер скачиваемого изображения
+const MaxFileSize = 20 << 20
+
+// ErrTooLarge - файл больше MaxFileSize
+var ErrTooLarge = errors.New("изображение слишком большое")
+
+// client скачивает изображения; адрес файла Telegram содержит токен бота,
+// поэтому в ошибки он не попадает
+var client = &http.Client{Timeout: 15 * time.Second}
+
+// DHash вычисляет разностный перцептивный хэш: изображение уменьшается
+// до 9x8 в оттенках серого, каждый бит - ярче ли пиксель соседа справа.
+// Хэш почти не меняется при пересжатии, масштабировании и мелких правках.
+func DHash(img image.Image) uint64 {
+	px := shrink(img, hashWidth, hashHeight)
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if px[y*hashWidth+x] < px[y*hashWidth+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance - расстояние Хэмминга: число различающихся битов
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Fetch скачивает изображение по адресу и вычисляет его DHash
+func Fetch(ctx context.Context, fileURL string) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return 0, errors.New("некорректный адрес файла")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("ошибка при скачивании изображения: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("ошибка при скачивании изображения: HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength > MaxFileSize {
+		return 0, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, MaxFileSize))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при разборе изображения: %w", err)
+	}
+	return DHash(img), nil
+}
+
+// shrink уменьшает изображение до w x h, усредняя яркость по областям.
+// Из больших областей берётся не больше maxSamples x maxSamples точек.
+func shrink(img image.Image, w, h int) []float64 {
+	const maxSamples = 16
+	b := img.Bounds()
+	out := make([]float64, w*h)
+	if b.Empty() {
+		return out
+	}
+
+	for ty := 0; ty < h; ty++ {
+		y0, y1 := span(b.Min.Y, b.Dy(), ty, h)
+		stepY := max(1, (y1-y0)/maxSamples)
+		for tx := 0; tx < w; tx++ {
+			x0, x1 := span(b.Min.X, b.Dx(), tx, w)
+			stepX := max(1, (x1-x0)/maxSamples)
+
+			var sum float64
+			var n int
+			for y := y0; y < y1; y += stepY {
+				for x := x0; x < x1; x += stepX {
+					sum += luminance(img, x, y)
+					n++
+				}
+			}
+			out[ty*w+tx] = sum / float64(n)
+		}
+	}
+	return out
+}
+
+// span - границы i-й из parts полос отрезка длиной size, начиная со start.
+// Полоса не бывает пустой, даже если изображение меньше хэша.
+func span(start, size, i, parts int) (int, int) {
+	from := start + i*size/parts
+	to := start + (i+1)*size/parts
+	if to <= from {
+		to = from + 1
+	}
+	if to > start+size {
+		from, to = start+size-1, start+size
+	}
+	return from, to
+}
+
+// luminance - яркость точки по ITU-R BT.601
+func luminance(img image.Image, x, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+}
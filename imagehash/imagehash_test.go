@@ -0,0 +1,203 @@
+// imagehash/imagehash_test.go
+package imagehash
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// threshold - порог похожести по умолчанию (PHASH_MAX_DISTANCE)
+const threshold = 6
+
+// scene рисует изображение, похожее на скриншот: градиентный фон
+// и прямоугольники случайной яркости. Одинаковый seed - одинаковая картинка.
+func scene(w, h int, seed int64) *image.RGBA {
+	rnd := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(64 + 128*x/w)
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	for i := 0; i < 12; i++ {
+		x0, y0 := rnd.Intn(w), rnd.Intn(h)
+		x1, y1 := x0+w/8+rnd.Intn(w/3), y0+h/8+rnd.Intn(h/3)
+		c := color.RGBA{uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), 255}
+		for y := y0; y < min(y1, h); y++ {
+			for x := x0; x < min(x1, w); x++ {
+				img.Set(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+// resize масштабирует изображение методом ближайшего соседа
+func resize(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h))
+		}
+	}
+	return dst
+}
+
+// reencode пересжимает изображение в JPEG
+func reencode(t *testing.T, img image.Image, quality int) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDHashSimilar(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		orig := scene(720, 1280, seed)
+		hash := DHash(orig)
+
+		variants := map[string]image.Image{
+			"JPEG 90":         reencode(t, orig, 90),
+			"JPEG 40":         reencode(t, orig, 40),
+			"уменьшено вдвое": resize(orig, 360, 640),
+			"увеличено":       resize(orig, 1080, 1920),
+			"уменьшено и пересжато": reencode(t, resize(orig, 480, 853), 60),
+		}
+		for name, img := range variants {
+			if d := Distance(hash, DHash(img)); d > threshold {
+				t.Errorf("seed %d, %s: расстояние %d больше порога %d", seed, name, d, threshold)
+			}
+		}
+	}
+}
+
+func TestDHashUnrelated(t *testing.T) {
+	hashes := make([]uint64, 6)
+	for i := range hashes {
+		hashes[i] = DHash(scene(720, 1280, int64(100+i)))
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if d := Distance(hashes[i], hashes[j]); d <= threshold {
+				t.Errorf("разные изображения %d и %d: расстояние %d не больше порога %d", i, j, d, threshold)
+			}
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xff, 0x0f, 4},
+		{0, ^uint64(0), Bits},
+		{0x8000000000000001, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%#x, %#x) = %d, ожидалось %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSpan(t *testing.T) {
+	for _, size := range []int{1, 2, 5, 8, 9, 10, 100} {
+		for _, parts := range []int{hashWidth, hashHeight} {
+			const start = 3
+			prevFrom := start
+			for i := 0; i < parts; i++ {
+				from, to := span(start, size, i, parts)
+				if to <= from {
+					t.Fatalf("span(%d, %d, %d, %d) = [%d, %d): пустая полоса", start, size, i, parts, from, to)
+				}
+				if from < start || to > start+size {
+					t.Fatalf("span(%d, %d, %d, %d) = [%d, %d): выход за [%d, %d)", start, size, i, parts, from, to, start, start+size)
+				}
+				if from < prevFrom {
+					t.Fatalf("span(%d, %d, %d, %d) = [%d, %d): полосы идут не по порядку", start, size, i, parts, from, to)
+				}
+				prevFrom = from
+			}
+		}
+	}
+}
+
+func TestDHashSmallImages(t *testing.T) {
+	// Изображения меньше 9x8 не должны выходить за границы
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 1, 1),
+		image.Rect(0, 0, 3, 2),
+		image.Rect(0, 0, 8, 7),
+		image.Rect(5, 5, 9, 13),
+	} {
+		img := image.NewGray(r)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetGray(x, y, color.Gray{uint8(40 * (x - r.Min.X))})
+			}
+		}
+		// Яркость растёт слева направо: хотя бы одна пара соседей различается
+		if got := DHash(img); r.Dx() > 1 && got == 0 {
+			t.Errorf("DHash градиента %v = 0", r)
+		}
+	}
+
+	if got := DHash(image.NewGray(image.Rect(0, 0, 1, 1))); got != 0 {
+		t.Errorf("DHash однотонного 1x1 = %#x, ожидался 0", got)
+	}
+	if got := DHash(image.NewGray(image.Rectangle{})); got != 0 {
+		t.Errorf("DHash пустого изображения = %#x, ожидался 0", got)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	img := scene(320, 240, 7)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes())
+	})
+	mux.HandleFunc("/broken.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not an image"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx := context.Background()
+	hash, err := Fetch(ctx, srv.URL+"/photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != DHash(img) {
+		t.Errorf("Fetch = %#x, ожидался %#x", hash, DHash(img))
+	}
+	if _, err := Fetch(ctx, srv.URL+"/missing.png"); err == nil {
+		t.Error("Fetch несуществующего файла должен вернуть ошибку")
+	}
+	if _, err := Fetch(ctx, srv.URL+"/broken.png"); err == nil {
+		t.Error("Fetch не изображения должен вернуть ошибку")
+	}
+}
@@ -0,0 +1,257 @@
+// ledger/ledger.go
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram_bot/database"
+	"telegram_bot/models"
+	"telegram_bot/money"
+)
+
+// Account - код счёта в журнале
+type Account string
+
+// Системные счета. Кошелёк пользователя - UserWallet(userID).
+const (
+	PayoutClearing  Account = "payout_clearing"  // выплачено пользователям
+	WithdrawalHold  Account = "withdrawal_hold"  // заблокировано заявками на вывод
+	RewardExpense   Account = "reward_expense"   // расходы на вознаграждения за задания
+	ReferralExpense Account = "referral_expense" // расходы на реферальные бонусы
+	OpeningBalance  Account = "opening_balance"  // балансы, существовавшие до журнала
+)
+
+const walletPrefix = "user:"
+
+// Виды проводок
+const (
+	KindTaskReward         = "task_reward"
+	KindTaskRewardReversal = "task_reward_reversal" // отмена вознаграждения администратором
+	KindReferralBonus      = "referral_bonus"
+	KindReferralReversal   = "referral_reversal"
+	KindWithdrawalHold     = "withdrawal_hold"
+	KindWithdrawalRelease  = "withdrawal_release"
+	KindPayout             = "payout"
+)
+
+var (
+	// ErrUnbalanced - сумма строк проводки не равна нулю
+	ErrUnbalanced = errors.New("проводка не сбалансирована")
+	// ErrInsufficientFunds - списание увело бы кошелёк в минус
+	ErrInsufficientFunds = errors.New("недостаточно средств")
+	// ErrDuplicate - проводка с таким reference уже записана
+	ErrDuplicate = errors.New("проводка уже записана")
+)
+
+// UserWallet возвращает счёт кошелька пользователя по его внутреннему ID
+func UserWallet(userID int) Account {
+	return Account(walletPrefix + strconv.Itoa(userID))
+}
+
+// walletUser возвращает ID пользователя, если счёт - кошелёк
+func (a Account) walletUser() (int, bool) {
+	s, ok := strings.CutPrefix(string(a), walletPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(s)
+	return id, err == nil
+}
+
+// Line - строка проводки. Положительная сумма увеличивает остаток счёта.
+type Line struct {
+	Account Account
+	Amount  money.Amount
+}
+
+// Entry - проводка. Reference, если задан, защищает от повторной записи
+// одной и той же операции (например, двойного начисления за задание).
+type Entry struct {
+	Kind        string
+	Description string
+	Reference   string
+	Lines       []Line
+}
+
+// Transfer - проводка из двух строк: списание с from и зачисление на to
+func Transfer(kind, description, reference string, from, to Account, amount money.Amount) Entry {
+	return Entry{
+		Kind:        kind,
+		Description: description,
+		Reference:   reference,
+		Lines: []Line{
+			{Account: from, Amount: amount.Neg()},
+			{Account: to, Amount: amount},
+		},
+	}
+}
+
+// Ledger записывает проводки и поддерживает users.balance как кэш
+// остатка кошелька
+type Ledger struct {
+	db database.DBInterface
+}
+
+// New создаёт журнал
+func New(db database.DBInterface) *Ledger {
+	return &Ledger{db: db}
+}
+
+// Post записывает проводку. Если передан tx, запись выполняется в нём,
+// иначе в собственной транзакции. Вместе со строками обновляется
+// users.balance затронутых кошельков.
+func (l *Ledger) Post(ctx context.Context, tx database.DBInterface, e Entry) (int64, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	if err := validate(e); err != nil {
+		return 0, err
+	}
+
+	var entryID int64
+	err := tx.RunInTx(ctx, func(tx database.DBInterface) error {
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO ledger_entries (kind, description, reference)
+            VALUES ($1, $2, NULLIF($3, ''))
+            ON CONFLICT (reference) DO NOTHING
+            RETURNING id
+        `, e.Kind, e.Description, e.Reference).Scan(&entryID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDuplicate
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка при создании проводки: %w", err)
+		}
+
+		for _, line := range e.Lines {
+			accountID, err := accountID(ctx, tx, line.Account)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO ledger_postings (entry_id, account_id, amount) VALUES ($1, $2, $3)",
+				entryID, accountID, line.Amount)
+			if err != nil {
+				return fmt.Errorf("ошибка при записи строки проводки: %w", err)
+			}
+
+			if userID, ok := line.Account.walletUser(); ok {
+				if err := updateCachedBalance(ctx, tx, userID, line.Amount); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return entryID, err
+}
+
+// Balance возвращает остаток счёта по журналу
+func (l *Ledger) Balance(ctx context.Context, account Account) (money.Amount, error) {
+	var balance money.Amount
+	err := l.db.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(p.amount), 0)
+        FROM ledger_postings p
+        JOIN ledger_accounts a ON a.id = p.account_id
+        WHERE a.code = $1
+    `, string(account)).Scan(&balance)
+	return balance, err
+}
+
+// History возвращает последние операции по кошельку пользователя
+func (l *Ledger) History(ctx context.Context, userID int, limit int) ([]models.Transaction, error) {
+	rows, err := l.db.QueryContext(ctx, `
+        SELECT p.id, p.amount, e.description, e.created_at
+        FROM ledger_postings p
+        JOIN ledger_accounts a ON a.id = p.account_id
+        JOIN ledger_entries e ON e.id = p.entry_id
+        WHERE a.user_id = $1
+        ORDER BY e.created_at DESC, p.id DESC
+        LIMIT $2
+    `, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Transaction
+	for rows.Next() {
+		t := models.Transaction{UserID: userID}
+		var createdAt time.Time
+		if err := rows.Scan(&t.ID, &t.Amount, &t.Description, &createdAt); err != nil {
+			return nil, err
+		}
+		t.CreatedAt = createdAt.Format("02.01.2006 15:04")
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func validate(e Entry) error {
+	if len(e.Lines) < 2 {
+		return fmt.Errorf("%w: меньше двух строк", ErrUnbalanced)
+	}
+	var sum money.Amount
+	for _, line := range e.Lines {
+		if line.Account == "" {
+			return fmt.Errorf("%w: не указан счёт", ErrUnbalanced)
+		}
+		sum = sum.Add(line.Amount)
+	}
+	if !sum.IsZero() {
+		return fmt.Errorf("%w: сумма строк %s", ErrUnbalanced, sum)
+	}
+	return nil
+}
+
+// accountID возвращает ID счёта, создавая кошелёк пользователя при первой проводке
+func accountID(ctx context.Context, tx database.DBInterface, account Account) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM ledger_accounts WHERE code = $1", string(account)).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	userID, ok := account.walletUser()
+	if !ok {
+		return 0, fmt.Errorf("неизвестный счёт %q", account)
+	}
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO ledger_accounts (code, kind, user_id) VALUES ($1, 'wallet', $2)
+        ON CONFLICT (code) DO UPDATE SET code = EXCLUDED.code
+        RETURNING id
+    `, string(account), userID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при создании кошелька: %w", err)
+	}
+	return id, nil
+}
+
+// updateCachedBalance изменяет users.balance. Списание, уводящее баланс
+// в минус, отклоняется.
+func updateCachedBalance(ctx context.Context, tx database.DBInterface, userID int, amount money.Amount) error {
+	result, err := tx.ExecContext(ctx, `
+        UPDATE users SET balance = balance + $1, updated_at = NOW()
+        WHERE id = $2 AND balance + $1 >= 0
+    `, amount, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления баланса пользователя: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
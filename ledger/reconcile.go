@@ -0,0 +1,94 @@
+// ledger/reconcile.go
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"telegram_bot/money"
+)
+
+// Drift - расхождение кэша users.balance с остатком кошелька по журналу
+type Drift struct {
+	UserID int
+	Cached money.Amount
+	Ledger money.Amount
+}
+
+// Report - результат сверки
+type Report struct {
+	Drifts     []Drift // кошельки, не совпадающие с users.balance
+	Unbalanced []int64 // проводки с ненулевой суммой строк
+}
+
+// OK сообщает, что расхождений не найдено
+func (r *Report) OK() bool {
+	return len(r.Drifts) == 0 && len(r.Unbalanced) == 0
+}
+
+// String - краткое описание результата для лога и администраторов
+func (r *Report) String() string {
+	if r.OK() {
+		return "Сверка журнала: расхождений нет."
+	}
+
+	var b strings.Builder
+	b.WriteString("Сверка журнала: найдены расхождения.\n")
+	for _, d := range r.Drifts {
+		fmt.Fprintf(&b, "Пользователь %d: баланс %s, по журналу %s\n", d.UserID, d.Cached, d.Ledger)
+	}
+	for _, id := range r.Unbalanced {
+		fmt.Fprintf(&b, "Проводка %d не сбалансирована\n", id)
+	}
+	return b.String()
+}
+
+// Reconcile сверяет users.balance с журналом и проверяет баланс проводок.
+// Ничего не исправляет: расхождение - повод для разбора, а не для перезаписи.
+func (l *Ledger) Reconcile(ctx context.Context) (*Report, error) {
+	report := &Report{}
+
+	rows, err := l.db.QueryContext(ctx, `
+        SELECT u.id, u.balance, COALESCE(SUM(p.amount), 0) AS ledger_balance
+        FROM users u
+        LEFT JOIN ledger_accounts a ON a.user_id = u.id
+        LEFT JOIN ledger_postings p ON p.account_id = a.id
+        GROUP BY u.id, u.balance
+        HAVING u.balance <> COALESCE(SUM(p.amount), 0)
+        ORDER BY u.id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d Drift
+		if err := rows.Scan(&d.UserID, &d.Cached, &d.Ledger); err != nil {
+			return nil, err
+		}
+		report.Drifts = append(report.Drifts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = l.db.QueryContext(ctx, `
+        SELECT entry_id FROM ledger_postings
+        GROUP BY entry_id
+        HAVING SUM(amount) <> 0
+        ORDER BY entry_id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		report.Unbalanced = append(report.Unbalanced, id)
+	}
+	return report, rows.Err()
+}
@@ -5,11 +5,17 @@ import (
 	"context"
 	"log"
 	"os"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"telegram_bot/config"
 	"telegram_bot/database"
+	"telegram_bot/dispatcher"
 	"telegram_bot/handlers"
-	"telegram_bot/models"
+	"telegram_bot/router"
+	"telegram_bot/transport"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -23,117 +29,95 @@ func main() {
 		log.Printf(".env файл не найден, продолжаем с системными переменными")
 	}
 
-	// Инициализация БД
-	database.InitDB()
-	defer database.CloseDB()
+	// Служебные подкоманды
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "payouts":
+			runPayouts(os.Args[2:])
+			return
+		case "tasks":
+			runTasks(os.Args[2:])
+			return
+		case "proofs":
+			runProofs(os.Args[2:])
+			return
+		}
+	}
 
-	// Получение токена из переменных окружения
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if token == "" {
-		log.Fatal("TELEGRAM_BOT_TOKEN не задан в переменных окружения")
+	// Загрузка настроек
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	// SIGINT/SIGTERM прекращают получение обновлений и запускают остановку
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Инициализация Telegram бота
-	bot, err := tgbotapi.NewBotAPI(token)
+	bot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	bot.Debug = true
+	bot.Debug = cfg.Debug
 	log.Printf("Авторизовался на аккаунте %s", bot.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
-	updates := bot.GetUpdatesChan(u)
-
-	Database := database.NewDatabase()
-
-	// Определение администраторов (можно извлекать из базы данных)
+	// Инициализация БД
+	Database := database.InitDB()
+	checkSchema(Database)
 
-	handler := handlers.Handler{
-		DB:  Database,
-		Bot: bot,
+	handler, err := handlers.NewHandler(bot, Database, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := handler.ScheduleReconciliation(ctx); err != nil {
+		log.Printf("Не удалось запланировать сверку журнала: %v", err)
 	}
 
-	for update := range updates {
-		if update.Message != nil {
-			ctx := context.Background()
-			userID := update.Message.From.ID
-			var user models.User
-
-			// Проверяем состояние пользователя
-			userState, _ := handler.DB.GetUserState(ctx, userID)
-			if userState == "awaiting_card_number" {
-				handler.HandleCardNumberReceived(ctx, update)
-				continue
-			}
-
-			// Добавьте другие состояния по необходимости
-
-			// Обработка команд
-			if update.Message.IsCommand() {
-				switch update.Message.Command() {
-				case "start":
-					handler.Start(ctx, update)
-				default:
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Неизвестная команда.")
-					msg.ReplyMarkup = handler.AdminMenu
-					handler.Bot.Send(msg)
-				}
-				continue
-			}
-
-			// Обработка обычных текстовых сообщений
-			chatID := update.Message.Chat.ID
-			telegramID := update.Message.From.ID
-			text := update.Message.Text
-
-			// Проверка, является ли пользователь администратором
-			if user.Admin {
-				switch text {
-				case "Добавить задание":
-					handler.HandleAdminAddTask(ctx, update)
-				case "Проверить задания":
-					handler.HandleAdminCheckTasks(ctx, update)
-				case "Главное меню":
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Главное меню:")
-					msg.ReplyMarkup = handler.AdminMenu
-					handler.Bot.Send(msg)
-				default:
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Неизвестная команда. Пожалуйста, выберите действие из меню.")
-					msg.ReplyMarkup = handler.AdminMenu
-					handler.Bot.Send(msg)
-				}
-				continue
-			}
-
-			// Обработка сообщений обычных пользователей
-			switch text {
-			case "Показать баланс":
-				handler.ShowBalance(ctx, chatID, telegramID)
-			case "Личный кабинет":
-				handler.HandleShowAccount(ctx, update)
-			case "Взять задание":
-				handler.HandleAssignTask(ctx, update)
-			case "Вывести средства":
-				handler.HandleWithdrawRequest(ctx, update)
-			case "Обратиться в техподдержку":
-				handler.HandleSupport(ctx, update)
-			default:
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Команда не распознана. Пожалуйста, выберите действие из меню.")
-				msg.ReplyMarkup = handler.Keyboard
-				handler.Bot.Send(msg)
-			}
-
-			if update.CallbackQuery != nil {
-				data := update.CallbackQuery.Data
-				if strings.HasPrefix(data, "starttask") || strings.HasPrefix(data, "nextstage") {
-					handler.HandleTaskAction(context.Background(), update)
-				} else if strings.HasPrefix(data, "verify") {
-					handler.HandleAdminCheckTasks(context.Background(), update)
-				}
-			}
-		}
+	r := router.New(handler)
+	registerRoutes(r)
+
+	// Обновления обрабатываются параллельно, с сохранением порядка для каждого пользователя
+	d := dispatcher.New(dispatcher.Config{
+		Workers:        cfg.Dispatch.Workers,
+		QueueSize:      cfg.Dispatch.QueueSize,
+		EnqueueTimeout: cfg.Dispatch.EnqueueTimeout,
+		HandleTimeout:  cfg.Dispatch.UpdateTimeout,
+	}, func(ctx context.Context, update tgbotapi.Update) {
+		r.Dispatch(ctx, update)
+	})
+
+	// Фоновые задачи останавливаются вместе с получением обновлений
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		d.LogStats(ctx, time.Minute)
+	}()
+	go func() {
+		defer background.Done()
+		handler.Scheduler.Run(ctx)
+	}()
+
+	// Long polling и webhook передают обновления в общий диспетчер.
+	// Отказ диспетчера возвращается транспорту, и Telegram повторит доставку.
+	if err := transport.Run(ctx, bot, cfg, d.Submit); err != nil {
+		log.Printf("Ошибка получения обновлений: %v", err)
+	}
+	stop()
+	log.Printf("Получение обновлений остановлено, завершаем обработку...")
+
+	// Дожидаемся обработчиков, уже получивших обновления
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := d.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Не все обновления обработаны до остановки: %v", err)
 	}
+	background.Wait()
+
+	database.CloseDB()
 }
@@ -0,0 +1,81 @@
+// migrate.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"telegram_bot/database"
+	"telegram_bot/migrations"
+)
+
+// runMigrate выполняет подкоманду: migrate up | down [N] | status
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "использование: migrate up | down [N] | status")
+		os.Exit(2)
+	}
+
+	db := database.InitDB()
+	defer database.CloseDB()
+
+	runner, err := migrations.NewRunner(db.SQLDB())
+	if err != nil {
+		log.Fatalf("Не удалось загрузить миграции: %v", err)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := runner.Up(ctx)
+		if err != nil {
+			log.Fatalf("Ошибка применения миграций: %v", err)
+		}
+		fmt.Printf("Применено миграций: %d\n", len(applied))
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("Некорректное число шагов отката: %q", args[1])
+			}
+		}
+		reverted, err := runner.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("Ошибка отката миграций: %v", err)
+		}
+		fmt.Printf("Откачено миграций: %d\n", len(reverted))
+
+	case "status":
+		list, err := runner.Status(ctx)
+		if err != nil {
+			log.Fatalf("Ошибка получения статуса миграций: %v", err)
+		}
+		for _, st := range list {
+			applied := "не применена"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", st.Version, st.Name, applied)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда migrate %q\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// checkSchema останавливает запуск, если схема базы устарела
+func checkSchema(db *database.Database) {
+	runner, err := migrations.NewRunner(db.SQLDB())
+	if err != nil {
+		log.Fatalf("Не удалось загрузить миграции: %v", err)
+	}
+	if err := runner.Check(context.Background()); err != nil {
+		log.Fatalf("%v. Выполните: %s migrate up", err, os.Args[0])
+	}
+}
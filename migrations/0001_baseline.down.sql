@@ -0,0 +1,7 @@
+-- migrations/0001_baseline.down.sql
+
+DROP TABLE IF EXISTS temp_data;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS user_tasks;
+DROP TABLE IF EXISTS tasks;
+DROP TABLE IF EXISTS users;
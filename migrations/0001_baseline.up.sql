@@ -1,4 +1,6 @@
--- migrations/schema.sql
+-- migrations/0001_baseline.up.sql
+-- Исходная схема. Написана идемпотентно, чтобы её можно было применить
+-- к базе, созданной старым schema.sql.
 
 -- Создание таблицы пользователей
 CREATE TABLE IF NOT EXISTS users (
@@ -22,12 +24,6 @@ ALTER TABLE users
 ALTER TABLE users
     ADD CONSTRAINT users_referrer_id_fkey FOREIGN KEY (referrer_id) REFERENCES users(id);
 
-INSERT INTO users (telegram_id, admin, created_at)
-VALUES
-(790745265, TRUE, NOW()),
-(884539153, TRUE, NOW()),
-(908077320, TRUE, NOW())
-ON CONFLICT (telegram_id) DO UPDATE SET admin = TRUE;
 -- Создание индекса на столбец referrer_id в таблице users
 CREATE INDEX IF NOT EXISTS idx_users_referrer_id ON users(referrer_id);
 
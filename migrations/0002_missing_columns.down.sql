@@ -0,0 +1,17 @@
+-- migrations/0002_missing_columns.down.sql
+
+ALTER TABLE temp_data
+    ALTER COLUMN user_id TYPE INTEGER;
+
+ALTER TABLE user_tasks
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS is_completed,
+    DROP COLUMN IF EXISTS screenshot_file_id,
+    DROP COLUMN IF EXISTS category;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS state_changed_at,
+    DROP COLUMN IF EXISTS admin;
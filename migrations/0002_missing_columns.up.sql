@@ -0,0 +1,29 @@
+-- migrations/0002_missing_columns.up.sql
+-- Столбцы, которые использует код, но не создавала исходная схема
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS admin BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS state_changed_at TIMESTAMP;
+
+ALTER TABLE tasks
+    ADD COLUMN IF NOT EXISTS category VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS screenshot_file_id VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS is_completed BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE user_tasks
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+-- Временные данные хранятся по Telegram ID, а не по users.id
+ALTER TABLE temp_data
+    DROP CONSTRAINT IF EXISTS temp_data_user_id_fkey;
+ALTER TABLE temp_data
+    ALTER COLUMN user_id TYPE BIGINT;
+
+-- Администраторы
+INSERT INTO users (telegram_id, admin, created_at)
+VALUES
+(790745265, TRUE, NOW()),
+(884539153, TRUE, NOW()),
+(908077320, TRUE, NOW())
+ON CONFLICT (telegram_id) DO UPDATE SET admin = TRUE;
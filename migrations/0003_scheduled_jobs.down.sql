@@ -0,0 +1,3 @@
+-- migrations/0003_scheduled_jobs.down.sql
+
+DROP TABLE IF EXISTS scheduled_jobs;
@@ -0,0 +1,32 @@
+-- migrations/0003_scheduled_jobs.up.sql
+
+-- Создание таблицы отложенных задач планировщика
+CREATE TABLE IF NOT EXISTS scheduled_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    run_at TIMESTAMP NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 8,
+    last_error TEXT,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Индекс для опроса наступивших задач
+CREATE INDEX IF NOT EXISTS idx_scheduled_jobs_due ON scheduled_jobs(run_at)
+    WHERE status IN ('pending', 'running');
+
+-- Перенос напоминаний об этапах, сохранённых до появления планировщика
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'user_tasks' AND column_name = 'stage_notify_at') THEN
+        INSERT INTO scheduled_jobs (kind, payload, run_at)
+        SELECT 'stage_unlock', json_build_object('user_task_id', id, 'stage', current_stage), stage_notify_at
+        FROM user_tasks WHERE stage_notify_at IS NOT NULL;
+        ALTER TABLE user_tasks DROP COLUMN stage_notify_at;
+    END IF;
+END $$;
@@ -0,0 +1,7 @@
+-- migrations/0004_ledger.down.sql
+
+ALTER TABLE users ALTER COLUMN balance DROP NOT NULL;
+
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_accounts;
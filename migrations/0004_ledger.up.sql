@@ -0,0 +1,61 @@
+-- migrations/0004_ledger.up.sql
+-- Двойная запись: каждая проводка состоит из строк, сумма которых равна нулю.
+-- Положительная сумма увеличивает остаток счёта, отрицательная - уменьшает.
+
+CREATE TABLE ledger_accounts (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(64) UNIQUE NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    user_id INTEGER UNIQUE REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    reference VARCHAR(128) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE ledger_postings (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES ledger_entries(id),
+    account_id INTEGER NOT NULL REFERENCES ledger_accounts(id),
+    amount DECIMAL(12, 2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_ledger_postings_account_id ON ledger_postings(account_id);
+CREATE INDEX idx_ledger_postings_entry_id ON ledger_postings(entry_id);
+
+-- Системные счета
+INSERT INTO ledger_accounts (code, kind) VALUES
+    ('payout_clearing', 'clearing'),
+    ('reward_expense', 'expense'),
+    ('referral_expense', 'expense'),
+    ('opening_balance', 'equity');
+
+-- Кошельки и входящие остатки для пользователей с ненулевым балансом,
+-- чтобы users.balance сразу совпадал с журналом
+INSERT INTO ledger_accounts (code, kind, user_id)
+SELECT 'user:' || id, 'wallet', id FROM users WHERE COALESCE(balance, 0) <> 0;
+
+WITH opening AS (
+    INSERT INTO ledger_entries (kind, description, reference)
+    SELECT 'opening_balance', 'Входящий остаток', 'opening:' || id
+    FROM users WHERE COALESCE(balance, 0) <> 0
+    RETURNING id, reference
+)
+INSERT INTO ledger_postings (entry_id, account_id, amount)
+SELECT o.id, a.id, u.balance
+FROM opening o
+JOIN users u ON o.reference = 'opening:' || u.id
+JOIN ledger_accounts a ON a.user_id = u.id
+UNION ALL
+SELECT o.id, (SELECT id FROM ledger_accounts WHERE code = 'opening_balance'), -u.balance
+FROM opening o
+JOIN users u ON o.reference = 'opening:' || u.id;
+
+UPDATE users SET balance = 0 WHERE balance IS NULL;
+ALTER TABLE users ALTER COLUMN balance SET NOT NULL;
@@ -0,0 +1,8 @@
+-- migrations/0005_withdrawals.down.sql
+
+DROP TABLE IF EXISTS withdrawal_events;
+DROP TABLE IF EXISTS withdrawals;
+
+DELETE FROM ledger_accounts a
+WHERE a.code = 'withdrawal_hold'
+  AND NOT EXISTS (SELECT 1 FROM ledger_postings p WHERE p.account_id = a.id);
@@ -0,0 +1,31 @@
+-- migrations/0005_withdrawals.up.sql
+-- Заявки на вывод средств и журнал их изменений
+
+CREATE TABLE withdrawals (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    destination TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_withdrawals_user_id ON withdrawals(user_id);
+CREATE INDEX idx_withdrawals_status ON withdrawals(status);
+
+CREATE TABLE withdrawal_events (
+    id BIGSERIAL PRIMARY KEY,
+    withdrawal_id BIGINT NOT NULL REFERENCES withdrawals(id),
+    from_status VARCHAR(20) NOT NULL DEFAULT '',
+    to_status VARCHAR(20) NOT NULL,
+    actor_telegram_id BIGINT,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_withdrawal_events_withdrawal_id ON withdrawal_events(withdrawal_id);
+
+-- Средства заявок, ожидающих решения
+INSERT INTO ledger_accounts (code, kind) VALUES ('withdrawal_hold', 'clearing');
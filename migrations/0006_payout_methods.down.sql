@@ -0,0 +1,5 @@
+-- migrations/0006_payout_methods.down.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS payout_operator;
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS payout_method_id;
+DROP TABLE IF EXISTS payout_methods;
@@ -0,0 +1,26 @@
+-- migrations/0006_payout_methods.up.sql
+-- Сохранённые реквизиты для выплат. Реквизиты хранятся только в
+-- зашифрованном виде (AES-GCM), label содержит маскированное представление.
+
+CREATE TABLE payout_methods (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    kind VARCHAR(20) NOT NULL,
+    label VARCHAR(64) NOT NULL,
+    details BYTEA NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    deleted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Одни и те же реквизиты пользователь сохраняет один раз
+CREATE UNIQUE INDEX idx_payout_methods_fingerprint
+    ON payout_methods(user_id, fingerprint) WHERE deleted_at IS NULL;
+
+ALTER TABLE withdrawals ADD COLUMN payout_method_id BIGINT REFERENCES payout_methods(id);
+
+-- Номера карт в старых заявках хранились открыто: оставляем только маску
+UPDATE withdrawals
+SET destination = '•••• ' || RIGHT(REGEXP_REPLACE(destination, '\D', '', 'g'), 4);
+
+ALTER TABLE users ADD COLUMN payout_operator BOOLEAN NOT NULL DEFAULT FALSE;
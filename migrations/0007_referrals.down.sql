@@ -0,0 +1,4 @@
+-- migrations/0007_referrals.down.sql
+
+DROP TABLE IF EXISTS referral_earnings;
+ALTER TABLE users DROP COLUMN IF EXISTS referred_at;
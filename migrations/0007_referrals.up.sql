@@ -0,0 +1,19 @@
+-- migrations/0007_referrals.up.sql
+-- Реферальная программа: момент привязки и начисления рефереру
+
+ALTER TABLE users ADD COLUMN referred_at TIMESTAMP;
+UPDATE users SET referred_at = created_at WHERE referrer_id IS NOT NULL;
+
+CREATE TABLE referral_earnings (
+    id BIGSERIAL PRIMARY KEY,
+    referrer_id INTEGER NOT NULL REFERENCES users(id),
+    referee_id INTEGER NOT NULL REFERENCES users(id),
+    user_task_id INTEGER REFERENCES user_tasks(id),
+    kind VARCHAR(20) NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL,
+    entry_id BIGINT NOT NULL REFERENCES ledger_entries(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_referral_earnings_referrer_id ON referral_earnings(referrer_id);
+CREATE INDEX idx_referral_earnings_referee_id ON referral_earnings(referee_id);
@@ -0,0 +1,5 @@
+-- migrations/0008_referral_tiers.down.sql
+
+DROP INDEX IF EXISTS idx_referral_earnings_cap;
+ALTER TABLE referral_earnings DROP COLUMN IF EXISTS level;
+DROP TABLE IF EXISTS referral_tiers;
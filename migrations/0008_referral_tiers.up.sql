@@ -0,0 +1,14 @@
+-- migrations/0008_referral_tiers.up.sql
+-- Многоуровневые реферальные начисления: процент и месячный лимит по уровням
+
+CREATE TABLE referral_tiers (
+    level INTEGER PRIMARY KEY CHECK (level > 0),
+    percent_bp INTEGER NOT NULL CHECK (percent_bp > 0 AND percent_bp <= 10000),
+    monthly_cap DECIMAL(12, 2) NOT NULL DEFAULT 0 CHECK (monthly_cap >= 0),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE referral_earnings ADD COLUMN level INTEGER NOT NULL DEFAULT 1;
+
+CREATE INDEX idx_referral_earnings_cap
+    ON referral_earnings(referrer_id, level, created_at);
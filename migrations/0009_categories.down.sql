@@ -0,0 +1,9 @@
+-- migrations/0009_categories.down.sql
+
+DROP INDEX IF EXISTS idx_tasks_category_active;
+
+UPDATE tasks SET category = c.names->>'ru'
+FROM categories c
+WHERE tasks.category = c.slug;
+
+DROP TABLE IF EXISTS categories;
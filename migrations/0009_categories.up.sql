@@ -0,0 +1,29 @@
+-- migrations/0009_categories.up.sql
+-- Категории заданий хранятся в базе и редактируются администратором
+
+CREATE TABLE categories (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(50) NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9][a-z0-9_-]*$'),
+    names JSONB NOT NULL,
+    emoji VARCHAR(16) NOT NULL DEFAULT '',
+    default_reward DECIMAL(12, 2) NOT NULL DEFAULT 0 CHECK (default_reward >= 0),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Прежний фиксированный набор категорий и вознаграждений
+INSERT INTO categories (slug, names, default_reward, sort_order) VALUES
+    ('avito', '{"ru": "Авито", "en": "Avito"}', 130, 10),
+    ('yandex', '{"ru": "Яндекс", "en": "Yandex"}', 25, 20),
+    ('google', '{"ru": "Google", "en": "Google"}', 25, 30),
+    ('2gis', '{"ru": "2GIS", "en": "2GIS"}', 25, 40);
+
+-- В tasks.category теперь хранится slug категории
+UPDATE tasks SET category = c.slug
+FROM categories c
+WHERE tasks.category = c.names->>'ru' OR lower(tasks.category) = c.slug;
+UPDATE tasks SET category = 'google' WHERE category = 'Гугл';
+
+CREATE INDEX IF NOT EXISTS idx_tasks_category_active ON tasks(category, is_active);
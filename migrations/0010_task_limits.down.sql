@@ -0,0 +1,13 @@
+-- migrations/0010_task_limits.down.sql
+
+DROP INDEX IF EXISTS idx_user_tasks_task_user;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS reward;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS exhausted_at,
+    DROP COLUMN IF EXISTS spent,
+    DROP COLUMN IF EXISTS taken,
+    DROP COLUMN IF EXISTS per_user_limit,
+    DROP COLUMN IF EXISTS budget,
+    DROP COLUMN IF EXISTS max_completions,
+    DROP COLUMN IF EXISTS reward;
@@ -0,0 +1,38 @@
+-- migrations/0010_task_limits.up.sql
+-- Вознаграждение, квота, бюджет и лимит на пользователя у каждого задания
+
+ALTER TABLE tasks
+    ADD COLUMN reward DECIMAL(12, 2) NOT NULL DEFAULT 0 CHECK (reward >= 0),
+    ADD COLUMN max_completions INTEGER CHECK (max_completions > 0),
+    ADD COLUMN budget DECIMAL(12, 2) CHECK (budget >= 0),
+    ADD COLUMN per_user_limit INTEGER NOT NULL DEFAULT 1 CHECK (per_user_limit > 0),
+    ADD COLUMN taken INTEGER NOT NULL DEFAULT 0 CHECK (taken >= 0),
+    ADD COLUMN spent DECIMAL(12, 2) NOT NULL DEFAULT 0 CHECK (spent >= 0),
+    ADD COLUMN exhausted_at TIMESTAMP;
+
+-- Вознаграждение берётся из категории, как раньше считал CalculateReward
+UPDATE tasks SET reward = c.default_reward
+FROM categories c
+WHERE tasks.category = c.slug;
+
+-- Раньше задание можно было взять только один раз
+UPDATE tasks SET max_completions = 1;
+
+-- Занятые места и потраченный бюджет по уже выданным заданиям
+UPDATE tasks SET
+    taken = s.taken,
+    spent = s.taken * tasks.reward
+FROM (
+    SELECT task_id, COUNT(*) AS taken FROM user_tasks
+    WHERE status IN ('in_progress', 'completed', 'verified_correct')
+    GROUP BY task_id
+) s
+WHERE tasks.id = s.task_id;
+
+-- Вознаграждение фиксируется при выдаче задания
+ALTER TABLE user_tasks ADD COLUMN reward DECIMAL(12, 2);
+UPDATE user_tasks SET reward = t.reward FROM tasks t WHERE user_tasks.task_id = t.id;
+UPDATE user_tasks SET reward = 0 WHERE reward IS NULL;
+ALTER TABLE user_tasks ALTER COLUMN reward SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_user_tasks_task_user ON user_tasks(task_id, user_id);
@@ -0,0 +1,8 @@
+-- migrations/0011_task_templates.down.sql
+
+UPDATE users SET state = 'awaiting_screenshot' WHERE state = 'awaiting_task_proof';
+
+DROP TABLE IF EXISTS user_task_proofs;
+ALTER TABLE tasks DROP COLUMN IF EXISTS template_id;
+DROP TABLE IF EXISTS task_template_steps;
+DROP TABLE IF EXISTS task_templates;
@@ -0,0 +1,61 @@
+-- migrations/0011_task_templates.up.sql
+-- Шаблоны заданий: упорядоченные шаги с доказательствами, задержками и сроками
+
+CREATE TABLE task_templates (
+    id SERIAL PRIMARY KEY,
+    category VARCHAR(50) NOT NULL REFERENCES categories(slug) ON UPDATE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- У категории не больше одного шаблона по умолчанию
+CREATE UNIQUE INDEX idx_task_templates_default ON task_templates(category) WHERE is_default;
+
+CREATE TABLE task_template_steps (
+    id SERIAL PRIMARY KEY,
+    template_id INTEGER NOT NULL REFERENCES task_templates(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL CHECK (position > 0),
+    instruction TEXT NOT NULL,
+    proof_type VARCHAR(20) NOT NULL CHECK (proof_type IN ('none', 'screenshot', 'text', 'link', 'screenshots')),
+    proof_count INTEGER NOT NULL DEFAULT 1 CHECK (proof_count > 0),
+    delay_seconds INTEGER NOT NULL DEFAULT 0 CHECK (delay_seconds >= 0),
+    deadline_seconds INTEGER CHECK (deadline_seconds > 0),
+    UNIQUE (template_id, position)
+);
+
+ALTER TABLE tasks ADD COLUMN template_id INTEGER REFERENCES task_templates(id);
+
+CREATE TABLE user_task_proofs (
+    id BIGSERIAL PRIMARY KEY,
+    user_task_id INTEGER NOT NULL REFERENCES user_tasks(id),
+    stage INTEGER NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    file_id VARCHAR(255),
+    content TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_task_proofs_user_task ON user_task_proofs(user_task_id, stage);
+
+-- Прежние три этапа SendTaskStage - шаблон по умолчанию для каждой категории
+WITH templates AS (
+    INSERT INTO task_templates (category, name, is_default)
+    SELECT slug, 'Избранное и отзыв', TRUE FROM categories
+    RETURNING id
+)
+INSERT INTO task_template_steps (template_id, position, instruction, proof_type, delay_seconds)
+SELECT t.id, s.position, s.instruction, s.proof_type, s.delay_seconds
+FROM templates t
+CROSS JOIN (VALUES
+    (1, 'Первый этап задания. Нажмите «Далее» после выполнения.', 'none', 0),
+    (2, 'Пришлите скриншот экрана с добавлением объявления в избранное.', 'screenshot', 3600),
+    (3, 'Пришлите скриншот с отзывом.', 'screenshot', 18000)
+) AS s(position, instruction, proof_type, delay_seconds);
+
+UPDATE tasks SET template_id = t.id
+FROM task_templates t
+WHERE t.category = tasks.category AND t.is_default;
+
+-- Ожидание скриншота стало общим ожиданием доказательства
+UPDATE users SET state = 'awaiting_task_proof' WHERE state = 'awaiting_screenshot';
@@ -0,0 +1,7 @@
+-- migrations/0012_category_domains.down.sql
+
+ALTER TABLE categories DROP COLUMN IF EXISTS domains;
+
+-- Новых шагов мастера добавления задания до этой миграции не было
+UPDATE users SET state = ''
+WHERE state IN ('awaiting_task_link', 'awaiting_task_example', 'awaiting_task_reward', 'awaiting_task_confirm');
@@ -0,0 +1,13 @@
+-- migrations/0012_category_domains.up.sql
+-- Домены площадки: ссылка задания должна вести на один из них
+
+ALTER TABLE categories ADD COLUMN domains JSONB NOT NULL DEFAULT '[]';
+
+UPDATE categories SET domains = '["avito.ru"]' WHERE slug = 'avito';
+UPDATE categories SET domains = '["yandex.ru", "yandex.com", "ya.ru"]' WHERE slug = 'yandex';
+UPDATE categories SET domains = '["google.com", "google.ru", "goo.gl"]' WHERE slug = 'google';
+UPDATE categories SET domains = '["2gis.ru", "2gis.com"]' WHERE slug = '2gis';
+
+-- Заглушки, которые сохранял прежний мастер добавления задания
+UPDATE tasks SET link = NULL WHERE link = 'https://example.com';
+UPDATE tasks SET screenshot_file_id = NULL WHERE screenshot_file_id = 'file_id_12345';
@@ -0,0 +1,23 @@
+-- migrations/0013_rejection_reasons.down.sql
+
+-- Ожидающие повторной отправки и обжалованные выполнения считаются отклонёнными.
+-- Места заданий, занятые ожидающими повторной отправки, освобождаются.
+UPDATE tasks SET
+    taken = GREATEST(taken - s.n, 0),
+    spent = GREATEST(spent - s.amount, 0)
+FROM (
+    SELECT task_id, COUNT(*) AS n, SUM(reward) AS amount FROM user_tasks
+    WHERE status = 'resubmit_requested'
+    GROUP BY task_id
+) s
+WHERE tasks.id = s.task_id;
+UPDATE user_tasks SET status = 'verified_incorrect' WHERE status IN ('resubmit_requested', 'appealed');
+
+UPDATE users SET state = ''
+WHERE state IN ('awaiting_rejection_text', 'awaiting_rejection_reason_input', 'awaiting_appeal_text');
+
+ALTER TABLE users DROP COLUMN IF EXISTS senior_moderator;
+ALTER TABLE user_task_proofs DROP COLUMN IF EXISTS superseded;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS appealed_at, DROP COLUMN IF EXISTS resubmits;
+DROP TABLE IF EXISTS moderation_decisions;
+DROP TABLE IF EXISTS rejection_reasons;
@@ -0,0 +1,43 @@
+-- migrations/0013_rejection_reasons.up.sql
+-- Причины отклонения, повторная отправка доказательств, апелляции и история решений
+
+CREATE TABLE rejection_reasons (
+    id SERIAL PRIMARY KEY,
+    text VARCHAR(500) NOT NULL,
+    allow_resubmit BOOLEAN NOT NULL DEFAULT FALSE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO rejection_reasons (text, allow_resubmit, sort_order) VALUES
+    ('Скриншот нечитаем или обрезан', TRUE, 10),
+    ('На скриншоте не то задание', TRUE, 20),
+    ('Отзыв не опубликован или удалён', FALSE, 30),
+    ('Задание выполнено не по инструкции', FALSE, 40),
+    ('Повторное или поддельное доказательство', FALSE, 50);
+
+CREATE TABLE moderation_decisions (
+    id BIGSERIAL PRIMARY KEY,
+    user_task_id INTEGER NOT NULL REFERENCES user_tasks(id),
+    moderator_id BIGINT, -- Telegram ID модератора, NULL - действие исполнителя
+    decision VARCHAR(20) NOT NULL CHECK (decision IN (
+        'approved', 'rejected', 'resubmit', 'appealed', 'appeal_approved', 'appeal_rejected'
+    )),
+    reason TEXT,
+    reason_id INTEGER REFERENCES rejection_reasons(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_moderation_decisions_user_task ON moderation_decisions(user_task_id, id);
+
+-- Номер попытки: отложенные задачи прежних попыток не трогают задание
+ALTER TABLE user_tasks
+    ADD COLUMN resubmits INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN appealed_at TIMESTAMP;
+
+-- Доказательства, заменённые при повторной отправке
+ALTER TABLE user_task_proofs ADD COLUMN superseded BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Апелляции рассматривают старшие модераторы
+ALTER TABLE users ADD COLUMN senior_moderator BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+-- migrations/0014_moderation_lease.down.sql
+
+DROP TABLE IF EXISTS moderation_messages;
+DROP INDEX IF EXISTS idx_user_tasks_queue;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS lease_until, DROP COLUMN IF EXISTS moderator_id;
@@ -0,0 +1,20 @@
+-- migrations/0014_moderation_lease.up.sql
+-- Выполнение на проверке закрепляется за модератором на время аренды
+
+ALTER TABLE user_tasks
+    ADD COLUMN moderator_id BIGINT, -- Telegram ID модератора, взявшего выполнение
+    ADD COLUMN lease_until TIMESTAMP;
+
+CREATE INDEX idx_user_tasks_queue ON user_tasks(last_updated) WHERE status = 'completed';
+
+-- Карточки выполнения, отправленные модераторам: их правят, когда выполнение
+-- берёт или проверяет другой модератор
+CREATE TABLE moderation_messages (
+    id BIGSERIAL PRIMARY KEY,
+    user_task_id INTEGER NOT NULL REFERENCES user_tasks(id),
+    chat_id BIGINT NOT NULL,
+    message_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_moderation_messages_user_task ON moderation_messages(user_task_id);
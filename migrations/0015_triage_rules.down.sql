@@ -0,0 +1,22 @@
+-- migrations/0015_triage_rules.down.sql
+
+UPDATE users SET state = '' WHERE state = 'awaiting_triage_rule';
+
+DROP INDEX IF EXISTS idx_user_task_proofs_file_unique_id;
+ALTER TABLE user_task_proofs DROP COLUMN IF EXISTS file_unique_id;
+ALTER TABLE user_tasks DROP COLUMN IF EXISTS assigned_at;
+ALTER TABLE users DROP COLUMN IF EXISTS trust_level;
+
+-- Автоматические решения остаются в истории как решения модератора
+DROP INDEX IF EXISTS idx_moderation_decisions_auto;
+ALTER TABLE moderation_decisions DROP COLUMN IF EXISTS rule_id;
+ALTER TABLE moderation_decisions DROP CONSTRAINT moderation_decisions_decision_check;
+UPDATE moderation_decisions SET decision = 'approved' WHERE decision = 'auto_approved';
+UPDATE moderation_decisions SET decision = 'rejected' WHERE decision = 'auto_rejected';
+DELETE FROM moderation_decisions WHERE decision = 'auto_reverted';
+ALTER TABLE moderation_decisions
+    ADD CONSTRAINT moderation_decisions_decision_check CHECK (decision IN (
+        'approved', 'rejected', 'resubmit', 'appealed', 'appeal_approved', 'appeal_rejected'
+    ));
+
+DROP TABLE IF EXISTS triage_rules;
@@ -0,0 +1,36 @@
+-- migrations/0015_triage_rules.up.sql
+-- Правила автоматической проверки выполнений и сигналы для них
+
+CREATE TABLE triage_rules (
+    id SERIAL PRIMARY KEY,
+    category VARCHAR(50) NOT NULL DEFAULT '*', -- slug категории, '*' - любая
+    action VARCHAR(20) NOT NULL CHECK (action IN ('approve', 'reject', 'review')),
+    conditions JSONB NOT NULL DEFAULT '[]',
+    position INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Автоматические решения и их отмена администратором
+ALTER TABLE moderation_decisions DROP CONSTRAINT moderation_decisions_decision_check;
+ALTER TABLE moderation_decisions
+    ADD CONSTRAINT moderation_decisions_decision_check CHECK (decision IN (
+        'approved', 'rejected', 'resubmit', 'appealed', 'appeal_approved', 'appeal_rejected',
+        'auto_approved', 'auto_rejected', 'auto_reverted'
+    )),
+    ADD COLUMN rule_id INTEGER REFERENCES triage_rules(id);
+
+CREATE INDEX idx_moderation_decisions_auto ON moderation_decisions(id)
+    WHERE decision IN ('auto_approved', 'auto_rejected');
+
+-- Уровень доверия исполнителя задаёт администратор
+ALTER TABLE users ADD COLUMN trust_level INTEGER NOT NULL DEFAULT 0;
+
+-- Время выдачи задания; у старых выполнений неизвестно
+ALTER TABLE user_tasks ADD COLUMN assigned_at TIMESTAMP;
+ALTER TABLE user_tasks ALTER COLUMN assigned_at SET DEFAULT NOW();
+
+-- Постоянный идентификатор файла Telegram для поиска повторных скриншотов
+ALTER TABLE user_task_proofs ADD COLUMN file_unique_id VARCHAR(64);
+CREATE INDEX idx_user_task_proofs_file_unique_id ON user_task_proofs(file_unique_id)
+    WHERE file_unique_id IS NOT NULL;
@@ -0,0 +1,3 @@
+-- migrations/0016_proof_phash.down.sql
+
+ALTER TABLE user_task_proofs DROP COLUMN IF EXISTS phash;
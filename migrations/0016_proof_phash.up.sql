@@ -0,0 +1,4 @@
+-- migrations/0016_proof_phash.up.sql
+-- Перцептивный хэш скриншотов для поиска повторно присланных изображений
+
+ALTER TABLE user_task_proofs ADD COLUMN phash BIGINT; -- DHash, 64 бита
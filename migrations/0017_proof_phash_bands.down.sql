@@ -0,0 +1,4 @@
+-- migrations/0017_proof_phash_bands.down.sql
+
+DROP INDEX IF EXISTS idx_user_task_proofs_phash_bands;
+ALTER TABLE user_task_proofs DROP COLUMN IF EXISTS phash_bands;
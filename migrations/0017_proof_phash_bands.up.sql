@@ -0,0 +1,20 @@
+-- migrations/0017_proof_phash_bands.up.sql
+-- Полосы перцептивного хэша для индексного поиска похожих скриншотов.
+-- Хэш делится на 8 байтов, каждый хранится как номер_байта * 256 + значение.
+-- Хэши, различающиеся не больше чем на 7 битов, совпадают хотя бы в одной
+-- полосе, поэтому GIN-индекс отбирает кандидатов без полного перебора.
+
+ALTER TABLE user_task_proofs ADD COLUMN phash_bands INTEGER[] GENERATED ALWAYS AS (ARRAY[
+    (phash & 255)::int,
+    256 + ((phash >> 8) & 255)::int,
+    512 + ((phash >> 16) & 255)::int,
+    768 + ((phash >> 24) & 255)::int,
+    1024 + ((phash >> 32) & 255)::int,
+    1280 + ((phash >> 40) & 255)::int,
+    1536 + ((phash >> 48) & 255)::int,
+    1792 + ((phash >> 56) & 255)::int
+]) STORED;
+
+CREATE INDEX idx_user_task_proofs_phash_bands
+    ON user_task_proofs USING GIN (phash_bands)
+    WHERE NOT superseded;
@@ -0,0 +1,6 @@
+-- migrations/0018_referral_tier_disabled.down.sql
+
+DELETE FROM referral_tiers WHERE percent_bp = 0;
+ALTER TABLE referral_tiers DROP CONSTRAINT referral_tiers_percent_bp_check;
+ALTER TABLE referral_tiers
+    ADD CONSTRAINT referral_tiers_percent_bp_check CHECK (percent_bp > 0 AND percent_bp <= 10000);
@@ -0,0 +1,7 @@
+-- migrations/0018_referral_tier_disabled.up.sql
+-- Отключённый уровень хранится строкой с нулевым процентом: пустая таблица
+-- означает, что уровни не настроены и действует REFERRAL_PERCENT
+
+ALTER TABLE referral_tiers DROP CONSTRAINT referral_tiers_percent_bp_check;
+ALTER TABLE referral_tiers
+    ADD CONSTRAINT referral_tiers_percent_bp_check CHECK (percent_bp >= 0 AND percent_bp <= 10000);
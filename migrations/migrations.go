@@ -0,0 +1,283 @@
+// migrations/migrations.go
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockKey - ключ advisory lock, под которым выполняются миграции.
+// Несколько экземпляров бота, запущенных одновременно, применяют миграции по очереди.
+const lockKey = 7_305_512_001
+
+// ErrOutdated возвращается, если в базе применены не все миграции
+var ErrOutdated = errors.New("схема базы данных устарела")
+
+var fileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration - одна версия схемы
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status - состояние одной миграции в базе
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load читает встроенные файлы миграций, упорядоченные по версии
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := fileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(files, path.Join(".", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("миграция %d: разные имена %q и %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("миграция %d_%s: нет файла up", mig.Version, mig.Name)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Runner применяет и откатывает миграции
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewRunner создаёт Runner для встроенных миграций
+func NewRunner(db *sql.DB) (*Runner, error) {
+	list, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: db, migrations: list}, nil
+}
+
+// Up применяет все неприменённые миграции. Возвращает применённые.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range r.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := r.apply(ctx, conn, mig, true); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down откатывает последние steps применённых миграций
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := r.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("миграция %d_%s не поддерживает откат", mig.Version, mig.Name)
+			}
+			if err := r.apply(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status возвращает состояние всех известных миграций
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Status, 0, len(r.migrations))
+	for _, mig := range r.migrations {
+		st := Status{Migration: mig}
+		if at, ok := done[mig.Version]; ok {
+			at := at
+			st.AppliedAt = &at
+		}
+		list = append(list, st)
+	}
+	return list, nil
+}
+
+// Check возвращает ErrOutdated, если есть неприменённые миграции
+func (r *Runner) Check(ctx context.Context) error {
+	list, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, st := range list {
+		if st.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%04d_%s", st.Version, st.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: не применены миграции %v", ErrOutdated, pending)
+	}
+	return nil
+}
+
+// withLock выполняет fn на отдельном соединении под advisory lock
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("не удалось получить блокировку миграций: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			log.Printf("Не удалось снять блокировку миграций: %v", err)
+		}
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply выполняет миграцию и запись в schema_migrations в одной транзакции
+func (r *Runner) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	body, direction := mig.Up, "up"
+	if !up {
+		body, direction = mig.Down, "down"
+	}
+
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		return fmt.Errorf("миграция %04d_%s (%s): %w", mig.Version, mig.Name, direction, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", mig.Version, mig.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Миграция %04d_%s: %s", mig.Version, mig.Name, direction)
+	return nil
+}
+
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name VARCHAR(255) NOT NULL,
+            applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+        )
+    `)
+	return err
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
@@ -5,9 +5,24 @@ type State string
 const (
 	StateNone                     State = ""
 	StateAwaitingTaskCategory     State = "awaiting_task_category"
+	StateAwaitingTaskLink         State = "awaiting_task_link"
 	StateAwaitingTaskDescription  State = "awaiting_task_description"
-	StateAwaitingTaskScreenshot   State = "awaiting_screenshot"
+	StateAwaitingTaskExample      State = "awaiting_task_example"
+	StateAwaitingTaskReward       State = "awaiting_task_reward"
+	StateAwaitingTaskConfirm      State = "awaiting_task_confirm"
+	StateAwaitingTaskProof        State = "awaiting_task_proof"
 	StateAwaitingCardNumder       State = "awaiting_card_number"
 	StateawaitingTaskCategoryUser State = "awaiting_task_category_user"
+	StateAwaitingWithdrawalAmount State = "awaiting_withdrawal_amount"
+	StateAwaitingPayoutMethod     State = "awaiting_payout_method"
+	StateAwaitingPayoutResults    State = "awaiting_payout_results"
+	StateAwaitingReferralTier     State = "awaiting_referral_tier"
+	StateAwaitingCategoryInput    State = "awaiting_category_input"
+	StateAwaitingTemplateInput    State = "awaiting_template_input"
+	StateAwaitingTaskImport       State = "awaiting_task_import"
+	StateAwaitingRejectionText    State = "awaiting_rejection_text"
+	StateAwaitingReasonInput      State = "awaiting_rejection_reason_input"
+	StateAwaitingAppealText       State = "awaiting_appeal_text"
+	StateAwaitingTriageRule       State = "awaiting_triage_rule"
 	// Добавьте другие состояния по необходимости
 )
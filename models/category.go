@@ -0,0 +1,74 @@
+// models/category.go
+package models
+
+import (
+	"strings"
+	"time"
+
+	"telegram_bot/money"
+)
+
+// DefaultLanguage - язык названий категорий по умолчанию
+const DefaultLanguage = "ru"
+
+// Category - категория заданий (площадка для отзывов)
+type Category struct {
+	ID            int
+	Slug          string            // постоянный идентификатор, хранится в tasks.category
+	Names         map[string]string // названия по языкам: {"ru": "Авито", "en": "Avito"}
+	Emoji         string
+	Domains       []string // домены площадки для ссылок заданий, пусто - любые
+	DefaultReward money.Amount
+	IsActive      bool
+	SortOrder     int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Name возвращает название на языке lang или на языке по умолчанию
+func (c *Category) Name(lang string) string {
+	if name := c.Names[lang]; name != "" {
+		return name
+	}
+	if name := c.Names[DefaultLanguage]; name != "" {
+		return name
+	}
+	return c.Slug
+}
+
+// Button - текст кнопки категории на клавиатуре
+func (c *Category) Button(lang string) string {
+	if c.Emoji == "" {
+		return c.Name(lang)
+	}
+	return c.Emoji + " " + c.Name(lang)
+}
+
+// Matches сообщает, соответствует ли текст кнопке или названию категории
+// на любом языке
+func (c *Category) Matches(text string) bool {
+	if text == c.Slug {
+		return true
+	}
+	for lang, name := range c.Names {
+		if text == name || text == c.Button(lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsHost сообщает, относится ли хост ссылки к площадке категории.
+// Поддомены разрешены: m.avito.ru подходит для avito.ru.
+func (c *Category) AllowsHost(host string) bool {
+	if len(c.Domains) == 0 {
+		return true
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, d := range c.Domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
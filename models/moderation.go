@@ -0,0 +1,45 @@
+// models/moderation.go
+package models
+
+import "time"
+
+// RejectionReason - причина отклонения, которую модератор выбирает кнопкой
+type RejectionReason struct {
+	ID            int
+	Text          string
+	AllowResubmit bool // исполнитель может исправить доказательства
+	IsActive      bool
+	SortOrder     int
+}
+
+// Решения по выполнению задания
+const (
+	DecisionApproved       = "approved"
+	DecisionRejected       = "rejected"
+	DecisionResubmit       = "resubmit"
+	DecisionAppealed       = "appealed"
+	DecisionAppealApproved = "appeal_approved"
+	DecisionAppealRejected = "appeal_rejected"
+	DecisionAutoApproved   = "auto_approved" // по правилу автоматической проверки
+	DecisionAutoRejected   = "auto_rejected"
+	DecisionAutoReverted   = "auto_reverted" // автоматическое решение отменено администратором
+)
+
+// ModerationDecision - запись истории решений по выполнению
+type ModerationDecision struct {
+	ID          int64
+	UserTaskID  int
+	ModeratorID *int64 // Telegram ID модератора, nil - действие исполнителя
+	Decision    string
+	Reason      string
+	ReasonID    *int
+	RuleID      *int // правило автоматической проверки
+	CreatedAt   time.Time
+}
+
+// ModerationMessage - карточка выполнения, отправленная модератору
+type ModerationMessage struct {
+	UserTaskID int
+	ChatID     int64
+	MessageID  int
+}
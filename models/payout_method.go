@@ -0,0 +1,15 @@
+// models/payout_method.go
+package models
+
+import "time"
+
+// PayoutMethod - сохранённые реквизиты для выплат. Сами реквизиты
+// хранятся зашифрованными, в модели только маскированное представление.
+type PayoutMethod struct {
+	ID        int64
+	UserID    int
+	Kind      string
+	Label     string
+	CreatedAt time.Time
+	DeletedAt *time.Time // когда пользователь удалил реквизиты
+}
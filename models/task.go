@@ -3,16 +3,25 @@ package models
 
 import (
 	"time"
+
+	"telegram_bot/money"
 )
 
 type Task struct {
 	ID               int
 	UserID           int
-	Category         Category
+	Category         string // slug категории
 	Description      string
 	IsActive         bool
 	CreatedAt        time.Time
 	Status           Status
 	Link             string
 	ScreenshotFileID string
+	Reward           money.Amount  // вознаграждение за одно выполнение
+	MaxCompletions   *int          // квота выполнений, nil - без ограничения
+	Budget           *money.Amount // общий бюджет, nil - без ограничения
+	PerUserLimit     int           // сколько раз один пользователь может выполнить задание
+	Taken            int           // занято мест: выданные, сданные и одобренные выполнения
+	Spent            money.Amount  // зарезервировано и выплачено из бюджета
+	TemplateID       *int          // шаблон шагов, nil - шаблон категории по умолчанию
 }
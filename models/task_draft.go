@@ -0,0 +1,44 @@
+// models/task_draft.go
+package models
+
+import "telegram_bot/money"
+
+// TaskDraft - черновик задания в мастере добавления. Хранится во временных
+// данных администратора до подтверждения.
+type TaskDraft struct {
+	Category       string        `json:"category"`
+	Link           string        `json:"link"`
+	Description    string        `json:"description"`
+	ExampleFileID  string        `json:"example_file_id,omitempty"` // пример выполнения
+	Reward         money.Amount  `json:"reward"`
+	MaxCompletions *int          `json:"max_completions,omitempty"`
+	Budget         *money.Amount `json:"budget,omitempty"`
+	PerUserLimit   int           `json:"per_user_limit"`
+	TemplateID     *int          `json:"template_id,omitempty"`
+	Editing        bool          `json:"editing,omitempty"` // после правки поля вернуться к предпросмотру
+}
+
+// Task возвращает задание, описанное черновиком
+func (d *TaskDraft) Task() *Task {
+	return &Task{
+		Category:         d.Category,
+		Description:      d.Description,
+		Link:             d.Link,
+		IsActive:         true,
+		Status:           "New",
+		ScreenshotFileID: d.ExampleFileID,
+		Reward:           d.Reward,
+		MaxCompletions:   d.MaxCompletions,
+		Budget:           d.Budget,
+		PerUserLimit:     d.PerUserLimit,
+		TemplateID:       d.TemplateID,
+	}
+}
+
+// SetLimits переносит в черновик вознаграждение и лимиты задания
+func (d *TaskDraft) SetLimits(t *Task) {
+	d.Reward = t.Reward
+	d.MaxCompletions = t.MaxCompletions
+	d.Budget = t.Budget
+	d.PerUserLimit = t.PerUserLimit
+}
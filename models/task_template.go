@@ -0,0 +1,63 @@
+// models/task_template.go
+package models
+
+import "time"
+
+// ProofType - вид доказательства выполнения шага
+type ProofType string
+
+const (
+	ProofNone        ProofType = "none"        // достаточно нажать «Далее»
+	ProofScreenshot  ProofType = "screenshot"  // один скриншот
+	ProofText        ProofType = "text"        // текстовый ответ
+	ProofLink        ProofType = "link"        // ссылка
+	ProofScreenshots ProofType = "screenshots" // несколько скриншотов, см. TemplateStep.ProofCount
+)
+
+// IsPhoto сообщает, ожидаются ли фотографии
+func (p ProofType) IsPhoto() bool {
+	return p == ProofScreenshot || p == ProofScreenshots
+}
+
+// TaskTemplate - упорядоченный набор шагов задания для категории
+type TaskTemplate struct {
+	ID        int
+	Category  string // slug категории
+	Name      string
+	IsDefault bool // используется для новых заданий категории
+	Steps     []TemplateStep
+	CreatedAt time.Time
+}
+
+// TemplateStep - шаг шаблона. Номер шага совпадает с user_tasks.current_stage.
+type TemplateStep struct {
+	Position    int
+	Instruction string
+	ProofType   ProofType
+	ProofCount  int           // сколько доказательств нужно, обычно 1
+	Delay       time.Duration // сколько ждать после предыдущего шага
+	Deadline    time.Duration // срок на выполнение шага, 0 - без срока
+}
+
+// UserTaskProof - доказательство, присланное на шаге задания
+type UserTaskProof struct {
+	ID         int64
+	UserTaskID int
+	Stage      int
+	Kind       ProofType
+	FileID     string
+	UniqueID   string  // file_unique_id: одинаков у одного файла для всех ботов и чатов
+	PHash      *uint64 // перцептивный хэш скриншота, nil - не вычислен
+	Content    string
+	CreatedAt  time.Time
+}
+
+// SimilarProof - скриншот другого выполнения, похожий на доказательство
+type SimilarProof struct {
+	ProofID    int64 // доказательство проверяемого выполнения
+	Stage      int
+	UserTaskID int // выполнение с похожим скриншотом
+	UserID     int
+	TaskID     int
+	Distance   int // различающихся битов хэша
+}
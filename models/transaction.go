@@ -1,10 +1,12 @@
 // models/transaction.go
 package models
 
+import "telegram_bot/money"
+
 type Transaction struct {
 	ID          int
 	UserID      int
-	Amount      float64
+	Amount      money.Amount
 	Description string
 	CreatedAt   string
 }
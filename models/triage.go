@@ -0,0 +1,44 @@
+// models/triage.go
+package models
+
+import "time"
+
+// Действия правил автоматической проверки
+const (
+	TriageApprove = "approve" // одобрить без модератора
+	TriageReject  = "reject"  // отклонить без модератора
+	TriageReview  = "review"  // отправить модератору
+)
+
+// TriageAnyCategory - правило для всех категорий
+const TriageAnyCategory = "*"
+
+// TriageCondition - условие правила: сигнал, сравнение и порог
+type TriageCondition struct {
+	Signal string  `json:"signal"`
+	Op     string  `json:"op"`
+	Value  float64 `json:"value"`
+}
+
+// TriageRule - правило автоматической проверки. Правила проверяются
+// по Position, срабатывает первое, у которого выполнены все условия.
+type TriageRule struct {
+	ID         int
+	Category   string
+	Action     string
+	Conditions []TriageCondition
+	Position   int
+	IsActive   bool
+}
+
+// AutoDecision - автоматическое решение для журнала администратора
+type AutoDecision struct {
+	ID         int64
+	UserTaskID int
+	TaskID     int
+	Decision   string
+	Reason     string
+	RuleID     *int
+	Latest     bool // после решения других не было, его можно отменить
+	CreatedAt  time.Time
+}
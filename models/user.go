@@ -1,14 +1,18 @@
 // models/user.go
 package models
 
-import "time"
+import (
+	"time"
+
+	"telegram_bot/money"
+)
 
 type User struct {
 	ID          int
 	TelegramID  int64
 	Admin       bool
 	Username    string
-	Balance     float64
+	Balance     money.Amount
 	State       State
 	AvailableAt time.Time
 	CreatedAt   time.Time
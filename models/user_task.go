@@ -1,6 +1,23 @@
 // models/user_task.go
 package models
 
+import (
+	"time"
+
+	"telegram_bot/money"
+)
+
+// Статусы выполнения задания пользователем
+const (
+	UserTaskInProgress = "in_progress"
+	UserTaskCompleted  = "completed"
+	UserTaskApproved   = "verified_correct"
+	UserTaskRejected   = "verified_incorrect"
+	UserTaskExpired    = "expired"
+	UserTaskResubmit   = "resubmit_requested" // ждёт повторной отправки доказательств
+	UserTaskAppealed   = "appealed"           // отклонение обжаловано исполнителем
+)
+
 type UserTask struct {
 	ID           int
 	UserID       int
@@ -9,4 +26,18 @@ type UserTask struct {
 	Screenshots  []string
 	CurrentStage int
 	LastUpdated  string
+	Reward       money.Amount // вознаграждение, зафиксированное при выдаче
+	Resubmits    int          // сколько раз доказательства отправлялись заново
+	AppealedAt   *time.Time   // когда исполнитель обжаловал отклонение
+	ModeratorID  *int64       // Telegram ID модератора, взявшего выполнение на проверку
+	LeaseUntil   *time.Time   // до какого времени выполнение закреплено за модератором
+}
+
+// ExecutorStats - история выполнений пользователя, которую видит модератор
+type ExecutorStats struct {
+	Approved  int
+	Rejected  int
+	Expired   int
+	Submitted int          // ожидают проверки, включая текущее
+	Earned    money.Amount // сумма одобренных вознаграждений
 }
@@ -0,0 +1,41 @@
+// models/withdrawal.go
+package models
+
+import (
+	"time"
+
+	"telegram_bot/money"
+)
+
+// Статусы заявки на вывод средств
+const (
+	WithdrawalRequested = "requested" // создана, средства ещё не заблокированы
+	WithdrawalHeld      = "held"      // средства заблокированы, ждёт решения
+	WithdrawalApproved  = "approved"  // одобрена, ждёт перевода
+	WithdrawalPaid      = "paid"      // деньги отправлены
+	WithdrawalRejected  = "rejected"  // отклонена, средства возвращены
+	WithdrawalFailed    = "failed"    // перевод не прошёл, средства возвращены
+)
+
+type Withdrawal struct {
+	ID             int64
+	UserID         int
+	Amount         money.Amount
+	Destination    string // маскированные реквизиты
+	PayoutMethodID *int64
+	Status         string
+	Comment        string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// WithdrawalEvent - запись журнала изменений заявки
+type WithdrawalEvent struct {
+	ID           int64
+	WithdrawalID int64
+	FromStatus   string
+	ToStatus     string
+	ActorID      *int64 // Telegram ID администратора, nil - пользователь или система
+	Comment      string
+	CreatedAt    time.Time
+}
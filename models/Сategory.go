@@ -1,10 +0,0 @@
-package models
-
-type Category string
-
-const (
-	CategoryAvito  Category = "Авито"
-	CategoryYandex Category = "Яндекс"
-	CategoryGoogle Category = "Google"
-	Category2GIS   Category = "2GIS"
-)
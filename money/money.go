@@ -0,0 +1,221 @@
+// money/money.go
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount - денежная сумма в копейках. Целое число исключает ошибки
+// округления при сложении вознаграждений и выплат.
+type Amount int64
+
+// Zero - нулевая сумма
+const Zero Amount = 0
+
+// ErrInvalid возвращается, если строку не удалось разобрать как сумму
+var ErrInvalid = errors.New("некорректная сумма")
+
+// Kopecks создаёт сумму из копеек
+func Kopecks(k int64) Amount {
+	return Amount(k)
+}
+
+// Rubles создаёт сумму из целого числа рублей
+func Rubles(r int64) Amount {
+	return Amount(r * 100)
+}
+
+// Kopecks возвращает сумму в копейках
+func (a Amount) Kopecks() int64 {
+	return int64(a)
+}
+
+// Add возвращает a + b
+func (a Amount) Add(b Amount) Amount {
+	return a + b
+}
+
+// Sub возвращает a - b
+func (a Amount) Sub(b Amount) Amount {
+	return a - b
+}
+
+// Neg возвращает -a
+func (a Amount) Neg() Amount {
+	return -a
+}
+
+// Mul умножает сумму на целое число
+func (a Amount) Mul(n int64) Amount {
+	return a * Amount(n)
+}
+
+// Percent возвращает p процентов от суммы, p задаётся в сотых долях
+// процента (1250 = 12,5%). Округление - до ближайшей копейки, половина
+// копейки округляется от нуля.
+func (a Amount) Percent(basisPoints int64) Amount {
+	num := int64(a) * basisPoints
+	q, r := num/10000, num%10000
+	if r*2 >= 10000 {
+		q++
+	} else if r*2 <= -10000 {
+		q--
+	}
+	return Amount(q)
+}
+
+// Cmp возвращает -1, 0 или 1, если a меньше, равна или больше b
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// IsZero сообщает, что сумма равна нулю
+func (a Amount) IsZero() bool { return a == 0 }
+
+// IsPositive сообщает, что сумма больше нуля
+func (a Amount) IsPositive() bool { return a > 0 }
+
+// IsNegative сообщает, что сумма меньше нуля
+func (a Amount) IsNegative() bool { return a < 0 }
+
+// Abs возвращает модуль суммы
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// String форматирует сумму для пользователя: "1 300,00 ₽"
+func (a Amount) String() string {
+	return a.Format() + " ₽"
+}
+
+// Format форматирует сумму без знака валюты: "1 300,00"
+func (a Amount) Format() string {
+	k := int64(a)
+	sign := ""
+	if k < 0 {
+		sign = "-"
+		k = -k
+	}
+
+	rubles := strconv.FormatInt(k/100, 10)
+	var b strings.Builder
+	for i, r := range rubles {
+		if i > 0 && (len(rubles)-i)%3 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return fmt.Sprintf("%s%s,%02d", sign, b.String(), k%100)
+}
+
+// Decimal возвращает сумму в виде десятичной строки для SQL и CSV: "1300.00"
+func (a Amount) Decimal() string {
+	k := int64(a)
+	sign := ""
+	if k < 0 {
+		sign = "-"
+		k = -k
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, k/100, k%100)
+}
+
+// Parse разбирает сумму в рублях. Допускаются запятая или точка в качестве
+// разделителя, пробелы между разрядами и знак ₽: "130", "130,5", "1 300.00 ₽".
+// Больше двух знаков после запятой - ошибка.
+func Parse(s string) (Amount, error) {
+	clean := strings.TrimSpace(s)
+	clean = strings.TrimSuffix(clean, "₽")
+	clean = strings.TrimSuffix(strings.TrimSpace(clean), "руб.")
+	clean = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\u00a0', '\u202f':
+			return -1
+		case ',':
+			return '.'
+		}
+		return r
+	}, clean)
+
+	negative := strings.HasPrefix(clean, "-")
+	clean = strings.TrimPrefix(strings.TrimPrefix(clean, "-"), "+")
+
+	whole, frac, hasFrac := strings.Cut(clean, ".")
+	if whole == "" && (!hasFrac || frac == "") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	if len(frac) > 2 || !digits(whole) || !digits(frac) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	for len(frac) < 2 {
+		frac += "0"
+	}
+
+	var rubles int64
+	if whole != "" {
+		var err error
+		rubles, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil || rubles > math.MaxInt64/100-1 {
+			return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+	}
+	kop, _ := strconv.ParseInt(frac, 10, 64)
+
+	a := Amount(rubles*100 + kop)
+	if negative {
+		a = -a
+	}
+	return a, nil
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Scan реализует sql.Scanner для столбцов DECIMAL
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case string:
+		parsed, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*a = parsed
+		return nil
+	case []byte:
+		return a.Scan(string(v))
+	case int64:
+		*a = Rubles(v)
+		return nil
+	case float64:
+		*a = Amount(math.Round(v * 100))
+		return nil
+	}
+	return fmt.Errorf("money: неподдерживаемый тип %T", src)
+}
+
+// Value реализует driver.Valuer: сумма передаётся в базу десятичной строкой
+func (a Amount) Value() (driver.Value, error) {
+	return a.Decimal(), nil
+}
@@ -0,0 +1,127 @@
+// money/money_test.go
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  bool
+	}{
+		{in: "130", want: Rubles(130)},
+		{in: "130,5", want: Kopecks(13050)},
+		{in: "130.5", want: Kopecks(13050)},
+		{in: "130,05", want: Kopecks(13005)},
+		{in: ",5", want: Kopecks(50)},
+		{in: "130.", want: Rubles(130)},
+		{in: "1 300.00 ₽", want: Rubles(1300)},
+		{in: "1 300,00", want: Rubles(1300)},
+		{in: "50 руб.", want: Rubles(50)},
+		{in: "+7", want: Rubles(7)},
+		{in: "-12,34", want: Kopecks(-1234)},
+		{in: "-0,01", want: Kopecks(-1)},
+		{in: "0", want: Zero},
+		{in: "92233720368547757,99", want: Kopecks(math.MaxInt64/100*100 - 1)},
+
+		{in: "", err: true},
+		{in: ".", err: true},
+		{in: "-", err: true},
+		{in: "1,234", err: true},
+		{in: "0.001", err: true},
+		{in: "1.2.3", err: true},
+		{in: "1,2,3", err: true},
+		{in: "12a", err: true},
+		{in: "--5", err: true},
+		{in: "1e3", err: true},
+		{in: "92233720368547758", err: true},
+		{in: "9223372036854775807", err: true},
+		{in: "99999999999999999999", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse(%q) = %v, %v; ожидалась ErrInvalid", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %v, %v; ожидалось %v", tt.in, got.Kopecks(), err, tt.want.Kopecks())
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{Zero, "0,00"},
+		{Kopecks(5), "0,05"},
+		{Kopecks(-5), "-0,05"},
+		{Rubles(130), "130,00"},
+		{Kopecks(130050), "1 300,50"},
+		{Rubles(1234567), "1 234 567,00"},
+		{Kopecks(-123456789), "-1 234 567,89"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Format(); got != tt.want {
+			t.Errorf("Format(%d) = %q, ожидалось %q", tt.in.Kopecks(), got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	amounts := []Amount{
+		Zero, Kopecks(1), Kopecks(-1), Kopecks(99), Rubles(100),
+		Kopecks(130050), Kopecks(-123456789), Amount(math.MaxInt64/100*100 - 1),
+	}
+	for _, a := range amounts {
+		var formatted Amount
+		if err := formatted.Scan(a.Format()); err != nil || formatted != a {
+			t.Errorf("Scan(Format(%d)) = %d, %v", a.Kopecks(), formatted.Kopecks(), err)
+		}
+
+		v, err := a.Value()
+		if err != nil {
+			t.Fatalf("Value(%d): %v", a.Kopecks(), err)
+		}
+		var scanned Amount
+		if err := scanned.Scan([]byte(v.(string))); err != nil || scanned != a {
+			t.Errorf("Scan(Value(%d)) = %d, %v", a.Kopecks(), scanned.Kopecks(), err)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Amount
+	}{
+		{nil, Zero},
+		{"12.30", Kopecks(1230)},
+		{[]byte("-0.50"), Kopecks(-50)},
+		{int64(7), Rubles(7)},
+		{float64(0.29), Kopecks(29)},
+	}
+	for _, tt := range tests {
+		a := Kopecks(1)
+		if err := a.Scan(tt.src); err != nil || a != tt.want {
+			t.Errorf("Scan(%#v) = %d, %v; ожидалось %d", tt.src, a.Kopecks(), err, tt.want.Kopecks())
+		}
+	}
+
+	var a Amount
+	if err := a.Scan(true); err == nil {
+		t.Error("Scan(bool) должен вернуть ошибку")
+	}
+	if err := a.Scan("1.234"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Scan(\"1.234\") = %v, ожидалась ErrInvalid", err)
+	}
+}
@@ -0,0 +1,223 @@
+// payout/method.go
+package payout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind - способ выплаты
+type Kind string
+
+const (
+	KindCard     Kind = "card"     // банковская карта
+	KindSBP      Kind = "sbp"      // Система быстрых платежей по номеру телефона
+	KindYooMoney Kind = "yoomoney" // кошелёк ЮMoney
+)
+
+// Mask - символ маскирования реквизитов
+const Mask = "••••"
+
+var (
+	// ErrInvalidCard - номер карты не прошёл проверку
+	ErrInvalidCard = errors.New("некорректный номер карты")
+	// ErrInvalidPhone - номер телефона не подходит для СБП
+	ErrInvalidPhone = errors.New("некорректный номер телефона")
+	// ErrInvalidWallet - номер кошелька ЮMoney не прошёл проверку
+	ErrInvalidWallet = errors.New("некорректный номер кошелька ЮMoney")
+	// ErrUnknownKind - неизвестный способ выплаты
+	ErrUnknownKind = errors.New("неизвестный способ выплаты")
+)
+
+// Details - проверенные и нормализованные реквизиты
+type Details struct {
+	Kind   Kind
+	Value  string // номер карты, телефона или кошелька без разделителей
+	Scheme string // платёжная система карты
+}
+
+// Title возвращает название способа выплаты
+func (k Kind) Title() string {
+	switch k {
+	case KindCard:
+		return "Карта"
+	case KindSBP:
+		return "СБП"
+	case KindYooMoney:
+		return "ЮMoney"
+	}
+	return string(k)
+}
+
+// Parse проверяет реквизиты выбранного способа выплаты
+func Parse(kind Kind, input string) (Details, error) {
+	switch kind {
+	case KindCard:
+		return parseCard(input)
+	case KindSBP:
+		return parsePhone(input)
+	case KindYooMoney:
+		return parseWallet(input)
+	}
+	return Details{}, ErrUnknownKind
+}
+
+// Label - маскированное представление: "Мир •••• 1234"
+func (d Details) Label() string {
+	prefix := d.Kind.Title()
+	if d.Scheme != "" {
+		prefix = d.Scheme
+	}
+	return fmt.Sprintf("%s %s %s", prefix, Mask, last4(d.Value))
+}
+
+// Full - полные реквизиты для исполнителя выплат. Не используйте в логах
+// и сообщениях пользователям.
+func (d Details) Full() string {
+	prefix := d.Kind.Title()
+	if d.Scheme != "" {
+		prefix += " " + d.Scheme
+	}
+
+	value := d.Value
+	switch d.Kind {
+	case KindCard:
+		var groups []string
+		for i := 0; i < len(value); i += 4 {
+			groups = append(groups, value[i:min(i+4, len(value))])
+		}
+		value = strings.Join(groups, " ")
+	case KindSBP:
+		if len(value) == 12 {
+			value = fmt.Sprintf("%s %s %s-%s-%s", value[:2], value[2:5], value[5:8], value[8:10], value[10:])
+		}
+	}
+	return prefix + ": " + value
+}
+
+// cardScheme - платёжная система и допустимые длины номера
+type cardScheme struct {
+	name    string
+	lengths []int
+}
+
+// schemeOf определяет платёжную систему по BIN (первым цифрам номера)
+func schemeOf(number string) (cardScheme, bool) {
+	prefix := func(n int) int {
+		v := 0
+		for _, r := range number[:n] {
+			v = v*10 + int(r-'0')
+		}
+		return v
+	}
+
+	switch p4, p2 := prefix(4), prefix(2); {
+	case p4 >= 2200 && p4 <= 2204:
+		return cardScheme{"Мир", []int{16, 17, 18, 19}}, true
+	case p4 >= 2221 && p4 <= 2720, p2 >= 51 && p2 <= 55:
+		return cardScheme{"Mastercard", []int{16}}, true
+	case number[0] == '4':
+		return cardScheme{"Visa", []int{13, 16, 19}}, true
+	case p2 == 62:
+		return cardScheme{"UnionPay", []int{16, 17, 18, 19}}, true
+	case p2 == 50, p2 >= 56 && p2 <= 69:
+		return cardScheme{"Maestro", []int{12, 13, 14, 15, 16, 17, 18, 19}}, true
+	}
+	return cardScheme{}, false
+}
+
+func parseCard(input string) (Details, error) {
+	number := stripSeparators(input)
+	if len(number) < 12 || len(number) > 19 || !isDigits(number) {
+		return Details{}, fmt.Errorf("%w: номер должен содержать от 12 до 19 цифр", ErrInvalidCard)
+	}
+
+	scheme, ok := schemeOf(number)
+	if !ok {
+		return Details{}, fmt.Errorf("%w: платёжная система не поддерживается", ErrInvalidCard)
+	}
+	if !containsInt(scheme.lengths, len(number)) {
+		return Details{}, fmt.Errorf("%w: неверная длина номера для карты %s", ErrInvalidCard, scheme.name)
+	}
+	if !luhn(number) {
+		return Details{}, fmt.Errorf("%w: ошибка в номере, проверьте цифры", ErrInvalidCard)
+	}
+	return Details{Kind: KindCard, Value: number, Scheme: scheme.name}, nil
+}
+
+// parsePhone принимает мобильный номер РФ в форматах +7, 7 или 8
+// и приводит его к виду +79XXXXXXXXX
+func parsePhone(input string) (Details, error) {
+	digits := stripSeparators(strings.TrimPrefix(strings.TrimSpace(input), "+"))
+	digits = strings.NewReplacer("(", "", ")", "").Replace(digits)
+	if len(digits) == 11 && (digits[0] == '7' || digits[0] == '8') {
+		digits = digits[1:]
+	}
+	if len(digits) != 10 || digits[0] != '9' || !isDigits(digits) {
+		return Details{}, fmt.Errorf("%w: укажите мобильный номер в формате +7 9XX XXX-XX-XX", ErrInvalidPhone)
+	}
+	return Details{Kind: KindSBP, Value: "+7" + digits}, nil
+}
+
+// parseWallet проверяет номер кошелька ЮMoney: 11-20 цифр, начинается с 4100
+func parseWallet(input string) (Details, error) {
+	number := stripSeparators(input)
+	if len(number) < 11 || len(number) > 20 || !isDigits(number) || !strings.HasPrefix(number, "4100") {
+		return Details{}, fmt.Errorf("%w: номер кошелька начинается с 4100 и содержит 11-20 цифр", ErrInvalidWallet)
+	}
+	return Details{Kind: KindYooMoney, Value: number}, nil
+}
+
+// luhn проверяет контрольную цифру номера карты
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func stripSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '\u00a0':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func last4(s string) string {
+	if len(s) <= 4 {
+		return s
+	}
+	return s[len(s)-4:]
+}
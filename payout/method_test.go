@@ -0,0 +1,130 @@
+// payout/method_test.go
+package payout
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCard(t *testing.T) {
+	tests := []struct {
+		in     string
+		value  string
+		scheme string
+	}{
+		{"4111 1111 1111 1111", "4111111111111111", "Visa"},
+		{"4222222222222", "4222222222222", "Visa"},
+		{"5555-5555-5555-4444", "5555555555554444", "Mastercard"},
+		{"2221 0000 0000 0009", "2221000000000009", "Mastercard"},
+		{"2200 0000 0000 0004", "2200000000000004", "Мир"},
+		{"220012345678901231", "220012345678901231", "Мир"},
+		{"6200000000000005", "6200000000000005", "UnionPay"},
+		{"67596498264388", "67596498264388", "Maestro"},
+	}
+	for _, tt := range tests {
+		d, err := Parse(KindCard, tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if d.Kind != KindCard || d.Value != tt.value || d.Scheme != tt.scheme {
+			t.Errorf("Parse(%q) = %+v, ожидались %s %s", tt.in, d, tt.scheme, tt.value)
+		}
+	}
+}
+
+func TestParseCardInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"контрольная цифра", "4111 1111 1111 1112"},
+		{"переставлены цифры", "5555 5555 5554 5444"},
+		{"мало цифр", "41111111111"},
+		{"много цифр", "41111111111111111111"},
+		{"буквы", "4111 1111 1111 111a"},
+		{"неизвестная платёжная система", "3530 1113 3330 0000"},
+		{"длина не для Visa", "411111111111116"},
+		{"длина не для Mastercard", "55555555555544440"},
+		{"длина не для Мир", "220000000000009"},
+		{"пусто", ""},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(KindCard, tt.in); !errors.Is(err, ErrInvalidCard) {
+			t.Errorf("%s: Parse(%q) = %v, ожидалась ErrInvalidCard", tt.name, tt.in, err)
+		}
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	for number, want := range map[string]bool{
+		"4111111111111111": true,
+		"4111111111111112": false,
+		"79927398713":      true,
+		"79927398710":      false,
+		"0":                true,
+	} {
+		if got := luhn(number); got != want {
+			t.Errorf("luhn(%q) = %v, ожидалось %v", number, got, want)
+		}
+	}
+}
+
+func TestParsePhoneAndWallet(t *testing.T) {
+	for _, in := range []string{"+7 912 345-67-89", "8 (912) 345-67-89", "79123456789", "9123456789"} {
+		d, err := Parse(KindSBP, in)
+		if err != nil || d.Value != "+79123456789" {
+			t.Errorf("Parse(СБП, %q) = %+v, %v", in, d, err)
+		}
+	}
+	for _, in := range []string{"+7 495 123-45-67", "912345678", "+1 912 345 67 89"} {
+		if _, err := Parse(KindSBP, in); !errors.Is(err, ErrInvalidPhone) {
+			t.Errorf("Parse(СБП, %q) = %v, ожидалась ErrInvalidPhone", in, err)
+		}
+	}
+
+	if d, err := Parse(KindYooMoney, "4100 1234 5678 901"); err != nil || d.Value != "410012345678901" {
+		t.Errorf("Parse(ЮMoney) = %+v, %v", d, err)
+	}
+	for _, in := range []string{"4200123456789", "4100123", "41001234567890123456789"} {
+		if _, err := Parse(KindYooMoney, in); !errors.Is(err, ErrInvalidWallet) {
+			t.Errorf("Parse(ЮMoney, %q) = %v, ожидалась ErrInvalidWallet", in, err)
+		}
+	}
+
+	if _, err := Parse(Kind("paypal"), "x"); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("Parse(paypal) = %v, ожидалась ErrUnknownKind", err)
+	}
+}
+
+func TestMasking(t *testing.T) {
+	tests := []struct {
+		kind  Kind
+		in    string
+		label string
+		full  string
+	}{
+		{KindCard, "2200000000000004", "Мир •••• 0004", "Карта Мир: 2200 0000 0000 0004"},
+		{KindCard, "4222222222222", "Visa •••• 2222", "Карта Visa: 4222 2222 2222 2"},
+		{KindSBP, "+79123456789", "СБП •••• 6789", "СБП: +7 912 345-67-89"},
+		{KindYooMoney, "410012345678901", "ЮMoney •••• 8901", "ЮMoney: 410012345678901"},
+	}
+	for _, tt := range tests {
+		d, err := Parse(tt.kind, tt.in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.in, err)
+		}
+		label := d.Label()
+		if label != tt.label {
+			t.Errorf("Label(%q) = %q, ожидалось %q", tt.in, label, tt.label)
+		}
+		// В маскированном виде не должно быть ничего, кроме последних 4 цифр
+		if strings.Contains(label, d.Value[:len(d.Value)-4]) {
+			t.Errorf("Label(%q) = %q раскрывает номер", tt.in, label)
+		}
+		if full := d.Full(); full != tt.full {
+			t.Errorf("Full(%q) = %q, ожидалось %q", tt.in, full, tt.full)
+		}
+	}
+}
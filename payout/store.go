@@ -0,0 +1,160 @@
+// payout/store.go
+package payout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"telegram_bot/database"
+	"telegram_bot/models"
+)
+
+// ErrNotFound - реквизиты не найдены или удалены
+var ErrNotFound = errors.New("реквизиты не найдены")
+
+// Store хранит реквизиты пользователей в таблице payout_methods
+type Store struct {
+	db    database.DBInterface
+	vault *Vault
+}
+
+// NewStore создаёт хранилище реквизитов
+func NewStore(db database.DBInterface, vault *Vault) *Store {
+	return &Store{db: db, vault: vault}
+}
+
+// Save сохраняет реквизиты пользователя. Если такие уже сохранены,
+// возвращает существующую запись.
+func (s *Store) Save(ctx context.Context, userID int, d Details) (*models.PayoutMethod, error) {
+	fingerprint := s.vault.Fingerprint(string(d.Kind) + ":" + d.Value)
+
+	existing, err := s.scanOne(ctx, `
+        SELECT id, user_id, kind, label, created_at, deleted_at FROM payout_methods
+        WHERE user_id = $1 AND fingerprint = $2 AND deleted_at IS NULL
+    `, userID, fingerprint)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	sealed, err := s.vault.Seal([]byte(d.Value), additionalData(userID))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось зашифровать реквизиты: %w", err)
+	}
+
+	m := &models.PayoutMethod{UserID: userID, Kind: string(d.Kind), Label: d.Label()}
+	err = s.db.QueryRowContext(ctx, `
+        INSERT INTO payout_methods (user_id, kind, label, details, fingerprint)
+        VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at
+    `, userID, m.Kind, m.Label, sealed, fingerprint).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при сохранении реквизитов: %w", err)
+	}
+	return m, nil
+}
+
+// List возвращает сохранённые реквизиты пользователя
+func (s *Store) List(ctx context.Context, userID int) ([]*models.PayoutMethod, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, user_id, kind, label, created_at, deleted_at FROM payout_methods
+        WHERE user_id = $1 AND deleted_at IS NULL
+        ORDER BY created_at
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*models.PayoutMethod
+	for rows.Next() {
+		m := &models.PayoutMethod{}
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Kind, &m.Label, &m.CreatedAt, &m.DeletedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// Get возвращает реквизиты по ID, включая удалённые: на них могут
+// ссылаться старые заявки
+func (s *Store) Get(ctx context.Context, id int64) (*models.PayoutMethod, error) {
+	return s.scanOne(ctx, `
+        SELECT id, user_id, kind, label, created_at, deleted_at FROM payout_methods WHERE id = $1
+    `, id)
+}
+
+// GetActive возвращает реквизиты, которые пользователь ещё не удалил.
+// Для удалённых реквизитов возвращает ErrNotFound.
+func (s *Store) GetActive(ctx context.Context, id int64) (*models.PayoutMethod, error) {
+	m, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if m.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return m, nil
+}
+
+// Reveal расшифровывает реквизиты. Только для исполнителя выплат.
+func (s *Store) Reveal(ctx context.Context, id int64) (Details, error) {
+	var userID int
+	var kind string
+	var sealed []byte
+	err := s.db.QueryRowContext(ctx,
+		"SELECT user_id, kind, details FROM payout_methods WHERE id = $1", id).Scan(&userID, &kind, &sealed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Details{}, ErrNotFound
+	}
+	if err != nil {
+		return Details{}, err
+	}
+
+	value, err := s.vault.Open(sealed, additionalData(userID))
+	if err != nil {
+		return Details{}, err
+	}
+	// Повторный разбор восстанавливает платёжную систему карты
+	return Parse(Kind(kind), string(value))
+}
+
+// Delete скрывает реквизиты пользователя из списка
+func (s *Store) Delete(ctx context.Context, userID int, id int64) error {
+	result, err := s.db.ExecContext(ctx, `
+        UPDATE payout_methods SET deleted_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+    `, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) scanOne(ctx context.Context, query string, args ...interface{}) (*models.PayoutMethod, error) {
+	m := &models.PayoutMethod{}
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&m.ID, &m.UserID, &m.Kind, &m.Label, &m.CreatedAt, &m.DeletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// additionalData привязывает шифртекст к владельцу реквизитов
+func additionalData(userID int) []byte {
+	return []byte(fmt.Sprintf("payout_method:%d", userID))
+}
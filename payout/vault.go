@@ -0,0 +1,72 @@
+// payout/vault.go
+package payout
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// KeySize - длина ключа AES-256
+const KeySize = 32
+
+// ErrDecrypt - шифртекст повреждён или зашифрован другим ключом
+var ErrDecrypt = errors.New("не удалось расшифровать реквизиты")
+
+// Vault шифрует реквизиты AES-GCM. Каждое значение получает случайный
+// nonce, который хранится перед шифртекстом.
+type Vault struct {
+	aead cipher.AEAD
+	key  []byte
+}
+
+// NewVault создаёт хранилище с 32-байтным ключом
+func NewVault(key []byte) (*Vault, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("ключ шифрования реквизитов должен быть длиной %d байт, получено %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{aead: aead, key: append([]byte(nil), key...)}, nil
+}
+
+// Seal шифрует значение. additional привязывает шифртекст к владельцу:
+// реквизиты, скопированные в чужую запись, не расшифруются.
+func (v *Vault) Seal(plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return v.aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// Open расшифровывает значение, зашифрованное Seal
+func (v *Vault) Open(sealed, additional []byte) ([]byte, error) {
+	n := v.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := v.aead.Open(nil, sealed[:n], sealed[n:], additional)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// Fingerprint - HMAC реквизитов для поиска дубликатов без расшифровки
+func (v *Vault) Fingerprint(value string) string {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte("fingerprint:" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
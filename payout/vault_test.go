@@ -0,0 +1,95 @@
+// payout/vault_test.go
+package payout
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testVault(t *testing.T, fill byte) *Vault {
+	t.Helper()
+	v, err := NewVault(bytes.Repeat([]byte{fill}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestNewVaultKeySize(t *testing.T) {
+	for _, n := range []int{0, 16, 31, 33} {
+		if _, err := NewVault(make([]byte, n)); err == nil {
+			t.Errorf("NewVault с ключом %d байт должен вернуть ошибку", n)
+		}
+	}
+}
+
+func TestVaultSealOpen(t *testing.T) {
+	v := testVault(t, 1)
+	plaintext := []byte("4111111111111111")
+	owner := additionalData(42)
+
+	sealed, err := v.Seal(plaintext, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("шифртекст содержит открытые реквизиты")
+	}
+	again, err := v.Seal(plaintext, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sealed, again) {
+		t.Error("повторное шифрование дало тот же шифртекст: nonce не случайный")
+	}
+
+	opened, err := v.Open(sealed, owner)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("Open = %q, %v", opened, err)
+	}
+}
+
+func TestVaultOpenFails(t *testing.T) {
+	v := testVault(t, 1)
+	owner := additionalData(42)
+	sealed, err := v.Seal([]byte("+79123456789"), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name       string
+		vault      *Vault
+		sealed     []byte
+		additional []byte
+	}{
+		{"чужой владелец", v, sealed, additionalData(43)},
+		{"без владельца", v, sealed, nil},
+		{"другой ключ", testVault(t, 2), sealed, owner},
+		{"изменённый шифртекст", v, tampered, owner},
+		{"обрезанный шифртекст", v, sealed[:5], owner},
+		{"пусто", v, nil, owner},
+	}
+	for _, tt := range tests {
+		if _, err := tt.vault.Open(tt.sealed, tt.additional); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: Open = %v, ожидалась ErrDecrypt", tt.name, err)
+		}
+	}
+}
+
+func TestVaultFingerprint(t *testing.T) {
+	v := testVault(t, 1)
+	if v.Fingerprint("4111111111111111") != v.Fingerprint("4111111111111111") {
+		t.Error("отпечаток одинаковых реквизитов различается")
+	}
+	if v.Fingerprint("4111111111111111") == v.Fingerprint("5555555555554444") {
+		t.Error("отпечатки разных реквизитов совпали")
+	}
+	if v.Fingerprint("4111111111111111") == testVault(t, 2).Fingerprint("4111111111111111") {
+		t.Error("отпечаток не зависит от ключа")
+	}
+}
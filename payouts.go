@@ -0,0 +1,89 @@
+// payouts.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"telegram_bot/config"
+	"telegram_bot/database"
+	"telegram_bot/ledger"
+	"telegram_bot/payout"
+	"telegram_bot/withdrawal"
+)
+
+// runPayouts выполняет подкоманду:
+//
+//	payouts export [-full] [-format csv|registry] [-o файл]
+//	payouts import файл.csv
+func runPayouts(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "использование: payouts export [-full] [-format csv|registry] [-o файл] | import файл.csv")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	vault, err := payout.NewVault(cfg.PayoutVaultKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := database.InitDB()
+	defer database.CloseDB()
+	checkSchema(db)
+
+	service := withdrawal.New(db, ledger.New(db), withdrawal.Limits{Min: cfg.Withdrawal.Min, Max: cfg.Withdrawal.Max})
+	ctx := context.Background()
+
+	switch args[0] {
+	case "export":
+		fs := flag.NewFlagSet("payouts export", flag.ExitOnError)
+		full := fs.Bool("full", false, "выгрузить полные реквизиты вместо маски")
+		format := fs.String("format", withdrawal.FormatCSV, "формат: csv или registry")
+		out := fs.String("o", "", "файл для выгрузки (по умолчанию stdout)")
+		fs.Parse(args[1:])
+
+		batch, err := service.Approved(ctx, payout.NewStore(db, vault), *full)
+		if err != nil {
+			log.Fatalf("Ошибка выгрузки заявок: %v", err)
+		}
+		data, err := batch.Encode(*format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *out == "" {
+			os.Stdout.Write(data)
+		} else if err := os.WriteFile(*out, data, 0o600); err != nil {
+			log.Fatalf("Не удалось записать %s: %v", *out, err)
+		}
+		fmt.Fprintf(os.Stderr, "Заявок: %d, сумма: %s\n", len(batch.Rows), batch.Total)
+
+	case "import":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "использование: payouts import файл.csv")
+			os.Exit(2)
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		// Действия из командной строки записываются без администратора
+		report, err := service.ImportResults(ctx, f, 0)
+		if err != nil {
+			log.Fatalf("Ошибка загрузки результатов: %v", err)
+		}
+		fmt.Print(report.String())
+
+	default:
+		fmt.Fprintf(os.Stderr, "неизвестная команда payouts %q\n", args[0])
+		os.Exit(2)
+	}
+}
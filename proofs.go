@@ -0,0 +1,85 @@
+// proofs.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"telegram_bot/config"
+	"telegram_bot/database"
+	"telegram_bot/imagehash"
+	"telegram_bot/tgfile"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// proofsBatch - сколько скриншотов читать из базы за раз
+const proofsBatch = 100
+
+// runProofs выполняет подкоманду:
+//
+//	proofs phash [-limit N]
+//
+// Вычисляет перцептивные хэши скриншотов, присланных до их появления.
+func runProofs(args []string) {
+	if len(args) == 0 || args[0] != "phash" {
+		fmt.Fprintln(os.Stderr, "использование: proofs phash [-limit N]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("proofs phash", flag.ExitOnError)
+	limit := fs.Int("limit", 0, "сколько скриншотов обработать, 0 - все")
+	fs.Parse(args[1:])
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	bot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := database.InitDB()
+	defer database.CloseDB()
+	checkSchema(db)
+
+	ctx := context.Background()
+	var afterID int64
+	var processed, saved int
+	for *limit == 0 || processed < *limit {
+		proofs, err := db.ListUnhashedProofs(ctx, afterID, proofsBatch)
+		if err != nil {
+			log.Fatalf("Ошибка при получении скриншотов: %v", err)
+		}
+		if len(proofs) == 0 {
+			break
+		}
+		for _, p := range proofs {
+			if *limit > 0 && processed == *limit {
+				break
+			}
+			afterID = p.ID
+			processed++
+
+			// Ошибочные скриншоты пропускаются, чтобы не остановить весь проход
+			fileURL, err := tgfile.DirectURL(bot, p.FileID)
+			if err != nil {
+				log.Printf("Скриншот %d: %v", p.ID, err)
+				continue
+			}
+			hash, err := imagehash.Fetch(ctx, fileURL)
+			if err != nil {
+				log.Printf("Скриншот %d: %v", p.ID, err)
+				continue
+			}
+			if err := db.SetProofHash(ctx, p.ID, hash); err != nil {
+				log.Fatalf("Ошибка при сохранении хэша скриншота %d: %v", p.ID, err)
+			}
+			saved++
+		}
+	}
+	fmt.Printf("Обработано скриншотов: %d, хэшей сохранено: %d, пропущено: %d\n", processed, saved, processed-saved)
+}
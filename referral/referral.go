@@ -0,0 +1,306 @@
+// referral/referral.go
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram_bot/database"
+	"telegram_bot/ledger"
+	"telegram_bot/money"
+)
+
+// Виды реферальных начислений
+const (
+	KindBonus   = "bonus"   // разовый бонус за первое одобренное задание
+	KindPercent = "percent" // процент от вознаграждений реферала
+)
+
+// payloadPrefix - префикс параметра /start в реферальной ссылке
+const payloadPrefix = "ref"
+
+// maxChainDepth ограничивает обход цепочки рефереров при проверке циклов
+const maxChainDepth = 100
+
+var (
+	// ErrSelfReferral - пользователь перешёл по собственной ссылке
+	ErrSelfReferral = errors.New("нельзя пригласить самого себя")
+	// ErrUnknownReferrer - владелец ссылки не зарегистрирован
+	ErrUnknownReferrer = errors.New("пригласивший пользователь не найден")
+	// ErrAlreadyReferred - у пользователя уже есть пригласивший
+	ErrAlreadyReferred = errors.New("пользователь уже привязан к рефереру")
+	// ErrCycle - привязка замкнула бы цепочку приглашений
+	ErrCycle = errors.New("привязка создаёт цикл приглашений")
+)
+
+// Config - условия реферальной программы. Проценты по уровням
+// хранятся в таблице referral_tiers и меняются без перезапуска.
+// PercentBP действует для уровня 1, пока таблица пуста, чтобы процент
+// из REFERRAL_PERCENT не пропал после обновления.
+type Config struct {
+	Bonus       money.Amount // разовый бонус, 0 - отключён
+	PercentBP   int64        // процент уровня 1 по умолчанию, 0 - отключён
+	PercentDays int          // сколько дней после привязки начисляется процент
+	MaxLevels   int          // сколько уровней цепочки получают процент
+}
+
+// Earning - начисление рефереру
+type Earning struct {
+	ReferrerID int
+	RefereeID  int
+	Kind       string
+	Level      int
+	Amount     money.Amount
+}
+
+// Program начисляет реферальные вознаграждения через журнал
+type Program struct {
+	db     database.DBInterface
+	ledger *ledger.Ledger
+	cfg    Config
+}
+
+// New создаёт реферальную программу
+func New(db database.DBInterface, l *ledger.Ledger, cfg Config) *Program {
+	return &Program{db: db, ledger: l, cfg: cfg}
+}
+
+// Config возвращает условия программы
+func (p *Program) Config() Config {
+	return p.cfg
+}
+
+// Link возвращает реферальную ссылку пользователя
+func Link(botUserName string, telegramID int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", botUserName, payloadPrefix, telegramID)
+}
+
+// ParsePayload извлекает Telegram ID пригласившего из параметра /start.
+// Поддерживаются ссылки вида ?start=ref123 и старые ?start=123.
+func ParsePayload(payload string) (int64, bool) {
+	payload = strings.TrimPrefix(strings.TrimSpace(payload), payloadPrefix)
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// Attribute привязывает пользователя к пригласившему. Привязка возможна
+// один раз; ссылка на себя и замыкание цепочки приглашений отклоняются.
+// Возвращает внутренний ID пригласившего.
+func (p *Program) Attribute(ctx context.Context, refereeID int, referrerTelegramID int64) (int, error) {
+	var referrerID int
+	err := p.db.RunInTx(ctx, func(tx database.DBInterface) error {
+		err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE telegram_id = $1", referrerTelegramID).Scan(&referrerID)
+		if err != nil {
+			return ErrUnknownReferrer
+		}
+		if referrerID == refereeID {
+			return ErrSelfReferral
+		}
+
+		var cycle bool
+		err = tx.QueryRowContext(ctx, `
+            WITH RECURSIVE chain AS (
+                SELECT id, referrer_id, 1 AS depth FROM users WHERE id = $1
+                UNION ALL
+                SELECT u.id, u.referrer_id, c.depth + 1
+                FROM users u JOIN chain c ON u.id = c.referrer_id
+                WHERE c.depth < $3
+            )
+            SELECT EXISTS (SELECT 1 FROM chain WHERE id = $2)
+        `, referrerID, refereeID, maxChainDepth).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrCycle
+		}
+
+		result, err := tx.ExecContext(ctx, `
+            UPDATE users SET referrer_id = $1, referred_at = NOW(), updated_at = NOW()
+            WHERE id = $2 AND referrer_id IS NULL
+        `, referrerID, refereeID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrAlreadyReferred
+		}
+		return nil
+	})
+	return referrerID, err
+}
+
+// OnTaskApproved начисляет рефереру вознаграждения за одобренное задание
+// реферала. Вызывается в транзакции одобрения, поэтому начисления
+// фиксируются или откатываются вместе с ним.
+func (p *Program) OnTaskApproved(ctx context.Context, tx database.DBInterface, refereeID int, userTaskID int, reward money.Amount) ([]Earning, error) {
+	var referrerID *int
+	var referredAt *time.Time
+	err := tx.QueryRowContext(ctx,
+		"SELECT referrer_id, referred_at FROM users WHERE id = $1", refereeID).Scan(&referrerID, &referredAt)
+	if err != nil {
+		return nil, err
+	}
+	if referrerID == nil {
+		return nil, nil
+	}
+
+	var earnings []Earning
+
+	// Разовый бонус: reference не даёт начислить его второй раз
+	if p.cfg.Bonus.IsPositive() {
+		e := Earning{ReferrerID: *referrerID, RefereeID: refereeID, Kind: KindBonus, Level: 1, Amount: p.cfg.Bonus}
+		ok, err := p.post(ctx, tx, e, nil, fmt.Sprintf("referral:%d:bonus", refereeID),
+			"Бонус за приглашённого пользователя")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			earnings = append(earnings, e)
+		}
+	}
+
+	// Процент по уровням цепочки в течение PercentDays после привязки
+	if referredAt == nil || time.Since(*referredAt) >= time.Duration(p.cfg.PercentDays)*24*time.Hour {
+		return earnings, nil
+	}
+	tiers, err := p.loadTiers(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(tiers) == 0 {
+		return earnings, nil
+	}
+	referrers, err := chain(ctx, tx, refereeID, tiers[len(tiers)-1].Level)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tiers {
+		if t.Level > len(referrers) {
+			break
+		}
+		if t.PercentBP == 0 {
+			continue
+		}
+		referrer := referrers[t.Level-1]
+		if referrer == refereeID {
+			continue
+		}
+		amount, err := capped(ctx, tx, referrer, t, reward.Percent(t.PercentBP))
+		if err != nil {
+			return nil, err
+		}
+		if !amount.IsPositive() {
+			continue
+		}
+
+		e := Earning{ReferrerID: referrer, RefereeID: refereeID, Kind: KindPercent, Level: t.Level, Amount: amount}
+		ok, err := p.post(ctx, tx, e, &userTaskID, percentReference(userTaskID, t.Level),
+			fmt.Sprintf("Процент с задания реферала %d-го уровня #%d", t.Level, userTaskID))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			earnings = append(earnings, e)
+		}
+	}
+	return earnings, nil
+}
+
+// OnTaskReverted отменяет процентные начисления за выполнение, одобрение
+// которого отменено: списывает их с рефереров и записывает в
+// referral_earnings с отрицательной суммой, чтобы не искажать лимиты.
+// Разовый бонус не отменяется: он начислен за приглашение, а не за задание.
+// Если реферер уже вывел средства, возвращается ledger.ErrInsufficientFunds.
+func (p *Program) OnTaskReverted(ctx context.Context, tx database.DBInterface, userTaskID int) ([]Earning, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT referrer_id, referee_id, level, amount FROM referral_earnings
+        WHERE user_task_id = $1 AND kind = $2 AND amount > 0
+        ORDER BY level
+    `, userTaskID, KindPercent)
+	if err != nil {
+		return nil, err
+	}
+	var earnings []Earning
+	for rows.Next() {
+		e := Earning{Kind: KindPercent}
+		if err := rows.Scan(&e.ReferrerID, &e.RefereeID, &e.Level, &e.Amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		earnings = append(earnings, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var reverted []Earning
+	for _, e := range earnings {
+		entryID, err := p.ledger.Post(ctx, tx, ledger.Transfer(
+			ledger.KindReferralReversal,
+			fmt.Sprintf("Отмена процента с задания реферала %d-го уровня #%d", e.Level, userTaskID),
+			percentReference(userTaskID, e.Level)+":reversal",
+			ledger.UserWallet(e.ReferrerID), ledger.ReferralExpense, e.Amount,
+		))
+		if errors.Is(err, ledger.ErrDuplicate) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO referral_earnings (referrer_id, referee_id, user_task_id, kind, level, amount, entry_id)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
+        `, e.ReferrerID, e.RefereeID, userTaskID, e.Kind, e.Level, e.Amount.Neg(), entryID)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при записи отмены реферального начисления: %w", err)
+		}
+		reverted = append(reverted, e)
+	}
+	return reverted, nil
+}
+
+// percentReference - reference начисления по уровню. Для первого уровня
+// сохранён прежний формат, чтобы повторное одобрение не начислило дважды.
+func percentReference(userTaskID, level int) string {
+	if level == 1 {
+		return fmt.Sprintf("referral:user_task:%d", userTaskID)
+	}
+	return fmt.Sprintf("referral:user_task:%d:level:%d", userTaskID, level)
+}
+
+// post записывает начисление в журнал и в referral_earnings.
+// Возвращает false, если начисление с таким reference уже было.
+func (p *Program) post(ctx context.Context, tx database.DBInterface, e Earning, userTaskID *int, reference, description string) (bool, error) {
+	entryID, err := p.ledger.Post(ctx, tx, ledger.Transfer(
+		ledger.KindReferralBonus, description, reference,
+		ledger.ReferralExpense, ledger.UserWallet(e.ReferrerID), e.Amount,
+	))
+	if errors.Is(err, ledger.ErrDuplicate) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO referral_earnings (referrer_id, referee_id, user_task_id, kind, level, amount, entry_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, e.ReferrerID, e.RefereeID, userTaskID, e.Kind, e.Level, e.Amount, entryID)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при записи реферального начисления: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,67 @@
+// referral/report.go
+package referral
+
+import (
+	"context"
+	"time"
+
+	"telegram_bot/models"
+	"telegram_bot/money"
+)
+
+// Referee - приглашённый пользователь в отчёте реферера
+type Referee struct {
+	UserID        int
+	TelegramID    int64
+	Username      string
+	JoinedAt      time.Time
+	ApprovedTasks int
+	Earned        money.Amount // начислено рефереру за этого пользователя
+}
+
+// Report - отчёт «Мои рефералы»
+type Report struct {
+	Referees []Referee
+	Indirect money.Amount // начислено за рефералов второго и следующих уровней
+	Total    money.Amount
+}
+
+// Report собирает приглашённых пользователя и начисления за них
+func (p *Program) Report(ctx context.Context, referrerID int) (*Report, error) {
+	rows, err := p.db.QueryContext(ctx, `
+        SELECT u.id, u.telegram_id, COALESCE(u.username, ''), COALESCE(u.referred_at, u.created_at),
+               (SELECT COUNT(*) FROM user_tasks ut WHERE ut.user_id = u.id AND ut.status = $2),
+               (SELECT COALESCE(SUM(e.amount), 0) FROM referral_earnings e
+                WHERE e.referrer_id = $1 AND e.referee_id = u.id AND e.level = 1)
+        FROM users u
+        WHERE u.referrer_id = $1
+        ORDER BY COALESCE(u.referred_at, u.created_at) DESC
+    `, referrerID, models.UserTaskApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &Report{}
+	for rows.Next() {
+		var r Referee
+		if err := rows.Scan(&r.UserID, &r.TelegramID, &r.Username, &r.JoinedAt, &r.ApprovedTasks, &r.Earned); err != nil {
+			return nil, err
+		}
+		report.Referees = append(report.Referees, r)
+		report.Total = report.Total.Add(r.Earned)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = p.db.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(amount), 0) FROM referral_earnings
+        WHERE referrer_id = $1 AND level > 1
+    `, referrerID).Scan(&report.Indirect)
+	if err != nil {
+		return nil, err
+	}
+	report.Total = report.Total.Add(report.Indirect)
+	return report, nil
+}
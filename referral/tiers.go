@@ -0,0 +1,163 @@
+// referral/tiers.go
+package referral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"telegram_bot/database"
+	"telegram_bot/money"
+)
+
+// ErrInvalidTier - некорректные параметры уровня
+var ErrInvalidTier = errors.New("некорректные параметры уровня")
+
+// Tier - процент и месячный лимит начислений для уровня цепочки.
+// Уровень 1 - пригласивший, 2 - пригласивший пригласившего и т.д.
+type Tier struct {
+	Level      int
+	PercentBP  int64        // процент в сотых долях процента, 0 - уровень отключён
+	MonthlyCap money.Amount // лимит начислений одному рефереру за месяц, 0 - без лимита
+}
+
+// Tiers возвращает настроенные уровни по возрастанию, включая отключённые.
+// Пока ни один уровень не сохранён, действует уровень 1 с процентом из Config.
+func (p *Program) Tiers(ctx context.Context) ([]Tier, error) {
+	return p.loadTiers(ctx, p.db)
+}
+
+func (p *Program) loadTiers(ctx context.Context, q database.DBInterface) ([]Tier, error) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT level, percent_bp, monthly_cap FROM referral_tiers
+        WHERE level <= $1
+        ORDER BY level
+    `, p.cfg.MaxLevels)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tiers []Tier
+	for rows.Next() {
+		var t Tier
+		if err := rows.Scan(&t.Level, &t.PercentBP, &t.MonthlyCap); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(tiers) == 0 && p.cfg.PercentBP > 0 {
+		tiers = []Tier{{Level: 1, PercentBP: p.cfg.PercentBP}}
+	}
+	return tiers, nil
+}
+
+// SetTier создаёт или изменяет уровень. Нулевой процент отключает уровень:
+// строка остаётся, чтобы не включился процент по умолчанию из Config.
+func (p *Program) SetTier(ctx context.Context, t Tier) error {
+	if t.Level < 1 || t.Level > p.cfg.MaxLevels || t.PercentBP < 0 || t.PercentBP > 10000 || t.MonthlyCap.IsNegative() {
+		return ErrInvalidTier
+	}
+	_, err := p.db.ExecContext(ctx, `
+        INSERT INTO referral_tiers (level, percent_bp, monthly_cap)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (level) DO UPDATE
+        SET percent_bp = EXCLUDED.percent_bp, monthly_cap = EXCLUDED.monthly_cap, updated_at = NOW()
+    `, t.Level, t.PercentBP, t.MonthlyCap)
+	return err
+}
+
+// ParseTier разбирает строку «уровень процент [лимит]», например «2 3 500»
+// или «1 10,5». Нулевой процент означает отключение уровня.
+func ParseTier(input string) (Tier, error) {
+	fields := strings.Fields(input)
+	if len(fields) < 2 || len(fields) > 3 {
+		return Tier{}, ErrInvalidTier
+	}
+
+	level, err := strconv.Atoi(fields[0])
+	if err != nil || level < 1 {
+		return Tier{}, ErrInvalidTier
+	}
+	// Процент с точностью до сотых разбирается как сумма: «10,5» -> 1050 б.п.
+	percent, err := money.Parse(strings.TrimSuffix(fields[1], "%"))
+	if err != nil || percent.IsNegative() || percent.Cmp(money.Rubles(100)) > 0 {
+		return Tier{}, ErrInvalidTier
+	}
+
+	t := Tier{Level: level, PercentBP: int64(percent)}
+	if len(fields) == 3 {
+		t.MonthlyCap, err = money.Parse(fields[2])
+		if err != nil || t.MonthlyCap.IsNegative() {
+			return Tier{}, ErrInvalidTier
+		}
+	}
+	return t, nil
+}
+
+// chain возвращает рефереров пользователя снизу вверх, не глубже depth уровней
+func chain(ctx context.Context, tx database.DBInterface, userID, depth int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+        WITH RECURSIVE chain AS (
+            SELECT referrer_id AS id, 1 AS level FROM users
+            WHERE id = $1 AND referrer_id IS NOT NULL
+            UNION ALL
+            SELECT u.referrer_id, c.level + 1
+            FROM users u JOIN chain c ON u.id = c.id
+            WHERE u.referrer_id IS NOT NULL AND c.level < $2
+        )
+        SELECT id FROM chain ORDER BY level
+    `, userID, depth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// capped урезает начисление до остатка месячного лимита уровня.
+// Строка реферера блокируется, чтобы параллельные одобрения не превысили лимит.
+func capped(ctx context.Context, tx database.DBInterface, referrerID int, t Tier, amount money.Amount) (money.Amount, error) {
+	if t.MonthlyCap.IsZero() {
+		return amount, nil
+	}
+
+	var id int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", referrerID).Scan(&id); err != nil {
+		return money.Zero, err
+	}
+
+	var earned money.Amount
+	err := tx.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(amount), 0) FROM referral_earnings
+        WHERE referrer_id = $1 AND level = $2 AND kind = $3
+          AND created_at >= date_trunc('month', NOW())
+    `, referrerID, t.Level, KindPercent).Scan(&earned)
+	if err != nil {
+		return money.Zero, fmt.Errorf("ошибка при подсчёте начислений за месяц: %w", err)
+	}
+
+	left := t.MonthlyCap.Sub(earned)
+	if left.Cmp(amount) < 0 {
+		amount = left
+	}
+	if amount.IsNegative() {
+		return money.Zero, nil
+	}
+	return amount, nil
+}
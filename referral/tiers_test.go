@@ -0,0 +1,51 @@
+// referral/tiers_test.go
+package referral
+
+import (
+	"errors"
+	"testing"
+
+	"telegram_bot/money"
+)
+
+func TestParseTier(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Tier
+	}{
+		{"1 10", Tier{Level: 1, PercentBP: 1000}},
+		{"1 10,5", Tier{Level: 1, PercentBP: 1050}},
+		{"2 3.25%", Tier{Level: 2, PercentBP: 325}},
+		{"2 3 500", Tier{Level: 2, PercentBP: 300, MonthlyCap: money.Rubles(500)}},
+		{"3 0,01 99,90", Tier{Level: 3, PercentBP: 1, MonthlyCap: money.Kopecks(9990)}},
+		{"1 100 0", Tier{Level: 1, PercentBP: 10000}},
+		{"  4   0  ", Tier{Level: 4}},
+	}
+	for _, tt := range tests {
+		got, err := ParseTier(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTier(%q) = %+v, %v; ожидалось %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseTierInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"1",
+		"1 10 500 7",
+		"0 10",
+		"-1 10",
+		"x 10",
+		"1 abc",
+		"1 -5",
+		"1 100,01",
+		"1 10,555",
+		"1 10 -1",
+		"1 10 много",
+	} {
+		if _, err := ParseTier(in); !errors.Is(err, ErrInvalidTier) {
+			t.Errorf("ParseTier(%q) = %v, ожидалась ErrInvalidTier", in, err)
+		}
+	}
+}
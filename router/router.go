@@ -0,0 +1,256 @@
+// router/router.go
+package router
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"telegram_bot/handlers"
+	"telegram_bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Context - данные, доступные обработчику маршрута
+type Context struct {
+	Update     tgbotapi.Update
+	User       *models.User // nil, если пользователь ещё не зарегистрирован
+	TelegramID int64
+	ChatID     int64
+	State      models.State
+	Handler    *handlers.Handler
+	Matches    []string // группы совпадения для Regex-маршрутов
+}
+
+// IsAdmin сообщает, является ли отправитель администратором
+func (c *Context) IsAdmin() bool {
+	return c.User != nil && c.User.Admin
+}
+
+// Text возвращает текст сообщения или пустую строку
+func (c *Context) Text() string {
+	if c.Update.Message == nil {
+		return ""
+	}
+	return c.Update.Message.Text
+}
+
+// HandlerFunc - обработчик маршрута
+type HandlerFunc func(ctx context.Context, c *Context)
+
+// Middleware вызывается до поиска маршрута. Если возвращает true,
+// обновление считается обработанным.
+type Middleware func(ctx context.Context, c *Context) bool
+
+// Route - зарегистрированный маршрут
+type Route struct {
+	match   func(c *Context) bool
+	filters []func(c *Context) bool
+	handle  HandlerFunc
+}
+
+// Admin ограничивает маршрут администраторами
+func (r *Route) Admin() *Route {
+	r.filters = append(r.filters, (*Context).IsAdmin)
+	return r
+}
+
+// Users ограничивает маршрут обычными пользователями
+func (r *Route) Users() *Route {
+	r.filters = append(r.filters, func(c *Context) bool { return !c.IsAdmin() })
+	return r
+}
+
+// When добавляет произвольное условие срабатывания маршрута
+func (r *Route) When(pred func(c *Context) bool) *Route {
+	r.filters = append(r.filters, pred)
+	return r
+}
+
+func (r *Route) matches(c *Context) bool {
+	if r.match != nil && !r.match(c) {
+		return false
+	}
+	for _, f := range r.filters {
+		if !f(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Router сопоставляет входящие обновления с зарегистрированными маршрутами.
+// Порядок проверки: состояния, команды, callback-запросы, фото, тексты
+// (в порядке регистрации), затем резервные обработчики.
+type Router struct {
+	handler   *handlers.Handler
+	before    []Middleware
+	states    []*Route
+	commands  []*Route
+	callbacks []*Route
+	photos    []*Route
+	texts     []*Route
+	fallbacks []*Route
+}
+
+// New создаёт маршрутизатор для указанного Handler
+func New(h *handlers.Handler) *Router {
+	return &Router{handler: h}
+}
+
+// Before добавляет промежуточный обработчик, вызываемый до маршрутов
+func (r *Router) Before(mw Middleware) {
+	r.before = append(r.before, mw)
+}
+
+// Command регистрирует обработчик команды (без "/")
+func (r *Router) Command(name string, fn HandlerFunc) *Route {
+	route := &Route{
+		match: func(c *Context) bool {
+			m := c.Update.Message
+			return m != nil && m.IsCommand() && m.Command() == name
+		},
+		handle: fn,
+	}
+	r.commands = append(r.commands, route)
+	return route
+}
+
+// Text регистрирует обработчик точного текста сообщения
+func (r *Router) Text(text string, fn HandlerFunc) *Route {
+	route := &Route{
+		match: func(c *Context) bool {
+			m := c.Update.Message
+			return m != nil && !m.IsCommand() && m.Text == text
+		},
+		handle: fn,
+	}
+	r.texts = append(r.texts, route)
+	return route
+}
+
+// Regex регистрирует обработчик текста по регулярному выражению.
+// Группы совпадения доступны в Context.Matches.
+func (r *Router) Regex(re *regexp.Regexp, fn HandlerFunc) *Route {
+	route := &Route{
+		match: func(c *Context) bool {
+			m := c.Update.Message
+			if m == nil || m.IsCommand() {
+				return false
+			}
+			c.Matches = re.FindStringSubmatch(m.Text)
+			return c.Matches != nil
+		},
+		handle: fn,
+	}
+	r.texts = append(r.texts, route)
+	return route
+}
+
+// Callback регистрирует обработчик callback-запросов по префиксу данных
+func (r *Router) Callback(prefix string, fn HandlerFunc) *Route {
+	route := &Route{
+		match: func(c *Context) bool {
+			cq := c.Update.CallbackQuery
+			return cq != nil && strings.HasPrefix(cq.Data, prefix)
+		},
+		handle: fn,
+	}
+	r.callbacks = append(r.callbacks, route)
+	return route
+}
+
+// Photo регистрирует обработчик сообщений с фотографией
+func (r *Router) Photo(fn HandlerFunc) *Route {
+	route := &Route{
+		match: func(c *Context) bool {
+			m := c.Update.Message
+			return m != nil && len(m.Photo) > 0
+		},
+		handle: fn,
+	}
+	r.photos = append(r.photos, route)
+	return route
+}
+
+// State регистрирует обработчик сообщений пользователя в указанном состоянии
+func (r *Router) State(state models.State, fn HandlerFunc) *Route {
+	route := &Route{
+		match: func(c *Context) bool {
+			return c.Update.Message != nil && c.State == state
+		},
+		handle: fn,
+	}
+	r.states = append(r.states, route)
+	return route
+}
+
+// Fallback регистрирует резервный обработчик. Резервные обработчики
+// проверяются в порядке регистрации, срабатывает первый подходящий.
+func (r *Router) Fallback(fn HandlerFunc) *Route {
+	route := &Route{handle: fn}
+	r.fallbacks = append(r.fallbacks, route)
+	return route
+}
+
+// Dispatch находит маршрут для обновления и вызывает его обработчик.
+// Возвращает false, если ни один маршрут не подошёл.
+func (r *Router) Dispatch(ctx context.Context, update tgbotapi.Update) bool {
+	c, ok := r.newContext(ctx, update)
+	if !ok {
+		return false
+	}
+
+	for _, mw := range r.before {
+		if mw(ctx, c) {
+			return true
+		}
+	}
+
+	groups := [][]*Route{r.states, r.commands, r.callbacks, r.photos, r.texts, r.fallbacks}
+	for _, group := range groups {
+		for _, route := range group {
+			if route.matches(c) {
+				route.handle(ctx, c)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Router) newContext(ctx context.Context, update tgbotapi.Update) (*Context, bool) {
+	// Обрабатываются только сообщения и callback-запросы
+	if update.Message == nil && update.CallbackQuery == nil {
+		return nil, false
+	}
+
+	from := update.SentFrom()
+	chat := update.FromChat()
+	if from == nil || chat == nil {
+		return nil, false
+	}
+
+	c := &Context{
+		Update:     update,
+		TelegramID: from.ID,
+		ChatID:     chat.ID,
+		Handler:    r.handler,
+	}
+
+	user, err := r.handler.DB.GetUserByTelegramID(ctx, from.ID)
+	switch {
+	case err == nil:
+		c.User = user
+		c.State = user.State
+	case errors.Is(err, sql.ErrNoRows):
+		// Пользователь ещё не прошёл /start
+	default:
+		log.Printf("Ошибка при получении пользователя %d: %v", from.ID, err)
+	}
+	return c, true
+}
@@ -0,0 +1,203 @@
+// routes.go
+package main
+
+import (
+	"context"
+
+	"telegram_bot/callback"
+	"telegram_bot/models"
+	"telegram_bot/router"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerRoutes регистрирует все маршруты бота
+func registerRoutes(r *router.Router) {
+	// Отмена, тайм-ауты и проверка ввода для многошаговых диалогов
+	r.Before(func(ctx context.Context, c *router.Context) bool {
+		return c.Handler.GuardState(ctx, c.Update, c.State, c.IsAdmin())
+	})
+
+	// Состояния
+	r.State(models.StateAwaitingWithdrawalAmount, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleWithdrawAmount(ctx, c.Update)
+	})
+	r.State(models.StateAwaitingPayoutMethod, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleWithdrawMethod(ctx, c.Update)
+	})
+	r.State(models.StateAwaitingPayoutResults, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandlePayoutResultsFile(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingTaskImport, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleTaskImportFile(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingCategoryInput, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleCategoryInput(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateawaitingTaskCategoryUser, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleUserTaskCategory(ctx, c.Update)
+	})
+	r.State(models.StateAwaitingReferralTier, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleReferralTierInput(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingCardNumder, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleCardNumberReceived(ctx, c.Update)
+	})
+	r.State(models.StateAwaitingTaskCategory, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTaskCategorySelection(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingTaskLink, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTaskLink(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingTaskDescription, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTaskDescription(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingTaskExample, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTaskExample(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingTaskReward, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTaskReward(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingTaskConfirm, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTaskConfirm(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingTemplateInput, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleTemplateInput(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingTaskProof, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleTaskProof(ctx, c.Update)
+	})
+	r.State(models.StateAwaitingRejectionText, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleRejectionText(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingReasonInput, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleReasonInput(ctx, c.Update)
+	}).Admin()
+	r.State(models.StateAwaitingAppealText, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAppealText(ctx, c.Update)
+	})
+	r.State(models.StateAwaitingTriageRule, func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleTriageRuleInput(ctx, c.Update)
+	}).Admin()
+
+	// Команды
+	r.Command("start", func(ctx context.Context, c *router.Context) {
+		c.Handler.Start(ctx, c.Update)
+	})
+	r.Command("reconcile", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleReconcile(ctx, c.Update)
+	}).Admin()
+	r.Command("task_limits", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTaskLimits(ctx, c.Update)
+	}).Admin()
+	r.Command("tasks_import", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTaskImport(ctx, c.Update)
+	}).Admin()
+	r.Command("payouts_export", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminPayoutExport(ctx, c.Update)
+	}).Admin()
+	r.Command("payouts_import", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminPayoutImport(ctx, c.Update)
+	}).Admin()
+	r.Command("triage_log", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTriageLog(ctx, c.Update)
+	}).Admin()
+	r.Command("trust", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTrust(ctx, c.Update)
+	}).Admin()
+	r.Command("appeals", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminAppeals(ctx, c.Update)
+	}).Admin()
+	r.Command("senior", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminSenior(ctx, c.Update)
+	}).Admin()
+
+	// Callback-запросы (права проверяются отдельно для каждого действия)
+	r.Callback(callback.Version+":", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleCallback(ctx, c.Update)
+	})
+
+	// Скриншоты выполнения заданий
+	r.Photo(func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleTaskProof(ctx, c.Update)
+	}).Users()
+
+	// Меню администратора
+	r.Text("Добавить задание", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminAddTask(ctx, c.Update)
+	}).Admin()
+	r.Text("Проверить задания", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminCheckTasks(ctx, c.Update)
+	}).Admin()
+	r.Text("Заявки на вывод", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminWithdrawals(ctx, c.Update)
+	}).Admin()
+	r.Text("Реферальные уровни", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminReferralTiers(ctx, c.Update)
+	}).Admin()
+	r.Text("Категории", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminCategories(ctx, c.Update)
+	}).Admin()
+	r.Text("Шаблоны заданий", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTemplates(ctx, c.Update)
+	}).Admin()
+	r.Text("Причины отклонения", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminRejectionReasons(ctx, c.Update)
+	}).Admin()
+	r.Text("Автопроверка", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAdminTriageRules(ctx, c.Update)
+	}).Admin()
+	r.Text("Главное меню", func(ctx context.Context, c *router.Context) {
+		reply(c, "Главное меню:", c.Handler.AdminMenu)
+	}).Admin()
+
+	// Меню пользователя
+	r.Text("Показать баланс", func(ctx context.Context, c *router.Context) {
+		c.Handler.ShowBalance(ctx, c.ChatID, c.TelegramID)
+	}).Users()
+	r.Text("Личный кабинет", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleShowAccount(ctx, c.Update)
+	}).Users()
+	r.Text("Взять задание", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleAssignTask(ctx, c.Update)
+	}).Users()
+	r.Text("Вывести средства", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleWithdrawRequest(ctx, c.Update)
+	}).Users()
+	r.Text("Мои выводы", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleMyWithdrawals(ctx, c.Update)
+	}).Users()
+	r.Text("Мои рефералы", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleMyReferrals(ctx, c.Update)
+	}).Users()
+	r.Text("Мои реквизиты", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandlePayoutMethods(ctx, c.Update)
+	}).Users()
+	r.Text("История операций", func(ctx context.Context, c *router.Context) {
+		c.Handler.ShowTransactionHistory(ctx, c.ChatID, c.TelegramID)
+	}).Users()
+	r.Text("Обратиться в техподдержку", func(ctx context.Context, c *router.Context) {
+		c.Handler.HandleSupport(ctx, c.Update)
+	}).Users()
+
+	// Резервные обработчики
+	r.Fallback(func(ctx context.Context, c *router.Context) {
+		c.Handler.Bot.Request(tgbotapi.NewCallback(c.Update.CallbackQuery.ID, "Кнопка устарела."))
+	}).When(func(c *router.Context) bool { return c.Update.CallbackQuery != nil })
+	r.Fallback(func(ctx context.Context, c *router.Context) {
+		reply(c, "Неизвестная команда.", c.Handler.AdminMenu)
+	}).When(func(c *router.Context) bool { return c.Update.Message != nil && c.Update.Message.IsCommand() })
+	r.Fallback(func(ctx context.Context, c *router.Context) {
+		reply(c, "Неизвестная команда. Пожалуйста, выберите действие из меню.", c.Handler.AdminMenu)
+	}).Admin()
+	r.Fallback(func(ctx context.Context, c *router.Context) {
+		reply(c, "Команда не распознана. Пожалуйста, выберите действие из меню.", c.Handler.Keyboard)
+	})
+}
+
+// reply отправляет ответ в чат с указанной клавиатурой
+func reply(c *router.Context, text string, markup interface{}) {
+	msg := tgbotapi.NewMessage(c.ChatID, text)
+	msg.ReplyMarkup = markup
+	c.Handler.Bot.Send(msg)
+}
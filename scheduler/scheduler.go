@@ -0,0 +1,238 @@
+// scheduler/scheduler.go
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"telegram_bot/database"
+)
+
+// Статусы отложенных задач
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Handler выполняет задачу одного вида. Ошибка приводит к повтору с задержкой.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Typed оборачивает обработчик с типизированными параметрами задачи
+func Typed[T any](fn func(ctx context.Context, payload T) error) Handler {
+	return func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("некорректные параметры задачи: %w", err)
+		}
+		return fn(ctx, payload)
+	}
+}
+
+// Options - параметры опроса таблицы scheduled_jobs
+type Options struct {
+	PollInterval time.Duration // как часто искать наступившие задачи
+	BatchSize    int           // сколько задач забирать за раз
+	Lease        time.Duration // через сколько зависшая задача снова доступна
+	MaxAttempts  int           // попыток до перевода задачи в failed
+	JobTimeout   time.Duration // дедлайн выполнения одной задачи
+}
+
+// Scheduler - планировщик отложенных задач, хранящихся в Postgres.
+// Задачи переживают перезапуск, а SKIP LOCKED позволяет нескольким
+// экземплярам бота опрашивать одну таблицу без двойного выполнения.
+type Scheduler struct {
+	db       database.DBInterface
+	opts     Options
+	handlers map[string]Handler
+}
+
+type job struct {
+	id       int64
+	kind     string
+	payload  json.RawMessage
+	attempts int
+}
+
+// New создаёт планировщик
+func New(db database.DBInterface, opts Options) *Scheduler {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 20
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 5 * time.Minute
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.JobTimeout <= 0 {
+		opts.JobTimeout = time.Minute
+	}
+	return &Scheduler{
+		db:       db,
+		opts:     opts,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register регистрирует обработчик задач вида kind
+func (s *Scheduler) Register(kind string, h Handler) {
+	s.handlers[kind] = h
+}
+
+// Enqueue планирует задачу на время runAt. Если передан tx, задача
+// создаётся в той же транзакции, что и изменения, которые её породили.
+func (s *Scheduler) Enqueue(ctx context.Context, tx database.DBInterface, kind string, payload interface{}, runAt time.Time) (int64, error) {
+	if tx == nil {
+		tx = s.db
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось закодировать параметры задачи: %w", err)
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO scheduled_jobs (kind, payload, run_at, status, max_attempts)
+        VALUES ($1, $2, $3, $4, $5) RETURNING id
+    `, kind, data, runAt, StatusPending, s.opts.MaxAttempts).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось запланировать задачу %s: %w", kind, err)
+	}
+	return id, nil
+}
+
+// Run опрашивает таблицу и выполняет наступившие задачи до отмены ctx.
+// Задачи, не выполненные к моменту остановки, остаются в базе.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		jobs, err := s.claim(ctx)
+		if err != nil {
+			log.Printf("Планировщик: ошибка при получении задач: %v", err)
+			continue
+		}
+		for _, j := range jobs {
+			s.execute(j)
+		}
+	}
+}
+
+// claim забирает наступившие задачи и помечает их выполняемыми.
+// Задачи, зависшие в running дольше Lease, забираются повторно.
+func (s *Scheduler) claim(ctx context.Context) ([]job, error) {
+	var jobs []job
+	err := s.db.RunInTx(ctx, func(tx database.DBInterface) error {
+		rows, err := tx.QueryContext(ctx, `
+            SELECT id, kind, payload, attempts FROM scheduled_jobs
+            WHERE (status = $1 AND run_at <= NOW())
+               OR (status = $2 AND locked_until < NOW())
+            ORDER BY run_at
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        `, StatusPending, StatusRunning, s.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var j job
+			if err := rows.Scan(&j.id, &j.kind, &j.payload, &j.attempts); err != nil {
+				return err
+			}
+			jobs = append(jobs, j)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i := range jobs {
+			jobs[i].attempts++
+			_, err := tx.ExecContext(ctx, `
+                UPDATE scheduled_jobs
+                SET status = $1, attempts = $2, locked_until = NOW() + $3 * INTERVAL '1 second', updated_at = NOW()
+                WHERE id = $4
+            `, StatusRunning, jobs[i].attempts, int(s.opts.Lease.Seconds()), jobs[i].id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// execute выполняет задачу. Контекст не зависит от остановки опроса,
+// чтобы начатая задача успела завершиться.
+func (s *Scheduler) execute(j job) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.JobTimeout)
+	defer cancel()
+
+	h, ok := s.handlers[j.kind]
+	var err error
+	if !ok {
+		err = fmt.Errorf("нет обработчика для задачи вида %q", j.kind)
+	} else {
+		err = h(ctx, j.payload)
+	}
+
+	if err == nil {
+		s.finish(ctx, j, StatusDone, "", time.Time{})
+		return
+	}
+
+	log.Printf("Планировщик: задача %d (%s), попытка %d: %v", j.id, j.kind, j.attempts, err)
+	if j.attempts >= s.opts.MaxAttempts {
+		s.finish(ctx, j, StatusFailed, err.Error(), time.Time{})
+		return
+	}
+	s.finish(ctx, j, StatusPending, err.Error(), time.Now().Add(backoff(j.attempts)))
+}
+
+func (s *Scheduler) finish(ctx context.Context, j job, status, lastError string, runAt time.Time) {
+	_, err := s.db.ExecContext(ctx, `
+        UPDATE scheduled_jobs
+        SET status = $1, last_error = NULLIF($2, ''), run_at = COALESCE($3, run_at),
+            locked_until = NULL, updated_at = NOW()
+        WHERE id = $4
+    `, status, lastError, nullTime(runAt), j.id)
+	if err != nil {
+		log.Printf("Планировщик: не удалось обновить задачу %d: %v", j.id, err)
+	}
+}
+
+// backoff - экспоненциальная задержка перед повтором: 30с, 1м, 2м ... до 1ч
+func backoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,163 @@
+// taskimport/parse.go
+package taskimport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Форматы файла заданий
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Колонки CSV и ключи JSON
+const (
+	colCategory    = "category"
+	colLink        = "link"
+	colDescription = "description"
+	colReward      = "reward"
+	colQuota       = "quota"
+	colTemplate    = "template"
+)
+
+// Row - строка файла. Пустые reward, quota и template - значения по умолчанию:
+// вознаграждение категории, квота 1 и шаблон категории по умолчанию.
+type Row struct {
+	Line        int    `json:"-"`
+	Category    string `json:"category"`
+	Link        string `json:"link"`
+	Description string `json:"description"`
+	Reward      Value  `json:"reward"`
+	Quota       Value  `json:"quota"`
+	Template    Value  `json:"template"`
+}
+
+// Value - значение JSON, записанное строкой или числом
+type Value string
+
+// UnmarshalJSON принимает строку, число или null
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = Value(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*v = Value(n.String())
+		return nil
+	}
+	if string(data) == "null" {
+		*v = ""
+		return nil
+	}
+	return fmt.Errorf("ожидается строка или число, получено %s", data)
+}
+
+// DetectFormat определяет формат по имени файла, а если оно не помогает -
+// по первому символу содержимого
+func DetectFormat(name string, data []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".csv", ".txt":
+		return FormatCSV
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return FormatJSON
+	}
+	return FormatCSV
+}
+
+// Parse разбирает файл заданий. Строки нумеруются как в файле:
+// для CSV с учётом заголовка, для JSON - по порядку элементов массива.
+func Parse(data []byte, format string) ([]Row, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM из Excel
+	switch format {
+	case FormatCSV:
+		return parseCSV(data)
+	case FormatJSON:
+		return parseJSON(data)
+	}
+	return nil, fmt.Errorf("неизвестный формат файла %q", format)
+}
+
+func parseJSON(data []byte) ([]Row, error) {
+	var rows []Row
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать JSON: ожидается массив объектов: %w", err)
+	}
+	for i := range rows {
+		rows[i].Line = i + 1
+	}
+	return rows, nil
+}
+
+func parseCSV(data []byte) ([]Row, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	// Excel с русской локалью сохраняет CSV через точку с запятой
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+		cr.Comma = ';'
+	}
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("файл пуст")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать CSV: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{colCategory, colLink, colDescription} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("в первой строке нет колонки %s. Колонки: %s",
+				required, strings.Join([]string{colCategory, colLink, colDescription, colReward, colQuota, colTemplate}, ", "))
+		}
+	}
+
+	field := func(rec []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	var rows []Row
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать CSV: %w", err)
+		}
+		// Номер строки файла: описание в кавычках может занимать несколько строк
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, Row{
+			Line:        line,
+			Category:    field(rec, colCategory),
+			Link:        field(rec, colLink),
+			Description: field(rec, colDescription),
+			Reward:      Value(field(rec, colReward)),
+			Quota:       Value(field(rec, colQuota)),
+			Template:    Value(field(rec, colTemplate)),
+		})
+	}
+	return rows, nil
+}
@@ -0,0 +1,138 @@
+// taskimport/parse_test.go
+package taskimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []Row
+	}{
+		{
+			name: "запятая",
+			data: "category,link,description,reward,quota,template\n" +
+				"2gis,https://2gis.ru/a,Отзыв,15,10,\n" +
+				"yandex,https://ya.ru/b,Оценка,,,Фото\n",
+			want: []Row{
+				{Line: 2, Category: "2gis", Link: "https://2gis.ru/a", Description: "Отзыв", Reward: "15", Quota: "10"},
+				{Line: 3, Category: "yandex", Link: "https://ya.ru/b", Description: "Оценка", Template: "Фото"},
+			},
+		},
+		{
+			name: "точка с запятой из Excel",
+			data: "category;link;description;reward\n" +
+				"2gis;https://2gis.ru/a;Отзыв, 5 звёзд;12,50\n",
+			want: []Row{
+				{Line: 2, Category: "2gis", Link: "https://2gis.ru/a", Description: "Отзыв, 5 звёзд", Reward: "12,50"},
+			},
+		},
+		{
+			name: "BOM и регистр заголовка",
+			data: "\xef\xbb\xbfCategory, Link, Description\r\n2gis, https://2gis.ru/a, Отзыв\r\n",
+			want: []Row{
+				{Line: 2, Category: "2gis", Link: "https://2gis.ru/a", Description: "Отзыв"},
+			},
+		},
+		{
+			name: "многострочное описание в кавычках",
+			data: "category,link,description\n" +
+				"2gis,https://2gis.ru/a,\"Первая строка\nвторая, с запятой\"\n" +
+				"2gis,https://2gis.ru/b,Обычное\n",
+			want: []Row{
+				{Line: 2, Category: "2gis", Link: "https://2gis.ru/a", Description: "Первая строка\nвторая, с запятой"},
+				{Line: 4, Category: "2gis", Link: "https://2gis.ru/b", Description: "Обычное"},
+			},
+		},
+		{
+			name: "короткая строка",
+			data: "category,link,description,reward\n2gis,https://2gis.ru/a\n",
+			want: []Row{
+				{Line: 2, Category: "2gis", Link: "https://2gis.ru/a"},
+			},
+		},
+		{
+			name: "только заголовок",
+			data: "category,link,description\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data), FormatCSV)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v\nожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"пустой файл", "", "файл пуст"},
+		{"только BOM", "\xef\xbb\xbf", "файл пуст"},
+		{"нет обязательной колонки", "category,link\n2gis,https://2gis.ru/a\n", "нет колонки description"},
+		{"незакрытая кавычка", "category,link,description\n2gis,https://2gis.ru/a,\"Отзыв\n", "не удалось прочитать CSV"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.data), FormatCSV)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ошибка %v, ожидалась %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	data := `[
+		{"category": "2gis", "link": "https://2gis.ru/a", "description": "Отзыв", "reward": 15.5, "quota": "10"},
+		{"category": "yandex", "link": "https://ya.ru/b", "description": "Оценка", "reward": null, "template": "Фото"}
+	]`
+	want := []Row{
+		{Line: 1, Category: "2gis", Link: "https://2gis.ru/a", Description: "Отзыв", Reward: "15.5", Quota: "10"},
+		{Line: 2, Category: "yandex", Link: "https://ya.ru/b", Description: "Оценка", Template: "Фото"},
+	}
+	got, err := Parse([]byte("\xef\xbb\xbf"+data), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %+v\nожидалось %+v", got, want)
+	}
+
+	for _, bad := range []string{`{"category": "2gis"}`, `[{"reward": true}]`, `[`} {
+		if _, err := Parse([]byte(bad), FormatJSON); err == nil {
+			t.Errorf("Parse(%q) должен вернуть ошибку", bad)
+		}
+	}
+	if _, err := Parse([]byte(data), "xml"); err == nil {
+		t.Error("неизвестный формат должен вернуть ошибку")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"tasks.json", "", FormatJSON},
+		{"TASKS.CSV", "[", FormatCSV},
+		{"tasks.txt", "[", FormatCSV},
+		{"tasks", "  \n[{}]", FormatJSON},
+		{"", "category,link", FormatCSV},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.name, []byte(tt.data)); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %s, ожидался %s", tt.name, tt.data, got, tt.want)
+		}
+	}
+}
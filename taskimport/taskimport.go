@@ -0,0 +1,297 @@
+// taskimport/taskimport.go
+package taskimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram_bot/database"
+	"telegram_bot/models"
+	"telegram_bot/money"
+)
+
+// Ограничения загрузки
+const (
+	MaxRows        = 1000 // строк в одном файле
+	MaxDescription = 3000 // символов в описании задания
+	maxLink        = 255  // длина tasks.link
+)
+
+// Unlimited - значение квоты или бюджета «без ограничения»
+const Unlimited = "-"
+
+// Importer проверяет и создаёт задания из файла
+type Importer struct {
+	db database.DBInterface
+}
+
+// New создаёт загрузчик заданий
+func New(db database.DBInterface) *Importer {
+	return &Importer{db: db}
+}
+
+// RowError - ошибка в строке файла
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("строка %d: %v", e.Line, e.Err)
+}
+
+// Report - итог загрузки. В режиме проверки Tasks - задания, которые
+// были бы созданы.
+type Report struct {
+	DryRun bool
+	Rows   int
+	Tasks  []*models.Task
+	Lines  []int // номера строк файла для Tasks
+	Errors []RowError
+}
+
+// Text - отчёт для администратора. limit ограничивает число перечисленных
+// заданий и ошибок, 0 - без ограничения.
+func (r *Report) Text(limit int) string {
+	var b strings.Builder
+	if r.DryRun {
+		fmt.Fprintf(&b, "Проверка файла: строк %d, будет создано заданий %d, строк с ошибками %d.\n",
+			r.Rows, len(r.Tasks), len(r.Errors))
+	} else {
+		fmt.Fprintf(&b, "Загрузка: строк %d, создано заданий %d, строк с ошибками %d.\n",
+			r.Rows, len(r.Tasks), len(r.Errors))
+	}
+
+	if len(r.Errors) > 0 {
+		b.WriteString("\nОшибки:\n")
+		for i, e := range r.Errors {
+			if limit > 0 && i == limit {
+				fmt.Fprintf(&b, "… и ещё %d\n", len(r.Errors)-limit)
+				break
+			}
+			b.WriteString(e.Error() + "\n")
+		}
+	}
+
+	if len(r.Tasks) > 0 {
+		if r.DryRun {
+			b.WriteString("\nБудут созданы:\n")
+		} else {
+			b.WriteString("\nСозданы:\n")
+		}
+		for i, t := range r.Tasks {
+			if limit > 0 && i == limit {
+				fmt.Fprintf(&b, "… и ещё %d\n", len(r.Tasks)-limit)
+				break
+			}
+			id := ""
+			if t.ID != 0 {
+				id = fmt.Sprintf("#%d ", t.ID)
+			}
+			quota := "без ограничения"
+			if t.MaxCompletions != nil {
+				quota = strconv.Itoa(*t.MaxCompletions)
+			}
+			fmt.Fprintf(&b, "строка %d: %s%s, %s, награда %s, квота %s\n",
+				r.Lines[i], id, t.Category, t.Link, t.Reward, quota)
+		}
+	}
+	return b.String()
+}
+
+// Import проверяет все строки и создаёт задания из правильных строк
+// в одной транзакции. Строки с ошибками попадают в отчёт и пропускаются.
+// При dryRun задания не создаются.
+func (im *Importer) Import(ctx context.Context, data []byte, format string, dryRun bool) (*Report, error) {
+	rows, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > MaxRows {
+		return nil, fmt.Errorf("в файле %d строк, можно не больше %d", len(rows), MaxRows)
+	}
+
+	v, err := im.newValidator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: dryRun, Rows: len(rows)}
+	now := time.Now()
+	for _, row := range rows {
+		task, err := v.task(row)
+		if err != nil {
+			report.Errors = append(report.Errors, RowError{Line: row.Line, Err: err})
+			continue
+		}
+		task.CreatedAt = now
+		report.Tasks = append(report.Tasks, task)
+		report.Lines = append(report.Lines, row.Line)
+	}
+
+	if dryRun || len(report.Tasks) == 0 {
+		return report, nil
+	}
+	err = im.db.RunInTx(ctx, func(tx database.DBInterface) error {
+		for i, task := range report.Tasks {
+			if err := tx.CreateTask(ctx, task); err != nil {
+				return fmt.Errorf("строка %d: %w", report.Lines[i], err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, task := range report.Tasks {
+			task.ID = 0
+		}
+		return nil, fmt.Errorf("задания не созданы: %w", err)
+	}
+	return report, nil
+}
+
+// validator проверяет строки по категориям и шаблонам из базы
+type validator struct {
+	categories map[string]*models.Category
+	templates  []*models.TaskTemplate
+	links      map[string]int // ссылка → строка, где она встретилась впервые
+}
+
+func (im *Importer) newValidator(ctx context.Context) (*validator, error) {
+	categories, err := im.db.ListCategories(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении категорий: %w", err)
+	}
+	templates, err := im.db.ListTemplates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении шаблонов: %w", err)
+	}
+
+	v := &validator{
+		categories: make(map[string]*models.Category, len(categories)),
+		templates:  templates,
+		links:      make(map[string]int),
+	}
+	for _, c := range categories {
+		v.categories[c.Slug] = c
+	}
+	return v, nil
+}
+
+// task проверяет строку и возвращает задание для неё
+func (v *validator) task(row Row) (*models.Task, error) {
+	category, ok := v.categories[strings.ToLower(strings.TrimSpace(row.Category))]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная категория %q", row.Category)
+	}
+
+	link, err := ValidateLink(row.Link, category)
+	if err != nil {
+		return nil, err
+	}
+	if line, ok := v.links[link]; ok {
+		return nil, fmt.Errorf("ссылка уже есть в строке %d", line)
+	}
+
+	description := strings.TrimSpace(row.Description)
+	if description == "" || len([]rune(description)) > MaxDescription {
+		return nil, fmt.Errorf("описание должно быть непустым и не длиннее %d символов", MaxDescription)
+	}
+
+	maxCompletions := 1
+	task := &models.Task{
+		Category:       category.Slug,
+		Description:    description,
+		Link:           link,
+		IsActive:       true,
+		Status:         "New",
+		Reward:         category.DefaultReward,
+		MaxCompletions: &maxCompletions,
+		PerUserLimit:   1,
+	}
+
+	if s := strings.TrimSpace(string(row.Reward)); s != "" {
+		reward, err := ParseReward(s)
+		if err != nil {
+			return nil, err
+		}
+		task.Reward = reward
+	}
+
+	if s := strings.TrimSpace(string(row.Quota)); s != "" {
+		quota, err := ParseQuota(s)
+		if err != nil {
+			return nil, err
+		}
+		task.MaxCompletions = quota
+	}
+
+	template, err := v.template(category.Slug, strings.TrimSpace(string(row.Template)))
+	if err != nil {
+		return nil, err
+	}
+	if template != nil {
+		task.TemplateID = &template.ID
+	}
+
+	v.links[link] = row.Line
+	return task, nil
+}
+
+// ParseReward разбирает вознаграждение за задание
+func ParseReward(s string) (money.Amount, error) {
+	reward, err := money.Parse(s)
+	if err != nil || reward.IsNegative() {
+		return 0, fmt.Errorf("некорректное вознаграждение %q", s)
+	}
+	return reward, nil
+}
+
+// ParseQuota разбирает квоту задания. Unlimited снимает ограничение (nil).
+func ParseQuota(s string) (*int, error) {
+	if s == Unlimited {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("квота должна быть положительным числом или «%s», получено %q", Unlimited, s)
+	}
+	return &n, nil
+}
+
+// template находит шаблон категории по ID или названию. Пустое значение -
+// шаблон категории по умолчанию, если он есть.
+func (v *validator) template(category, ref string) (*models.TaskTemplate, error) {
+	for _, t := range v.templates {
+		if t.Category != category {
+			continue
+		}
+		if (ref == "" && t.IsDefault) || (ref != "" && (ref == strconv.Itoa(t.ID) || strings.EqualFold(ref, t.Name))) {
+			return t, nil
+		}
+	}
+	if ref == "" {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("шаблон %q не найден в категории %s", ref, category)
+}
+
+// ValidateLink проверяет ссылку задания: http(s) и домен площадки категории
+func ValidateLink(input string, category *models.Category) (string, error) {
+	link := strings.TrimSpace(input)
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", errors.New("ссылка должна начинаться с http:// или https://")
+	}
+	if len(link) > maxLink {
+		return "", fmt.Errorf("ссылка длиннее %d символов", maxLink)
+	}
+	if !category.AllowsHost(u.Hostname()) {
+		return "", fmt.Errorf("ссылка должна вести на %s", strings.Join(category.Domains, ", "))
+	}
+	return link, nil
+}
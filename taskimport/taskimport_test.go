@@ -0,0 +1,45 @@
+// taskimport/taskimport_test.go
+package taskimport
+
+import (
+	"testing"
+
+	"telegram_bot/money"
+)
+
+func TestParseReward(t *testing.T) {
+	tests := []struct {
+		in   string
+		want money.Amount
+		ok   bool
+	}{
+		{"40", money.Rubles(40), true},
+		{"12,50", money.Kopecks(1250), true},
+		{"0", 0, true},
+		{"-5", 0, false},
+		{"сорок", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseReward(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseReward(%q) = %s, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestParseQuota(t *testing.T) {
+	quota, err := ParseQuota(Unlimited)
+	if err != nil || quota != nil {
+		t.Errorf("ParseQuota(%q) = %v, %v, ожидалось без ограничения", Unlimited, quota, err)
+	}
+	quota, err = ParseQuota("100")
+	if err != nil || quota == nil || *quota != 100 {
+		t.Errorf("ParseQuota(\"100\") = %v, %v", quota, err)
+	}
+	for _, in := range []string{"0", "-1", "1.5", "много", ""} {
+		if _, err := ParseQuota(in); err == nil {
+			t.Errorf("ParseQuota(%q) должен вернуть ошибку", in)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// tasks.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"telegram_bot/database"
+	"telegram_bot/taskimport"
+)
+
+// runTasks выполняет подкоманду:
+//
+//	tasks import [-dry-run] файл.csv|файл.json
+func runTasks(args []string) {
+	if len(args) == 0 || args[0] != "import" {
+		fmt.Fprintln(os.Stderr, "использование: tasks import [-dry-run] файл.csv|файл.json")
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("tasks import", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "только проверить файл, ничего не создавая")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "использование: tasks import [-dry-run] файл.csv|файл.json")
+		os.Exit(2)
+	}
+
+	name := fs.Arg(0)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := database.InitDB()
+	defer database.CloseDB()
+	checkSchema(db)
+
+	report, err := taskimport.New(db).Import(context.Background(), data, taskimport.DetectFormat(name, data), *dryRun)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки заданий: %v", err)
+	}
+	fmt.Print(report.Text(0))
+}
@@ -0,0 +1,57 @@
+// tgfile/tgfile.go
+package tgfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// client скачивает файлы, присланные боту
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// DirectURL возвращает адрес файла. Адрес содержит токен бота, поэтому
+// его нельзя логировать; ошибка адреса не содержит.
+func DirectURL(bot *tgbotapi.BotAPI, fileID string) (string, error) {
+	fileURL, err := bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при получении адреса файла: %w", WithoutURL(err))
+	}
+	return fileURL, nil
+}
+
+// Download скачивает файл, присланный боту, не больше limit байт
+func Download(ctx context.Context, bot *tgbotapi.BotAPI, fileID string, limit int64) ([]byte, error) {
+	fileURL, err := DirectURL(bot, fileID)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, errors.New("некорректный адрес файла")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при скачивании файла: %w", WithoutURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка при скачивании файла: HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// WithoutURL убирает из ошибки HTTP-клиента адрес запроса
+func WithoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
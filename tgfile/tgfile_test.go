@@ -0,0 +1,25 @@
+// tgfile/tgfile_test.go
+package tgfile
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWithoutURL(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := &url.Error{Op: "Get", URL: "https://api.telegram.org/file/bot123:SECRET/photos/1.jpg", Err: cause}
+
+	got := WithoutURL(err)
+	if strings.Contains(got.Error(), "SECRET") {
+		t.Fatalf("WithoutURL() = %q, токен остался в ошибке", got)
+	}
+	if !errors.Is(got, cause) {
+		t.Fatalf("WithoutURL() = %v, ожидалась исходная причина", got)
+	}
+	if WithoutURL(cause) != cause {
+		t.Fatal("WithoutURL() изменил ошибку без адреса")
+	}
+}
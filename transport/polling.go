@@ -0,0 +1,134 @@
+// transport/polling.go
+package transport
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// retryDelay - пауза перед повторным запросом после ошибки getUpdates
+// или отказа Sink принять обновление
+const retryDelay = 3 * time.Second
+
+// ackTimeout - сколько ждать подтверждения смещения при остановке
+const ackTimeout = 5 * time.Second
+
+// Polling получает обновления через getUpdates
+type Polling struct {
+	bot     *tgbotapi.BotAPI
+	timeout int
+	sink    Sink
+}
+
+// NewPolling создаёт транспорт long polling
+func NewPolling(bot *tgbotapi.BotAPI, timeout int, sink Sink) *Polling {
+	return &Polling{bot: bot, timeout: timeout, sink: sink}
+}
+
+// Run удаляет webhook, если бот ранее работал в режиме webhook
+// (иначе getUpdates вернёт конфликт), и читает обновления до отмены ctx.
+//
+// Смещение сдвигается только за обновления, принятые Sink. Если Sink
+// вернул ошибку, это и все следующие обновления запрашиваются повторно,
+// поэтому они не теряются и порядок обновлений сохраняется.
+// Перед выходом смещение подтверждается, чтобы после перезапуска
+// обработанные обновления не пришли снова.
+func (p *Polling) Run(ctx context.Context) error {
+	if _, err := p.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("не удалось удалить webhook: %w", err)
+	}
+	log.Printf("Получение обновлений через long polling")
+
+	offset := 0
+	defer func() { p.ack(offset) }()
+	for {
+		updates, err := p.fetch(ctx, offset)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Printf("Ошибка получения обновлений: %v", err)
+			if !sleep(ctx, retryDelay) {
+				return nil
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			if err := p.sink(ctx, update); err != nil {
+				log.Printf("Обновление %d не принято, повторим: %v", update.UpdateID, err)
+				if !sleep(ctx, retryDelay) {
+					return nil
+				}
+				break
+			}
+			offset = update.UpdateID + 1
+		}
+	}
+}
+
+// ack подтверждает Telegram обновления до offset. Telegram считает
+// обновление доставленным, только когда getUpdates запрошен с большим
+// смещением, поэтому без этого последняя пачка пришла бы повторно.
+func (p *Polling) ack(offset int) {
+	if offset == 0 {
+		return
+	}
+	u := tgbotapi.NewUpdate(offset)
+	u.Timeout = 0
+	u.Limit = 1
+
+	// Недоступность Telegram не должна задерживать остановку бота
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.bot.GetUpdates(u)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Printf("Не удалось подтвердить обновления до %d: %v", offset, err)
+		}
+	case <-time.After(ackTimeout):
+		log.Printf("Не удалось подтвердить обновления до %d: нет ответа за %s", offset, ackTimeout)
+	}
+}
+
+// fetch выполняет getUpdates, не дожидаясь ответа после отмены ctx
+func (p *Polling) fetch(ctx context.Context, offset int) ([]tgbotapi.Update, error) {
+	u := tgbotapi.NewUpdate(offset)
+	u.Timeout = p.timeout
+
+	type result struct {
+		updates []tgbotapi.Update
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		updates, err := p.bot.GetUpdates(u)
+		done <- result{updates, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.updates, r.err
+	}
+}
+
+// sleep ждёт d или отмены ctx. Возвращает false, если ctx отменён.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
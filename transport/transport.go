@@ -0,0 +1,25 @@
+// transport/transport.go
+package transport
+
+import (
+	"context"
+
+	"telegram_bot/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Sink принимает обновления от любого транспорта.
+// Long polling и webhook передают обновления в один и тот же Sink.
+// Ошибка означает, что обновление не принято: транспорт не подтверждает
+// его Telegram, и оно будет доставлено повторно.
+type Sink func(ctx context.Context, update tgbotapi.Update) error
+
+// Run получает обновления в режиме из конфигурации и передаёт их в sink.
+// Блокируется до отмены ctx.
+func Run(ctx context.Context, bot *tgbotapi.BotAPI, cfg *config.Config, sink Sink) error {
+	if cfg.Mode == config.ModeWebhook {
+		return NewWebhook(bot, cfg.Webhook, sink).Run(ctx)
+	}
+	return NewPolling(bot, 60, sink).Run(ctx)
+}
@@ -0,0 +1,130 @@
+// transport/webhook.go
+package transport
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"telegram_bot/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SecretTokenHeader - заголовок, в котором Telegram передаёт secret_token
+const SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// maxUpdateSize ограничивает размер тела запроса с обновлением
+const maxUpdateSize = 1 << 20
+
+// Webhook принимает обновления через встроенный HTTP(S)-сервер
+type Webhook struct {
+	bot  *tgbotapi.BotAPI
+	cfg  config.WebhookConfig
+	sink Sink
+}
+
+// NewWebhook создаёт транспорт webhook
+func NewWebhook(bot *tgbotapi.BotAPI, cfg config.WebhookConfig, sink Sink) *Webhook {
+	return &Webhook{bot: bot, cfg: cfg, sink: sink}
+}
+
+// Handler возвращает HTTP-обработчик webhook. Его можно вызывать напрямую
+// из httptest без запуска сервера и регистрации в Telegram.
+func (w *Webhook) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(w.cfg.Path(), w.serveUpdate)
+	return mux
+}
+
+func (w *Webhook) serveUpdate(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(SecretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(w.cfg.SecretToken)) != 1 {
+		http.Error(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxUpdateSize)).Decode(&update); err != nil {
+		log.Printf("Некорректное обновление webhook: %v", err)
+		http.Error(rw, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Не 200: Telegram повторит доставку, а не потеряет обновление
+	if err := w.sink(r.Context(), update); err != nil {
+		log.Printf("Обновление %d не принято: %v", update.UpdateID, err)
+		http.Error(rw, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+// Run регистрирует webhook в Telegram и обслуживает запросы до отмены ctx
+func (w *Webhook) Run(ctx context.Context) error {
+	if err := w.register(); err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              w.cfg.Listen,
+		Handler:           w.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if w.cfg.CertFile != "" {
+			err = srv.ListenAndServeTLS(w.cfg.CertFile, w.cfg.KeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		errCh <- err
+	}()
+	log.Printf("Получение обновлений через webhook на %s", w.cfg.Listen)
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("ошибка HTTP-сервера webhook: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("ошибка остановки HTTP-сервера webhook: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// register вызывает setWebhook. WebhookConfig библиотеки не поддерживает
+// secret_token, поэтому запрос формируется вручную.
+func (w *Webhook) register() error {
+	params := tgbotapi.Params{
+		"url":          w.cfg.URL(),
+		"secret_token": w.cfg.SecretToken,
+	}
+	if w.cfg.MaxConnections > 0 {
+		params["max_connections"] = strconv.Itoa(w.cfg.MaxConnections)
+	}
+
+	if _, err := w.bot.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("не удалось зарегистрировать webhook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// transport/webhook_test.go
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telegram_bot/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	cfg := config.WebhookConfig{PathSecret: "path-secret", SecretToken: "token"}
+	const update = `{"update_id": 42, "message": {"message_id": 1, "text": "/start"}}`
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		token   string
+		body    string
+		sinkErr error
+		want    int
+		sunk    bool
+	}{
+		{name: "принято", path: "/webhook/path-secret", token: "token", body: update, want: http.StatusOK, sunk: true},
+		{name: "без токена", path: "/webhook/path-secret", body: update, want: http.StatusForbidden},
+		{name: "неверный токен", path: "/webhook/path-secret", token: "wrong", body: update, want: http.StatusForbidden},
+		{name: "неверный путь", path: "/webhook/wrong", token: "token", body: update, want: http.StatusNotFound},
+		{name: "некорректное тело", path: "/webhook/path-secret", token: "token", body: "{", want: http.StatusBadRequest},
+		{name: "не POST", method: http.MethodGet, path: "/webhook/path-secret", token: "token", want: http.StatusMethodNotAllowed},
+		{
+			name: "sink не принял", path: "/webhook/path-secret", token: "token", body: update,
+			sinkErr: errors.New("очередь обработки переполнена"), want: http.StatusServiceUnavailable, sunk: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *tgbotapi.Update
+			sink := func(ctx context.Context, update tgbotapi.Update) error {
+				got = &update
+				return tt.sinkErr
+			}
+			h := NewWebhook(nil, cfg, sink).Handler()
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set(SecretTokenHeader, tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("статус %d, ожидался %d", rec.Code, tt.want)
+			}
+			if (got != nil) != tt.sunk {
+				t.Fatalf("обновление передано в sink: %v, ожидалось %v", got != nil, tt.sunk)
+			}
+			if got != nil && got.UpdateID != 42 {
+				t.Errorf("update_id %d, ожидался 42", got.UpdateID)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// triage/rules.go
+package triage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"telegram_bot/models"
+)
+
+// Сигналы, по которым правила оценивают выполнение
+const (
+	SignalTrust      = "trust"      // уровень доверия исполнителя
+	SignalApproved   = "approved"   // одобренных выполнений исполнителя
+	SignalRate       = "rate"       // доля одобренных среди проверенных, от 0 до 1
+	SignalProofs     = "proofs"     // доказательств в выполнении
+	SignalDuration   = "duration"   // минут от выдачи задания до сдачи
+	SignalGap        = "gap"        // наименьший промежуток между шагами, минут
+	SignalDuplicates = "duplicates" // скриншотов, уже присланных в других выполнениях
+	SignalSimilar    = "similar"    // скриншотов, похожих на скриншоты других выполнений
+)
+
+// signalNames - описание сигналов для администратора
+var signalNames = []struct{ Name, Text string }{
+	{SignalTrust, "уровень доверия исполнителя"},
+	{SignalApproved, "одобренных выполнений исполнителя"},
+	{SignalRate, "доля одобренных среди проверенных, от 0 до 1"},
+	{SignalProofs, "доказательств в выполнении"},
+	{SignalDuration, "минут от выдачи задания до сдачи"},
+	{SignalGap, "наименьший промежуток между шагами, минут"},
+	{SignalDuplicates, "скриншотов, уже присланных в других выполнениях"},
+	{SignalSimilar, "скриншотов, похожих на скриншоты других выполнений"},
+}
+
+// operators - сравнения в порядке разбора: двухсимвольные раньше односимвольных
+var operators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// actionNames - действия правил, как их вводит администратор
+var actionNames = map[string]string{
+	"approve":   models.TriageApprove,
+	"одобрить":  models.TriageApprove,
+	"reject":    models.TriageReject,
+	"отклонить": models.TriageReject,
+	"review":    models.TriageReview,
+	"проверить": models.TriageReview,
+}
+
+// MaxConditions ограничивает число условий в правиле
+const MaxConditions = 10
+
+var (
+	// ErrRuleFormat - строка правила не разобрана
+	ErrRuleFormat = errors.New("ожидается строка «категория; действие; условие; ...»")
+	// ErrNoConditions - автоматическое решение без условий применялось бы ко всем выполнениям
+	ErrNoConditions = errors.New("для одобрения и отклонения нужно хотя бы одно условие")
+)
+
+// SignalsHelp - список сигналов для подсказки администратору
+func SignalsHelp() string {
+	var b strings.Builder
+	for _, s := range signalNames {
+		fmt.Fprintf(&b, "%s - %s\n", s.Name, s.Text)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// ParseRule разбирает строку вида «2gis; одобрить; approved>=10; rate>=0.9».
+// Категория «*» означает любую. Существование категории проверяет вызывающий.
+func ParseRule(line string) (*models.TriageRule, error) {
+	parts := strings.Split(line, ";")
+	if len(parts) < 2 {
+		return nil, ErrRuleFormat
+	}
+
+	rule := &models.TriageRule{Category: strings.ToLower(strings.TrimSpace(parts[0]))}
+	if rule.Category == "" {
+		return nil, ErrRuleFormat
+	}
+	action, ok := actionNames[strings.ToLower(strings.TrimSpace(parts[1]))]
+	if !ok {
+		return nil, fmt.Errorf("неизвестное действие %q: укажите одобрить, отклонить или проверить", strings.TrimSpace(parts[1]))
+	}
+	rule.Action = action
+
+	for _, part := range parts[2:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		c, err := ParseCondition(part)
+		if err != nil {
+			return nil, err
+		}
+		rule.Conditions = append(rule.Conditions, c)
+	}
+	if len(rule.Conditions) > MaxConditions {
+		return nil, fmt.Errorf("условий больше %d", MaxConditions)
+	}
+	if len(rule.Conditions) == 0 && rule.Action != models.TriageReview {
+		return nil, ErrNoConditions
+	}
+	return rule, nil
+}
+
+// ParseCondition разбирает условие вида «rate>=0.9»
+func ParseCondition(s string) (models.TriageCondition, error) {
+	for _, op := range operators {
+		name, value, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !knownSignal(name) {
+			return models.TriageCondition{}, fmt.Errorf("неизвестный сигнал %q", name)
+		}
+		v, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(value), ",", "."), 64)
+		if err != nil {
+			return models.TriageCondition{}, fmt.Errorf("условие %q: порог должен быть числом", s)
+		}
+		return models.TriageCondition{Signal: name, Op: op, Value: v}, nil
+	}
+	return models.TriageCondition{}, fmt.Errorf("условие %q: нет сравнения (>=, <=, >, <, =, !=)", s)
+}
+
+func knownSignal(name string) bool {
+	for _, s := range signalNames {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatCondition - условие в том виде, в каком его вводит администратор
+func FormatCondition(c models.TriageCondition) string {
+	return c.Signal + c.Op + strconv.FormatFloat(c.Value, 'f', -1, 64)
+}
+
+// FormatRule - правило одной строкой для списка
+func FormatRule(r *models.TriageRule) string {
+	category := r.Category
+	if category == models.TriageAnyCategory {
+		category = "любая категория"
+	}
+	text := fmt.Sprintf("%s: %s", category, ActionText(r.Action))
+	if len(r.Conditions) > 0 {
+		conditions := make([]string, len(r.Conditions))
+		for i, c := range r.Conditions {
+			conditions[i] = FormatCondition(c)
+		}
+		text += ", если " + strings.Join(conditions, "; ")
+	}
+	return text
+}
+
+// ActionText - действие правила для администратора
+func ActionText(action string) string {
+	switch action {
+	case models.TriageApprove:
+		return "одобрить"
+	case models.TriageReject:
+		return "отклонить"
+	}
+	return "к модератору"
+}
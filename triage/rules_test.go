@@ -0,0 +1,111 @@
+// triage/rules_test.go
+package triage
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"telegram_bot/models"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		in   string
+		want models.TriageCondition
+	}{
+		{"rate>=0.9", models.TriageCondition{Signal: SignalRate, Op: ">=", Value: 0.9}},
+		{"rate >= 0,9", models.TriageCondition{Signal: SignalRate, Op: ">=", Value: 0.9}},
+		{"approved>10", models.TriageCondition{Signal: SignalApproved, Op: ">", Value: 10}},
+		{"duration<=5", models.TriageCondition{Signal: SignalDuration, Op: "<=", Value: 5}},
+		{"gap<1.5", models.TriageCondition{Signal: SignalGap, Op: "<", Value: 1.5}},
+		{"duplicates=0", models.TriageCondition{Signal: SignalDuplicates, Op: "=", Value: 0}},
+		{"TRUST!=-1", models.TriageCondition{Signal: SignalTrust, Op: "!=", Value: -1}},
+	}
+	for _, tt := range tests {
+		got, err := ParseCondition(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseCondition(%q) = %+v, %v; ожидалось %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"rate", "rate~1", "speed>1", "rate>=abc", "rate>=", ">=1", "rate=>1"} {
+		if _, err := ParseCondition(in); err == nil {
+			t.Errorf("ParseCondition(%q) должен вернуть ошибку", in)
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *models.TriageRule
+	}{
+		{
+			in: "2GIS; Одобрить; approved>=10; rate>=0.9",
+			want: &models.TriageRule{Category: "2gis", Action: models.TriageApprove, Conditions: []models.TriageCondition{
+				{Signal: SignalApproved, Op: ">=", Value: 10},
+				{Signal: SignalRate, Op: ">=", Value: 0.9},
+			}},
+		},
+		{
+			in: "*; reject; duplicates>0;",
+			want: &models.TriageRule{Category: models.TriageAnyCategory, Action: models.TriageReject, Conditions: []models.TriageCondition{
+				{Signal: SignalDuplicates, Op: ">", Value: 0},
+			}},
+		},
+		{
+			in:   "yandex; проверить",
+			want: &models.TriageRule{Category: "yandex", Action: models.TriageReview},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseRule(tt.in)
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRule(%q) = %+v, ожидалось %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	tooMany := "2gis; одобрить" + strings.Repeat("; rate>=0.9", MaxConditions+1)
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"2gis", ErrRuleFormat},
+		{" ; одобрить; rate>=0.9", ErrRuleFormat},
+		{"2gis; одобрить", ErrNoConditions},
+		{"2gis; reject; ;", ErrNoConditions},
+		{"2gis; удалить; rate>=0.9", nil},
+		{"2gis; одобрить; rate", nil},
+		{tooMany, nil},
+	}
+	for _, tt := range tests {
+		_, err := ParseRule(tt.in)
+		if err == nil {
+			t.Errorf("ParseRule(%q) должен вернуть ошибку", tt.in)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("ParseRule(%q) = %v, ожидалась %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	for _, in := range []string{"rate>=0.95", "approved>10", "duplicates=0", "gap!=2.5"} {
+		c, err := ParseCondition(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := FormatCondition(c); got != in {
+			t.Errorf("FormatCondition(ParseCondition(%q)) = %q", in, got)
+		}
+	}
+}
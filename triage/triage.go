@@ -0,0 +1,207 @@
+// triage/triage.go
+package triage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"telegram_bot/database"
+	"telegram_bot/models"
+)
+
+// Signals - значения сигналов выполнения по именам Signal*
+type Signals map[string]float64
+
+// Describe - значения сигналов, упомянутых в условиях, для истории решений
+func (s Signals) Describe(conditions []models.TriageCondition) string {
+	seen := make(map[string]bool)
+	var parts []string
+	for _, c := range conditions {
+		if seen[c.Signal] {
+			continue
+		}
+		seen[c.Signal] = true
+		parts = append(parts, c.Signal+"="+strconv.FormatFloat(s[c.Signal], 'f', -1, 64))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Holds проверяет условие. Неизвестный сигнал условию не удовлетворяет.
+func (s Signals) Holds(c models.TriageCondition) bool {
+	v, ok := s[c.Signal]
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case ">=":
+		return v >= c.Value
+	case "<=":
+		return v <= c.Value
+	case ">":
+		return v > c.Value
+	case "<":
+		return v < c.Value
+	case "=":
+		return v == c.Value
+	case "!=":
+		return v != c.Value
+	}
+	return false
+}
+
+// Match возвращает первое активное правило категории, все условия
+// которого выполнены, или nil
+func Match(rules []*models.TriageRule, category string, s Signals) *models.TriageRule {
+	for _, r := range rules {
+		if !r.IsActive || (r.Category != models.TriageAnyCategory && r.Category != category) {
+			continue
+		}
+		matched := true
+		for _, c := range r.Conditions {
+			if !s.Holds(c) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return r
+		}
+	}
+	return nil
+}
+
+// Engine оценивает сданные выполнения по правилам из таблицы triage_rules.
+// Правила читаются при каждой оценке, поэтому меняются без перезапуска.
+type Engine struct {
+	db database.DBInterface
+	// maxDistance - до скольких различающихся битов хэша скриншоты похожи
+	maxDistance int
+}
+
+// maxSimilar ограничивает поиск похожих скриншотов при оценке
+const maxSimilar = 100
+
+// New создаёт движок автоматической проверки
+func New(db database.DBInterface, maxDistance int) *Engine {
+	return &Engine{db: db, maxDistance: maxDistance}
+}
+
+// Evaluate собирает сигналы выполнения и подбирает правило. Если ни одно
+// правило не подошло, возвращает nil: выполнение проверит модератор.
+func (e *Engine) Evaluate(ctx context.Context, userTask *models.UserTask, category string) (*models.TriageRule, Signals, error) {
+	rules, err := e.db.ListTriageRules(ctx, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil, nil
+	}
+	s, err := e.Collect(ctx, userTask)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Match(rules, category, s), s, nil
+}
+
+// Collect вычисляет сигналы выполнения
+func (e *Engine) Collect(ctx context.Context, userTask *models.UserTask) (Signals, error) {
+	s := make(Signals)
+
+	var trust int
+	err := e.db.QueryRowContext(ctx, "SELECT trust_level FROM users WHERE id = $1", userTask.UserID).Scan(&trust)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении уровня доверия: %w", err)
+	}
+	s[SignalTrust] = float64(trust)
+
+	// Текущее выполнение ещё не проверено и в историю не входит
+	stats, err := e.db.GetExecutorStats(ctx, userTask.UserID)
+	if err != nil {
+		return nil, err
+	}
+	s[SignalApproved] = float64(stats.Approved)
+	s[SignalRate] = 0
+	if decided := stats.Approved + stats.Rejected; decided > 0 {
+		s[SignalRate] = float64(stats.Approved) / float64(decided)
+	}
+
+	proofs, err := e.db.ListUserTaskProofs(ctx, userTask.ID)
+	if err != nil {
+		return nil, err
+	}
+	s[SignalProofs] = float64(len(proofs))
+
+	// У выполнений, выданных до появления assigned_at, время считается
+	// от первого доказательства
+	var seconds float64
+	err = e.db.QueryRowContext(ctx, `
+        SELECT COALESCE(EXTRACT(EPOCH FROM ut.last_updated - COALESCE(ut.assigned_at,
+            (SELECT MIN(p.created_at) FROM user_task_proofs p WHERE p.user_task_id = ut.id))), 0)
+        FROM user_tasks ut WHERE ut.id = $1
+    `, userTask.ID).Scan(&seconds)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при вычислении времени выполнения: %w", err)
+	}
+	s[SignalDuration] = seconds / 60
+	s[SignalGap] = s[SignalDuration]
+	if gap, ok := stageGap(proofs); ok {
+		s[SignalGap] = gap
+	}
+
+	var duplicates int
+	err = e.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM user_task_proofs p
+        WHERE p.user_task_id = $1 AND NOT p.superseded AND p.file_unique_id IS NOT NULL
+          AND EXISTS (
+              SELECT 1 FROM user_task_proofs o
+              WHERE o.file_unique_id = p.file_unique_id
+                AND o.user_task_id <> p.user_task_id AND NOT o.superseded
+          )
+    `, userTask.ID).Scan(&duplicates)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при поиске повторных скриншотов: %w", err)
+	}
+	s[SignalDuplicates] = float64(duplicates)
+
+	similar, err := e.db.FindSimilarProofs(ctx, userTask.ID, e.maxDistance, maxSimilar)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при поиске похожих скриншотов: %w", err)
+	}
+	flagged := make(map[int64]bool)
+	for _, p := range similar {
+		flagged[p.ProofID] = true
+	}
+	s[SignalSimilar] = float64(len(flagged))
+	return s, nil
+}
+
+// stageGap - наименьший промежуток в минутах между последними доказательствами
+// соседних шагов. ok = false, если доказательства есть меньше чем на двух шагах.
+func stageGap(proofs []*models.UserTaskProof) (float64, bool) {
+	last := make(map[int]*models.UserTaskProof)
+	var stages []int
+	for _, p := range proofs {
+		if _, ok := last[p.Stage]; !ok {
+			stages = append(stages, p.Stage)
+		}
+		if prev, ok := last[p.Stage]; !ok || p.CreatedAt.After(prev.CreatedAt) {
+			last[p.Stage] = p
+		}
+	}
+	if len(stages) < 2 {
+		return 0, false
+	}
+	sort.Ints(stages)
+
+	gap := -1.0
+	for i := 1; i < len(stages); i++ {
+		d := last[stages[i]].CreatedAt.Sub(last[stages[i-1]].CreatedAt).Minutes()
+		if gap < 0 || d < gap {
+			gap = d
+		}
+	}
+	return max(gap, 0), true
+}
@@ -0,0 +1,90 @@
+// triage/triage_test.go
+package triage
+
+import (
+	"testing"
+	"time"
+
+	"telegram_bot/models"
+)
+
+func TestHolds(t *testing.T) {
+	s := Signals{SignalRate: 0.9}
+	tests := []struct {
+		op   string
+		v    float64
+		want bool
+	}{
+		{">=", 0.9, true}, {">", 0.9, false}, {"<=", 0.9, true}, {"<", 0.9, false},
+		{"=", 0.9, true}, {"!=", 0.9, false}, {">", 0.5, true}, {"~", 0.9, false},
+	}
+	for _, tt := range tests {
+		c := models.TriageCondition{Signal: SignalRate, Op: tt.op, Value: tt.v}
+		if got := s.Holds(c); got != tt.want {
+			t.Errorf("Holds(rate%s%v) = %v, ожидалось %v", tt.op, tt.v, got, tt.want)
+		}
+	}
+	if s.Holds(models.TriageCondition{Signal: SignalGap, Op: ">=", Value: 0}) {
+		t.Error("условие с отсутствующим сигналом не должно выполняться")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cond := func(signal, op string, v float64) models.TriageCondition {
+		return models.TriageCondition{Signal: signal, Op: op, Value: v}
+	}
+	inactive := &models.TriageRule{ID: 1, Category: "2gis", Action: models.TriageReject, IsActive: false,
+		Conditions: []models.TriageCondition{cond(SignalRate, ">=", 0)}}
+	reject := &models.TriageRule{ID: 2, Category: models.TriageAnyCategory, Action: models.TriageReject, IsActive: true,
+		Conditions: []models.TriageCondition{cond(SignalDuplicates, ">", 0)}}
+	approve := &models.TriageRule{ID: 3, Category: "2gis", Action: models.TriageApprove, IsActive: true,
+		Conditions: []models.TriageCondition{cond(SignalApproved, ">=", 10), cond(SignalRate, ">=", 0.9)}}
+	review := &models.TriageRule{ID: 4, Category: "yandex", Action: models.TriageReview, IsActive: true}
+	rules := []*models.TriageRule{inactive, reject, approve, review}
+
+	tests := []struct {
+		name     string
+		category string
+		signals  Signals
+		want     *models.TriageRule
+	}{
+		{"первое подходящее по порядку", "2gis", Signals{SignalDuplicates: 1, SignalApproved: 20, SignalRate: 1}, reject},
+		{"все условия выполнены", "2gis", Signals{SignalDuplicates: 0, SignalApproved: 10, SignalRate: 0.9}, approve},
+		{"не все условия", "2gis", Signals{SignalDuplicates: 0, SignalApproved: 10, SignalRate: 0.5}, nil},
+		{"другая категория", "avito", Signals{SignalDuplicates: 0, SignalApproved: 10, SignalRate: 1}, nil},
+		{"правило без условий", "yandex", Signals{SignalDuplicates: 0}, review},
+		{"нет сигналов", "2gis", Signals{}, nil},
+	}
+	for _, tt := range tests {
+		if got := Match(rules, tt.category, tt.signals); got != tt.want {
+			t.Errorf("%s: Match = %+v, ожидалось %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStageGap(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	proof := func(stage int, minutes float64) *models.UserTaskProof {
+		return &models.UserTaskProof{Stage: stage, CreatedAt: base.Add(time.Duration(minutes * float64(time.Minute)))}
+	}
+
+	tests := []struct {
+		name   string
+		proofs []*models.UserTaskProof
+		want   float64
+		ok     bool
+	}{
+		{"нет доказательств", nil, 0, false},
+		{"один шаг", []*models.UserTaskProof{proof(1, 0), proof(1, 5)}, 0, false},
+		{"два шага", []*models.UserTaskProof{proof(1, 0), proof(2, 30)}, 30, true},
+		{"берётся последнее доказательство шага", []*models.UserTaskProof{proof(1, 0), proof(1, 20), proof(2, 30)}, 10, true},
+		{"наименьший промежуток", []*models.UserTaskProof{proof(3, 100), proof(1, 0), proof(2, 90)}, 10, true},
+		{"время назад не уходит в минус", []*models.UserTaskProof{proof(1, 10), proof(2, 5)}, 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := stageGap(tt.proofs)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: stageGap = %v, %v; ожидалось %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
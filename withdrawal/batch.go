@@ -0,0 +1,242 @@
+// withdrawal/batch.go
+package withdrawal
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram_bot/database"
+	"telegram_bot/models"
+	"telegram_bot/money"
+	"telegram_bot/payout"
+)
+
+// Форматы выгрузки одобренных заявок
+const (
+	FormatCSV      = "csv"      // CSV с заголовком для учёта
+	FormatRegistry = "registry" // реестр выплат для загрузки в банк
+)
+
+// Revealer расшифровывает реквизиты заявки для выгрузки
+type Revealer interface {
+	Reveal(ctx context.Context, id int64) (payout.Details, error)
+}
+
+// ExportRow - одна заявка в выгрузке
+type ExportRow struct {
+	WithdrawalID int64
+	TelegramID   int64
+	Amount       money.Amount
+	Method       string
+	Details      string
+	CreatedAt    time.Time
+}
+
+// Batch - одобренные заявки, ожидающие перевода
+type Batch struct {
+	CreatedAt time.Time
+	Rows      []ExportRow
+	Total     money.Amount
+}
+
+// Approved собирает выгрузку одобренных заявок. При full реквизиты
+// расшифровываются, иначе выгружается маска.
+func (s *Service) Approved(ctx context.Context, revealer Revealer, full bool) (*Batch, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT w.id, u.telegram_id, w.amount, w.destination, w.payout_method_id,
+               COALESCE(m.kind, ''), w.created_at
+        FROM withdrawals w
+        JOIN users u ON u.id = w.user_id
+        LEFT JOIN payout_methods m ON m.id = w.payout_method_id
+        WHERE w.status = $1
+        ORDER BY w.id
+    `, models.WithdrawalApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batch := &Batch{CreatedAt: time.Now()}
+	var methodIDs []*int64
+	for rows.Next() {
+		var r ExportRow
+		var methodID *int64
+		var kind string
+		if err := rows.Scan(&r.WithdrawalID, &r.TelegramID, &r.Amount, &r.Details, &methodID, &kind, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.Method = payout.Kind(kind).Title()
+		batch.Rows = append(batch.Rows, r)
+		batch.Total = batch.Total.Add(r.Amount)
+		methodIDs = append(methodIDs, methodID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if full {
+		for i := range batch.Rows {
+			if methodIDs[i] == nil {
+				continue
+			}
+			details, err := revealer.Reveal(ctx, *methodIDs[i])
+			if err != nil {
+				return nil, fmt.Errorf("заявка %d: %w", batch.Rows[i].WithdrawalID, err)
+			}
+			batch.Rows[i].Details = details.Value
+		}
+	}
+	return batch, nil
+}
+
+// WriteCSV пишет выгрузку в CSV: одна строка на заявку
+func (b *Batch) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"withdrawal_id", "telegram_id", "amount", "method", "details", "created_at"})
+	for _, r := range b.Rows {
+		cw.Write([]string{
+			strconv.FormatInt(r.WithdrawalID, 10),
+			strconv.FormatInt(r.TelegramID, 10),
+			r.Amount.Decimal(),
+			r.Method,
+			r.Details,
+			r.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteRegistry пишет реестр выплат: заголовок, строки через ";" с
+// суммой в рублях через запятую и итоговая строка
+func (b *Batch) WriteRegistry(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	cw.Write([]string{"Реестр выплат", b.CreatedAt.Format("02.01.2006"), strconv.Itoa(len(b.Rows)), b.Total.Format()})
+	cw.Write([]string{"№", "Заявка", "Получатель", "Способ", "Реквизиты", "Сумма", "Назначение платежа"})
+	for i, r := range b.Rows {
+		cw.Write([]string{
+			strconv.Itoa(i + 1),
+			strconv.FormatInt(r.WithdrawalID, 10),
+			strconv.FormatInt(r.TelegramID, 10),
+			r.Method,
+			r.Details,
+			strings.ReplaceAll(r.Amount.Format(), " ", ""),
+			fmt.Sprintf("Выплата по заявке %d", r.WithdrawalID),
+		})
+	}
+	cw.Write([]string{"ИТОГО", "", "", "", "", strings.ReplaceAll(b.Total.Format(), " ", ""), ""})
+	cw.Flush()
+	return cw.Error()
+}
+
+// Encode возвращает выгрузку в указанном формате
+func (b *Batch) Encode(format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatCSV:
+		err = b.WriteCSV(&buf)
+	case FormatRegistry:
+		err = b.WriteRegistry(&buf)
+	default:
+		err = fmt.Errorf("неизвестный формат выгрузки %q", format)
+	}
+	return buf.Bytes(), err
+}
+
+// Результаты перевода в файле банка
+const (
+	ResultPaid   = "paid"
+	ResultFailed = "failed"
+)
+
+// ImportReport - итог загрузки результатов выплат
+type ImportReport struct {
+	Paid    []int64
+	Failed  []int64
+	Skipped []int64 // заявки не в статусе approved, например уже обработанные ранее
+	Errors  []string
+}
+
+// String - краткий отчёт для администратора
+func (r *ImportReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Выплачено: %d\nНе прошло: %d\nПропущено (не ожидают перевода): %d\n", len(r.Paid), len(r.Failed), len(r.Skipped))
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(&b, "Ошибки (%d):\n", len(r.Errors))
+		for _, e := range r.Errors {
+			b.WriteString(e + "\n")
+		}
+	}
+	return b.String()
+}
+
+// ImportResults читает CSV с результатами переводов: withdrawal_id,status[,comment],
+// где status - paid или failed. Каждая строка обрабатывается в своей
+// транзакции, поэтому ошибка в одной строке не отменяет остальные,
+// а повторная загрузка того же файла пропускает уже обработанные заявки.
+func (s *Service) ImportResults(ctx context.Context, r io.Reader, actorID int64) (*ImportReport, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать CSV: %w", err)
+	}
+
+	report := &ImportReport{}
+	for i, rec := range records {
+		line := i + 1
+		if len(rec) < 2 {
+			report.Errors = append(report.Errors, fmt.Sprintf("строка %d: ожидается withdrawal_id,status[,comment]", line))
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(rec[0]), 10, 64)
+		if err != nil {
+			if i == 0 {
+				continue // заголовок
+			}
+			report.Errors = append(report.Errors, fmt.Sprintf("строка %d: некорректный номер заявки %q", line, rec[0]))
+			continue
+		}
+		comment := ""
+		if len(rec) > 2 {
+			comment = strings.TrimSpace(rec[2])
+		}
+
+		status := strings.ToLower(strings.TrimSpace(rec[1]))
+		switch status {
+		case ResultPaid:
+			_, err = s.MarkPaid(ctx, id, actorID, comment)
+		case ResultFailed:
+			if comment == "" {
+				comment = "Перевод не прошёл"
+			}
+			_, err = s.MarkFailed(ctx, id, actorID, comment)
+		default:
+			report.Errors = append(report.Errors, fmt.Sprintf("строка %d: неизвестный статус %q", line, rec[1]))
+			continue
+		}
+
+		switch {
+		case err == nil && status == ResultPaid:
+			report.Paid = append(report.Paid, id)
+		case err == nil:
+			report.Failed = append(report.Failed, id)
+		case errors.Is(err, database.ErrStatusChanged):
+			report.Skipped = append(report.Skipped, id)
+		default:
+			report.Errors = append(report.Errors, fmt.Sprintf("строка %d, заявка %d: %v", line, id, err))
+		}
+	}
+	return report, nil
+}
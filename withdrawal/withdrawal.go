@@ -0,0 +1,357 @@
+// withdrawal/withdrawal.go
+package withdrawal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"telegram_bot/database"
+	"telegram_bot/ledger"
+	"telegram_bot/models"
+	"telegram_bot/money"
+)
+
+var (
+	// ErrBelowMinimum - сумма меньше минимальной
+	ErrBelowMinimum = errors.New("сумма меньше минимальной")
+	// ErrAboveMaximum - сумма больше максимальной
+	ErrAboveMaximum = errors.New("сумма больше максимальной")
+	// ErrNotFound - заявка не найдена
+	ErrNotFound = errors.New("заявка не найдена")
+	// ErrMethodDeleted - реквизиты заявки удалены пользователем
+	ErrMethodDeleted = errors.New("реквизиты удалены")
+)
+
+const columns = "id, user_id, amount, destination, payout_method_id, status, comment, created_at, updated_at"
+
+// transitions - допустимые переходы между статусами заявки
+var transitions = map[string][]string{
+	models.WithdrawalRequested: {models.WithdrawalHeld, models.WithdrawalRejected},
+	models.WithdrawalHeld:      {models.WithdrawalApproved, models.WithdrawalRejected},
+	models.WithdrawalApproved:  {models.WithdrawalPaid, models.WithdrawalRejected, models.WithdrawalFailed},
+}
+
+// Limits - ограничения суммы одной заявки. Нулевой Max - без ограничения.
+type Limits struct {
+	Min money.Amount
+	Max money.Amount
+}
+
+// Service ведёт заявки на вывод. Каждое изменение статуса записывается
+// в withdrawal_events, а движение средств - в журнал в той же транзакции.
+type Service struct {
+	db     database.DBInterface
+	ledger *ledger.Ledger
+	limits Limits
+}
+
+// New создаёт сервис заявок на вывод
+func New(db database.DBInterface, l *ledger.Ledger, limits Limits) *Service {
+	return &Service{db: db, ledger: l, limits: limits}
+}
+
+// Limits возвращает ограничения суммы заявки
+func (s *Service) Limits() Limits {
+	return s.limits
+}
+
+// Validate проверяет сумму заявки против ограничений и доступного баланса
+func (s *Service) Validate(amount, balance money.Amount) error {
+	switch {
+	case !amount.IsPositive() || amount.Cmp(s.limits.Min) < 0:
+		return ErrBelowMinimum
+	case !s.limits.Max.IsZero() && amount.Cmp(s.limits.Max) > 0:
+		return ErrAboveMaximum
+	case amount.Cmp(balance) > 0:
+		return ledger.ErrInsufficientFunds
+	}
+	return nil
+}
+
+// Request создаёт заявку на выплату по сохранённым реквизитам и блокирует
+// сумму на счёте withdrawal_hold. В заявке хранится только маска реквизитов.
+func (s *Service) Request(ctx context.Context, userID int, amount money.Amount, method *models.PayoutMethod) (*models.Withdrawal, error) {
+	if method.UserID != userID {
+		return nil, fmt.Errorf("реквизиты %d не принадлежат пользователю %d", method.ID, userID)
+	}
+	if method.DeletedAt != nil {
+		return nil, ErrMethodDeleted
+	}
+
+	var w *models.Withdrawal
+	err := s.db.RunInTx(ctx, func(tx database.DBInterface) error {
+		// Реквизиты могли удалить после того, как их выбрали
+		var deleted bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT deleted_at IS NOT NULL FROM payout_methods WHERE id = $1 FOR UPDATE", method.ID).Scan(&deleted)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			return ErrMethodDeleted
+		}
+
+		var balance money.Amount
+		err = tx.QueryRowContext(ctx, "SELECT balance FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&balance)
+		if err != nil {
+			return err
+		}
+		if err := s.Validate(amount, balance); err != nil {
+			return err
+		}
+
+		var id int64
+		err = tx.QueryRowContext(ctx, `
+            INSERT INTO withdrawals (user_id, amount, destination, payout_method_id, status)
+            VALUES ($1, $2, $3, $4, $5) RETURNING id
+        `, userID, amount, method.Label, method.ID, models.WithdrawalRequested).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("ошибка при создании заявки на вывод: %w", err)
+		}
+		if err := logEvent(ctx, tx, id, "", models.WithdrawalRequested, 0, ""); err != nil {
+			return err
+		}
+
+		_, err = s.ledger.Post(ctx, tx, ledger.Transfer(
+			ledger.KindWithdrawalHold,
+			fmt.Sprintf("Заявка на вывод #%d", id),
+			fmt.Sprintf("withdrawal:%d:hold", id),
+			ledger.UserWallet(userID), ledger.WithdrawalHold, amount,
+		))
+		if err != nil {
+			return err
+		}
+
+		w, err = s.transition(ctx, tx, id, models.WithdrawalHeld, 0, "")
+		return err
+	})
+	return w, err
+}
+
+// Approve одобряет заявку. Средства остаются заблокированными до выплаты.
+// actorID - Telegram ID администратора, 0 - системное действие (CLI).
+func (s *Service) Approve(ctx context.Context, id int64, actorID int64) (*models.Withdrawal, error) {
+	var w *models.Withdrawal
+	err := s.db.RunInTx(ctx, func(tx database.DBInterface) error {
+		var err error
+		w, err = s.transition(ctx, tx, id, models.WithdrawalApproved, actorID, "")
+		return err
+	})
+	return w, err
+}
+
+// MarkPaid отмечает заявку выплаченной и списывает сумму с блокировки
+func (s *Service) MarkPaid(ctx context.Context, id int64, actorID int64, comment string) (*models.Withdrawal, error) {
+	var w *models.Withdrawal
+	err := s.db.RunInTx(ctx, func(tx database.DBInterface) error {
+		var err error
+		w, err = s.transition(ctx, tx, id, models.WithdrawalPaid, actorID, comment)
+		if err != nil {
+			return err
+		}
+		_, err = s.ledger.Post(ctx, tx, ledger.Transfer(
+			ledger.KindPayout,
+			fmt.Sprintf("Выплата по заявке #%d", id),
+			fmt.Sprintf("withdrawal:%d:payout", id),
+			ledger.WithdrawalHold, ledger.PayoutClearing, w.Amount,
+		))
+		return err
+	})
+	return w, err
+}
+
+// Reject отклоняет заявку и возвращает сумму в кошелёк пользователя
+func (s *Service) Reject(ctx context.Context, id int64, actorID int64, comment string) (*models.Withdrawal, error) {
+	return s.release(ctx, id, models.WithdrawalRejected, actorID, comment)
+}
+
+// MarkFailed отмечает, что перевод по одобренной заявке не прошёл,
+// и возвращает сумму в кошелёк пользователя
+func (s *Service) MarkFailed(ctx context.Context, id int64, actorID int64, comment string) (*models.Withdrawal, error) {
+	return s.release(ctx, id, models.WithdrawalFailed, actorID, comment)
+}
+
+// release переводит заявку в конечный статус с возвратом средств
+func (s *Service) release(ctx context.Context, id int64, to string, actorID int64, comment string) (*models.Withdrawal, error) {
+	var w *models.Withdrawal
+	err := s.db.RunInTx(ctx, func(tx database.DBInterface) error {
+		var err error
+		w, err = s.transition(ctx, tx, id, to, actorID, comment)
+		if err != nil {
+			return err
+		}
+		_, err = s.ledger.Post(ctx, tx, ledger.Transfer(
+			ledger.KindWithdrawalRelease,
+			fmt.Sprintf("Возврат по заявке #%d", id),
+			fmt.Sprintf("withdrawal:%d:release", id),
+			ledger.WithdrawalHold, ledger.UserWallet(w.UserID), w.Amount,
+		))
+		return err
+	})
+	return w, err
+}
+
+// Get возвращает заявку по ID
+func (s *Service) Get(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	return get(ctx, s.db, id, false)
+}
+
+// ListByUser возвращает последние заявки пользователя
+func (s *Service) ListByUser(ctx context.Context, userID int, limit int) ([]*models.Withdrawal, error) {
+	return list(ctx, s.db, `
+        SELECT `+columns+`
+        FROM withdrawals WHERE user_id = $1
+        ORDER BY created_at DESC LIMIT $2
+    `, userID, limit)
+}
+
+// ListOpen возвращает заявки, ожидающие решения или выплаты
+func (s *Service) ListOpen(ctx context.Context) ([]*models.Withdrawal, error) {
+	return list(ctx, s.db, `
+        SELECT `+columns+`
+        FROM withdrawals WHERE status IN ($1, $2)
+        ORDER BY created_at
+    `, models.WithdrawalHeld, models.WithdrawalApproved)
+}
+
+// Events возвращает историю изменений заявки
+func (s *Service) Events(ctx context.Context, id int64) ([]models.WithdrawalEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, withdrawal_id, from_status, to_status, actor_telegram_id, comment, created_at
+        FROM withdrawal_events WHERE withdrawal_id = $1
+        ORDER BY id
+    `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.WithdrawalEvent
+	for rows.Next() {
+		var e models.WithdrawalEvent
+		if err := rows.Scan(&e.ID, &e.WithdrawalID, &e.FromStatus, &e.ToStatus, &e.ActorID, &e.Comment, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// transition меняет статус заявки, если переход допустим, и пишет событие.
+// Строка блокируется, поэтому два администратора не изменят её одновременно.
+func (s *Service) transition(ctx context.Context, tx database.DBInterface, id int64, to string, actorID int64, comment string) (*models.Withdrawal, error) {
+	w, err := get(ctx, tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed(w.Status, to) {
+		return nil, database.ErrStatusChanged
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE withdrawals SET status = $1, comment = $2, updated_at = NOW() WHERE id = $3
+    `, to, comment, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при обновлении заявки на вывод: %w", err)
+	}
+	if err := logEvent(ctx, tx, id, w.Status, to, actorID, comment); err != nil {
+		return nil, err
+	}
+
+	w.Status = to
+	w.Comment = comment
+	return w, nil
+}
+
+func allowed(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func logEvent(ctx context.Context, tx database.DBInterface, id int64, from, to string, actorID int64, comment string) error {
+	var actor *int64
+	if actorID != 0 {
+		actor = &actorID
+	}
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO withdrawal_events (withdrawal_id, from_status, to_status, actor_telegram_id, comment)
+        VALUES ($1, $2, $3, $4, $5)
+    `, id, from, to, actor, comment)
+	if err != nil {
+		return fmt.Errorf("ошибка при записи события заявки: %w", err)
+	}
+	return nil
+}
+
+func get(ctx context.Context, q database.DBInterface, id int64, forUpdate bool) (*models.Withdrawal, error) {
+	query := "SELECT " + columns + " FROM withdrawals WHERE id = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	w, err := scan(q.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func list(ctx context.Context, q database.DBInterface, query string, args ...interface{}) ([]*models.Withdrawal, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*models.Withdrawal
+	for rows.Next() {
+		w, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, w)
+	}
+	return result, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (*models.Withdrawal, error) {
+	w := &models.Withdrawal{}
+	err := row.Scan(&w.ID, &w.UserID, &w.Amount, &w.Destination, &w.PayoutMethodID,
+		&w.Status, &w.Comment, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// StatusTitle возвращает название статуса для сообщений
+func StatusTitle(status string) string {
+	switch status {
+	case models.WithdrawalRequested:
+		return "создана"
+	case models.WithdrawalHeld:
+		return "на рассмотрении"
+	case models.WithdrawalApproved:
+		return "одобрена, ожидает перевода"
+	case models.WithdrawalPaid:
+		return "выплачена"
+	case models.WithdrawalRejected:
+		return "отклонена"
+	case models.WithdrawalFailed:
+		return "перевод не прошёл, средства возвращены"
+	}
+	return status
+}
@@ -0,0 +1,24 @@
+// withdrawal/withdrawal_test.go
+package withdrawal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"telegram_bot/models"
+	"telegram_bot/money"
+)
+
+func TestRequestRejectsDeletedMethod(t *testing.T) {
+	deletedAt := time.Now()
+	method := &models.PayoutMethod{ID: 7, UserID: 1, Label: "Visa •••• 1111", DeletedAt: &deletedAt}
+
+	// Заявка на удалённые реквизиты отклоняется до обращения к базе
+	s := New(nil, nil, Limits{})
+	_, err := s.Request(context.Background(), 1, money.Rubles(100), method)
+	if !errors.Is(err, ErrMethodDeleted) {
+		t.Fatalf("Request() = %v, ожидалась ErrMethodDeleted", err)
+	}
+}